@@ -0,0 +1,281 @@
+// fichier: config/config.go
+package config
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config regroupe les paramètres de l'application configurables par variables d'environnement
+type Config struct {
+	// Durée d'inactivité après laquelle une session expire
+	SessionIdleTimeout time.Duration
+	// Durée de vie maximale d'une session, quelle que soit l'activité
+	SessionAbsoluteTimeout time.Duration
+	// Intervalle minimal entre deux renouvellements d'une même session
+	SessionRenewInterval time.Duration
+	// Intervalle d'exécution de la tâche de nettoyage en arrière-plan
+	JanitorInterval time.Duration
+	// Âge au-delà duquel un indicateur de frappe est considéré comme obsolète
+	TypingIndicatorTTL time.Duration
+	// Émetteur affiché dans les applications d'authentification TOTP
+	TwoFactorIssuer string
+	// Durée de validité d'une session partielle en attente du second facteur
+	TwoFactorChallengeTTL time.Duration
+	// Nombre d'échecs tolérés par compte avant verrouillage temporaire
+	LoginMaxFailuresPerAccount int
+	// Nombre d'échecs tolérés par adresse IP avant verrouillage temporaire
+	LoginMaxFailuresPerIP int
+	// Durée du premier verrouillage, doublée à chaque échec supplémentaire
+	LoginLockoutBase time.Duration
+	// Durée maximale d'un verrouillage
+	LoginLockoutMax time.Duration
+	// Délai sans échec après lequel le compteur d'échecs est remis à zéro
+	LoginFailureWindow time.Duration
+	// Faire confiance à l'en-tête X-Forwarded-For (derrière un proxy inverse)
+	TrustProxyHeaders bool
+	// Utilisateurs promus administrateurs au démarrage
+	AdminUsernames []string
+
+	// Environnement d'exécution (development ou production)
+	Environment string
+	// Origines autorisées pour les requêtes cross-origin (la même origine est toujours autorisée)
+	AllowedOrigins []string
+	// Attribut Secure du cookie de session (HTTPS uniquement)
+	CookieSecure bool
+	// Attribut SameSite du cookie de session
+	CookieSameSite http.SameSite
+	// Durée de validité d'un ticket de connexion WebSocket
+	WSTicketTTL time.Duration
+
+	// Nombre maximal de tentatives de livraison d'un webhook
+	WebhookMaxAttempts int
+	// Délai avant la première nouvelle tentative, doublé à chaque échec
+	WebhookRetryBase time.Duration
+	// Délai maximal entre deux tentatives
+	WebhookRetryMax time.Duration
+	// Délai d'attente d'une requête de livraison
+	WebhookTimeout time.Duration
+	// Intervalle de consultation de la file de livraison
+	WebhookPollInterval time.Duration
+	// Durée de conservation du journal des livraisons terminées
+	WebhookRetention time.Duration
+
+	// Intervalle de consultation des publications programmées
+	DraftPublishInterval time.Duration
+
+	// URL publique du forum, utilisée dans les liens envoyés par email
+	BaseURL string
+	// Adresse d'expédition des emails
+	MailFrom string
+	// Serveur SMTP (hôte:port) ; sans serveur, les emails sont écrits dans le journal
+	SMTPAddr string
+	// Identifiants SMTP (optionnels)
+	SMTPUsername string
+	SMTPPassword string
+	// Durée de validité d'un lien de vérification d'adresse email
+	EmailVerificationTTL time.Duration
+
+	// Répertoire du stockage local des fichiers envoyés
+	StorageDir string
+	// Taille maximale d'un avatar envoyé, en octets
+	AvatarMaxBytes int
+	// Taille maximale d'une pièce jointe, en octets
+	AttachmentMaxBytes int
+	// Espace total alloué aux pièces jointes de chaque utilisateur, en octets
+	AttachmentQuotaBytes int
+	// Délai après lequel une pièce jointe envoyée mais jamais utilisée est supprimée
+	AttachmentOrphanTTL time.Duration
+}
+
+// App contient la configuration chargée au démarrage
+var App = Default()
+
+// Default retourne la configuration par défaut
+func Default() Config {
+	return Config{
+		SessionIdleTimeout:     24 * time.Hour,
+		SessionAbsoluteTimeout: 7 * 24 * time.Hour,
+		SessionRenewInterval:   time.Minute,
+		JanitorInterval:        5 * time.Minute,
+		TypingIndicatorTTL:     time.Minute,
+		TwoFactorIssuer:        "RealTimeForum",
+		TwoFactorChallengeTTL:  5 * time.Minute,
+
+		LoginMaxFailuresPerAccount: 5,
+		LoginMaxFailuresPerIP:      20,
+		LoginLockoutBase:           time.Minute,
+		LoginLockoutMax:            time.Hour,
+		LoginFailureWindow:         15 * time.Minute,
+
+		Environment:    "development",
+		AllowedOrigins: []string{},
+		CookieSecure:   false,
+		CookieSameSite: http.SameSiteLaxMode,
+		WSTicketTTL:    30 * time.Second,
+
+		WebhookMaxAttempts:  8,
+		WebhookRetryBase:    30 * time.Second,
+		WebhookRetryMax:     time.Hour,
+		WebhookTimeout:      10 * time.Second,
+		WebhookPollInterval: 5 * time.Second,
+		WebhookRetention:    30 * 24 * time.Hour,
+
+		DraftPublishInterval: 30 * time.Second,
+
+		BaseURL:              "http://localhost:8080",
+		MailFrom:             "forum@localhost",
+		EmailVerificationTTL: 24 * time.Hour,
+
+		StorageDir:     "uploads",
+		AvatarMaxBytes: 5 << 20,
+
+		AttachmentMaxBytes:   10 << 20,
+		AttachmentQuotaBytes: 100 << 20,
+		AttachmentOrphanTTL:  24 * time.Hour,
+	}
+}
+
+// Load charge la configuration depuis les variables d'environnement
+func Load() {
+	cfg := Default()
+
+	cfg.SessionIdleTimeout = durationEnv("FORUM_SESSION_IDLE_TIMEOUT", cfg.SessionIdleTimeout)
+	cfg.SessionAbsoluteTimeout = durationEnv("FORUM_SESSION_ABSOLUTE_TIMEOUT", cfg.SessionAbsoluteTimeout)
+	cfg.SessionRenewInterval = durationEnv("FORUM_SESSION_RENEW_INTERVAL", cfg.SessionRenewInterval)
+	cfg.JanitorInterval = durationEnv("FORUM_JANITOR_INTERVAL", cfg.JanitorInterval)
+	cfg.TypingIndicatorTTL = durationEnv("FORUM_TYPING_TTL", cfg.TypingIndicatorTTL)
+	cfg.TwoFactorIssuer = stringEnv("FORUM_2FA_ISSUER", cfg.TwoFactorIssuer)
+	cfg.TwoFactorChallengeTTL = durationEnv("FORUM_2FA_CHALLENGE_TTL", cfg.TwoFactorChallengeTTL)
+	cfg.LoginMaxFailuresPerAccount = intEnv("FORUM_LOGIN_MAX_FAILURES", cfg.LoginMaxFailuresPerAccount)
+	cfg.LoginMaxFailuresPerIP = intEnv("FORUM_LOGIN_MAX_FAILURES_PER_IP", cfg.LoginMaxFailuresPerIP)
+	cfg.LoginLockoutBase = durationEnv("FORUM_LOGIN_LOCKOUT_BASE", cfg.LoginLockoutBase)
+	cfg.LoginLockoutMax = durationEnv("FORUM_LOGIN_LOCKOUT_MAX", cfg.LoginLockoutMax)
+	cfg.LoginFailureWindow = durationEnv("FORUM_LOGIN_FAILURE_WINDOW", cfg.LoginFailureWindow)
+	cfg.TrustProxyHeaders = boolEnv("FORUM_TRUST_PROXY", cfg.TrustProxyHeaders)
+	cfg.AdminUsernames = listEnv("FORUM_ADMIN_USERNAMES", cfg.AdminUsernames)
+
+	// En production, les cookies sont par défaut réservés à HTTPS et au même site
+	cfg.Environment = stringEnv("FORUM_ENV", cfg.Environment)
+	if cfg.Environment == "production" {
+		cfg.CookieSecure = true
+		cfg.CookieSameSite = http.SameSiteStrictMode
+	}
+	cfg.AllowedOrigins = listEnv("FORUM_ALLOWED_ORIGINS", cfg.AllowedOrigins)
+	cfg.CookieSecure = boolEnv("FORUM_COOKIE_SECURE", cfg.CookieSecure)
+	cfg.CookieSameSite = sameSiteEnv("FORUM_COOKIE_SAMESITE", cfg.CookieSameSite)
+	cfg.WSTicketTTL = durationEnv("FORUM_WS_TICKET_TTL", cfg.WSTicketTTL)
+	cfg.WebhookMaxAttempts = intEnv("FORUM_WEBHOOK_MAX_ATTEMPTS", cfg.WebhookMaxAttempts)
+	cfg.WebhookRetryBase = durationEnv("FORUM_WEBHOOK_RETRY_BASE", cfg.WebhookRetryBase)
+	cfg.WebhookRetryMax = durationEnv("FORUM_WEBHOOK_RETRY_MAX", cfg.WebhookRetryMax)
+	cfg.WebhookTimeout = durationEnv("FORUM_WEBHOOK_TIMEOUT", cfg.WebhookTimeout)
+	cfg.WebhookPollInterval = durationEnv("FORUM_WEBHOOK_POLL_INTERVAL", cfg.WebhookPollInterval)
+	cfg.WebhookRetention = durationEnv("FORUM_WEBHOOK_RETENTION", cfg.WebhookRetention)
+	cfg.DraftPublishInterval = durationEnv("FORUM_DRAFT_PUBLISH_INTERVAL", cfg.DraftPublishInterval)
+	cfg.BaseURL = strings.TrimSuffix(stringEnv("FORUM_BASE_URL", cfg.BaseURL), "/")
+	cfg.MailFrom = stringEnv("FORUM_MAIL_FROM", cfg.MailFrom)
+	cfg.SMTPAddr = stringEnv("FORUM_SMTP_ADDR", cfg.SMTPAddr)
+	cfg.SMTPUsername = stringEnv("FORUM_SMTP_USERNAME", cfg.SMTPUsername)
+	cfg.SMTPPassword = stringEnv("FORUM_SMTP_PASSWORD", cfg.SMTPPassword)
+	cfg.EmailVerificationTTL = durationEnv("FORUM_EMAIL_VERIFICATION_TTL", cfg.EmailVerificationTTL)
+	cfg.StorageDir = stringEnv("FORUM_STORAGE_DIR", cfg.StorageDir)
+	cfg.AvatarMaxBytes = intEnv("FORUM_AVATAR_MAX_BYTES", cfg.AvatarMaxBytes)
+	cfg.AttachmentMaxBytes = intEnv("FORUM_ATTACHMENT_MAX_BYTES", cfg.AttachmentMaxBytes)
+	cfg.AttachmentQuotaBytes = intEnv("FORUM_ATTACHMENT_QUOTA_BYTES", cfg.AttachmentQuotaBytes)
+	cfg.AttachmentOrphanTTL = durationEnv("FORUM_ATTACHMENT_ORPHAN_TTL", cfg.AttachmentOrphanTTL)
+	if cfg.CookieSameSite == http.SameSiteNoneMode && !cfg.CookieSecure {
+		log.Printf("Attention: SameSite=None sans Secure est refusé par les navigateurs récents")
+	}
+
+	App = cfg
+}
+
+// stringEnv lit une chaîne depuis une variable d'environnement
+func stringEnv(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// intEnv lit un entier strictement positif depuis une variable d'environnement
+func intEnv(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Valeur invalide pour %s (%q), utilisation de la valeur par défaut %d", name, value, fallback)
+		return fallback
+	}
+	return n
+}
+
+// boolEnv lit un booléen (true/false, 1/0) depuis une variable d'environnement
+func boolEnv(name string, fallback bool) bool {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Valeur invalide pour %s (%q), utilisation de la valeur par défaut %t", name, value, fallback)
+		return fallback
+	}
+	return b
+}
+
+// listEnv lit une liste de valeurs séparées par des virgules depuis une variable d'environnement
+func listEnv(name string, fallback []string) []string {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// sameSiteEnv lit un mode SameSite (lax, strict ou none) depuis une variable d'environnement
+func sameSiteEnv(name string, fallback http.SameSite) http.SameSite {
+	switch strings.ToLower(os.Getenv(name)) {
+	case "":
+		return fallback
+	case "lax":
+		return http.SameSiteLaxMode
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		log.Printf("Valeur invalide pour %s (%q), utilisation de la valeur par défaut", name, os.Getenv(name))
+		return fallback
+	}
+}
+
+// durationEnv lit une durée (format time.ParseDuration) depuis une variable d'environnement
+func durationEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Valeur invalide pour %s (%q), utilisation de la valeur par défaut %s", name, value, fallback)
+		return fallback
+	}
+	return d
+}
@@ -0,0 +1,182 @@
+// fichier: database/apitokens.go
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Préfixe distinguant les jetons d'accès personnels des IDs de session
+const APITokenPrefix = "rtf_"
+
+// Intervalle minimal entre deux mises à jour de last_used_at
+const apiTokenTouchInterval = time.Minute
+
+// ==================================
+// API Token Operations
+// ==================================
+
+// IsAPIToken indique si une valeur d'en-tête Bearer est un jeton d'accès personnel
+func IsAPIToken(value string) bool {
+	return strings.HasPrefix(value, APITokenPrefix)
+}
+
+// hashAPIToken hache un jeton d'accès (seul le haché est stocké)
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIToken crée un jeton d'accès et retourne sa valeur en clair, qui n'est plus récupérable ensuite
+func CreateAPIToken(userID int, req APITokenRequest) (*APIToken, string, error) {
+	random, err := generateToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	value := APITokenPrefix + random
+
+	token := &APIToken{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    value[:len(APITokenPrefix)+8],
+		Scopes:    req.Scopes,
+		CreatedAt: time.Now(),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := token.CreatedAt.AddDate(0, 0, req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	result, err := DB.Exec(
+		"INSERT INTO api_tokens (user_id, name, token_hash, prefix, scopes, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		token.UserID, token.Name, hashAPIToken(value), token.Prefix, strings.Join(token.Scopes, ","), token.CreatedAt, token.ExpiresAt,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, "", err
+	}
+	token.ID = int(id)
+
+	return token, value, nil
+}
+
+// scanAPIToken lit une ligne de la table api_tokens
+func scanAPIToken(scanner interface{ Scan(...interface{}) error }) (*APIToken, error) {
+	token := &APIToken{}
+	var scopes string
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+
+	err := scanner.Scan(
+		&token.ID, &token.UserID, &token.Name, &token.Prefix, &scopes,
+		&token.CreatedAt, &expiresAt, &lastUsedAt, &revokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Scopes = strings.Split(scopes, ",")
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+
+	return token, nil
+}
+
+// GetAPITokenByValue récupère un jeton d'accès valide (non révoqué, non expiré) à partir de sa valeur
+func GetAPITokenByValue(value string) (*APIToken, error) {
+	row := DB.QueryRow(`
+		SELECT id, user_id, name, prefix, scopes, created_at, expires_at, last_used_at, revoked_at
+		FROM api_tokens
+		WHERE token_hash = ?
+	`, hashAPIToken(value))
+
+	token, err := scanAPIToken(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("jeton d'accès non trouvé")
+		}
+		return nil, err
+	}
+
+	if token.RevokedAt != nil {
+		return nil, errors.New("jeton d'accès révoqué")
+	}
+	if token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt) {
+		return nil, errors.New("jeton d'accès expiré")
+	}
+
+	return token, nil
+}
+
+// TouchAPIToken met à jour la date de dernière utilisation d'un jeton (au plus une fois par minute)
+func TouchAPIToken(token *APIToken) error {
+	now := time.Now()
+	if token.LastUsedAt != nil && now.Sub(*token.LastUsedAt) < apiTokenTouchInterval {
+		return nil
+	}
+	_, err := DB.Exec("UPDATE api_tokens SET last_used_at = ? WHERE id = ?", now, token.ID)
+	return err
+}
+
+// GetAPITokensByUser récupère les jetons d'accès d'un utilisateur
+func GetAPITokensByUser(userID int) ([]*APIToken, error) {
+	rows, err := DB.Query(`
+		SELECT id, user_id, name, prefix, scopes, created_at, expires_at, last_used_at, revoked_at
+		FROM api_tokens
+		WHERE user_id = ?
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]*APIToken, 0)
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// RevokeAPIToken révoque un jeton d'accès appartenant à l'utilisateur donné
+func RevokeAPIToken(userID, tokenID int) error {
+	result, err := DB.Exec(
+		"UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL",
+		time.Now(), tokenID, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New("jeton d'accès non trouvé")
+	}
+	return nil
+}
@@ -0,0 +1,319 @@
+// fichier: database/attachments.go
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"realtimeforum/storage"
+	"strings"
+	"time"
+)
+
+// Nombre maximal de pièces jointes par publication ou par message
+const MaxAttachmentsPerItem = 10
+
+// ErrAttachmentQuotaExceeded est retournée lorsque l'envoi dépasserait le quota de l'utilisateur
+var ErrAttachmentQuotaExceeded = errors.New("quota de pièces jointes dépassé")
+
+// ErrAttachmentUnavailable est retournée lorsqu'une pièce jointe n'existe pas,
+// appartient à un autre utilisateur ou est déjà rattachée ailleurs
+var ErrAttachmentUnavailable = errors.New("pièce jointe introuvable ou déjà utilisée")
+
+// ErrTooManyAttachments est retournée au-delà de MaxAttachmentsPerItem pièces jointes
+var ErrTooManyAttachments = fmt.Errorf("%d pièces jointes au maximum", MaxAttachmentsPerItem)
+
+// Colonnes lues par scanAttachment
+const attachmentColumns = `id, user_id, storage_key, thumbnail_key, filename, content_type, size, width, height,
+	post_id, message_id, created_at`
+
+// scanAttachment lit une ligne de la table attachments et renseigne ses URLs de téléchargement
+func scanAttachment(scanner interface{ Scan(...interface{}) error }) (*Attachment, error) {
+	attachment := &Attachment{}
+	var thumbnailKey sql.NullString
+	var postID, messageID sql.NullInt64
+
+	err := scanner.Scan(&attachment.ID, &attachment.UserID, &attachment.StorageKey, &thumbnailKey,
+		&attachment.Filename, &attachment.ContentType, &attachment.Size, &attachment.Width, &attachment.Height,
+		&postID, &messageID, &attachment.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment.URL = fmt.Sprintf("/api/attachments/%d", attachment.ID)
+	if thumbnailKey.Valid {
+		attachment.ThumbnailKey = thumbnailKey.String
+		attachment.ThumbnailURL = attachment.URL + "/thumbnail"
+	}
+	if postID.Valid {
+		id := int(postID.Int64)
+		attachment.PostID = &id
+	}
+	if messageID.Valid {
+		id := int(messageID.Int64)
+		attachment.MessageID = &id
+	}
+
+	return attachment, nil
+}
+
+// NewAttachmentKey génère une clé de stockage pour une nouvelle pièce jointe d'un utilisateur
+func NewAttachmentKey(userID int) (string, error) {
+	token, err := generateToken(16)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("attachments/%d/%s", userID, token), nil
+}
+
+// CreateAttachment enregistre les métadonnées d'une pièce jointe déjà stockée.
+// Le quota est vérifié dans la même requête que l'insertion, pour que deux envois
+// simultanés ne puissent pas le dépasser ensemble.
+func CreateAttachment(attachment *Attachment, quota int64) (*Attachment, error) {
+	var thumbnailKey interface{}
+	if attachment.ThumbnailKey != "" {
+		thumbnailKey = attachment.ThumbnailKey
+	}
+
+	result, err := DB.Exec(`
+		INSERT INTO attachments (user_id, storage_key, thumbnail_key, filename, content_type, size, width, height)
+		SELECT ?, ?, ?, ?, ?, ?, ?, ?
+		WHERE (SELECT COALESCE(SUM(size), 0) FROM attachments WHERE user_id = ?) + ? <= ?
+	`, attachment.UserID, attachment.StorageKey, thumbnailKey, attachment.Filename, attachment.ContentType,
+		attachment.Size, attachment.Width, attachment.Height,
+		attachment.UserID, attachment.Size, quota)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if rows == 0 {
+		return nil, ErrAttachmentQuotaExceeded
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return GetAttachmentByID(int(id))
+}
+
+// GetAttachmentByID récupère une pièce jointe par son ID
+func GetAttachmentByID(id int) (*Attachment, error) {
+	attachment, err := scanAttachment(DB.QueryRow("SELECT "+attachmentColumns+" FROM attachments WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("pièce jointe non trouvée")
+	}
+	return attachment, err
+}
+
+// GetAttachmentUsage retourne l'espace occupé par les pièces jointes d'un utilisateur, en octets
+func GetAttachmentUsage(userID int) (int64, error) {
+	var used int64
+	err := DB.QueryRow("SELECT COALESCE(SUM(size), 0) FROM attachments WHERE user_id = ?", userID).Scan(&used)
+	return used, err
+}
+
+// CanViewAttachment indique si un utilisateur (0 pour un visiteur anonyme) peut télécharger une pièce jointe :
+// celles des publications visibles sont publiques, celles des messages privés visibles réservées aux deux participants,
+// et celles qui ne sont pas encore rattachées à leur propriétaire
+func CanViewAttachment(attachment *Attachment, userID int) (bool, error) {
+	switch {
+	case attachment.PostID != nil:
+		var count int
+		err := DB.QueryRow("SELECT COUNT(*) FROM posts WHERE id = ? AND hidden = FALSE", *attachment.PostID).Scan(&count)
+		return count > 0, err
+	case attachment.MessageID != nil:
+		if userID == 0 {
+			return false, nil
+		}
+		var count int
+		err := DB.QueryRow(
+			"SELECT COUNT(*) FROM private_messages WHERE id = ? AND hidden = FALSE AND (sender_id = ? OR receiver_id = ?)",
+			*attachment.MessageID, userID, userID,
+		).Scan(&count)
+		return count > 0, err
+	default:
+		return userID != 0 && attachment.UserID == userID, nil
+	}
+}
+
+// DeleteUnattachedAttachment supprime une pièce jointe de l'utilisateur qui n'est encore rattachée à rien
+// et retourne ses métadonnées pour permettre la suppression des fichiers
+func DeleteUnattachedAttachment(userID, id int) (*Attachment, error) {
+	attachment, err := scanAttachment(DB.QueryRow(`
+		DELETE FROM attachments
+		WHERE id = ? AND user_id = ? AND post_id IS NULL AND message_id IS NULL
+		RETURNING `+attachmentColumns, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrAttachmentUnavailable
+	}
+	return attachment, err
+}
+
+// DeleteOrphanAttachments supprime les pièces jointes jamais rattachées envoyées avant la date donnée,
+// ainsi que leurs fichiers
+func DeleteOrphanAttachments(before time.Time) (int64, error) {
+	rows, err := DB.Query(`
+		DELETE FROM attachments
+		WHERE post_id IS NULL AND message_id IS NULL AND created_at < ?
+		RETURNING `+attachmentColumns, before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var deleted int64
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return deleted, err
+		}
+		DeleteAttachmentFiles(attachment)
+		deleted++
+	}
+
+	return deleted, rows.Err()
+}
+
+// DeleteAttachmentFiles supprime du stockage le fichier d'une pièce jointe et sa miniature
+func DeleteAttachmentFiles(attachment *Attachment) {
+	for _, key := range []string{attachment.StorageKey, attachment.ThumbnailKey} {
+		if key == "" {
+			continue
+		}
+		if err := storage.Default.Delete(key); err != nil {
+			log.Printf("Erreur lors de la suppression du fichier %s: %v", key, err)
+		}
+	}
+}
+
+// linkAttachments rattache les pièces jointes données à une publication ou à un message (colonne column),
+// dans la transaction de création de celui-ci. Chaque pièce jointe doit appartenir à l'utilisateur
+// et n'être rattachée à rien d'autre.
+func linkAttachments(tx *sql.Tx, column string, itemID, userID int, ids []int) error {
+	ids = uniqueIDs(ids)
+	if len(ids) == 0 {
+		return nil
+	}
+	if len(ids) > MaxAttachmentsPerItem {
+		return ErrTooManyAttachments
+	}
+
+	args := []interface{}{itemID, userID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	result, err := tx.Exec(`
+		UPDATE attachments SET `+column+` = ?
+		WHERE user_id = ? AND post_id IS NULL AND message_id IS NULL AND id IN (`+placeholders(len(ids))+`)
+	`, args...)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != int64(len(ids)) {
+		return ErrAttachmentUnavailable
+	}
+	return nil
+}
+
+// loadAttachments récupère les pièces jointes rattachées aux éléments donnés, indexées par ID d'élément
+func loadAttachments(column string, itemIDs []int) (map[int][]*Attachment, error) {
+	byItem := make(map[int][]*Attachment)
+	if len(itemIDs) == 0 {
+		return byItem, nil
+	}
+
+	args := make([]interface{}, len(itemIDs))
+	for i, id := range itemIDs {
+		args[i] = id
+	}
+
+	rows, err := DB.Query(`
+		SELECT `+attachmentColumns+` FROM attachments
+		WHERE `+column+` IN (`+placeholders(len(itemIDs))+`)
+		ORDER BY id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		var itemID int
+		if column == "post_id" {
+			itemID = *attachment.PostID
+		} else {
+			itemID = *attachment.MessageID
+		}
+		byItem[itemID] = append(byItem[itemID], attachment)
+	}
+
+	return byItem, rows.Err()
+}
+
+// withPostAttachments renseigne les pièces jointes des publications données
+func withPostAttachments(posts []*Post) error {
+	ids := make([]int, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	byPost, err := loadAttachments("post_id", ids)
+	if err != nil {
+		return err
+	}
+	for _, post := range posts {
+		post.Attachments = byPost[post.ID]
+	}
+	return nil
+}
+
+// withMessageAttachments renseigne les pièces jointes des messages privés donnés
+func withMessageAttachments(messages []*PrivateMessage) error {
+	ids := make([]int, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID
+	}
+
+	byMessage, err := loadAttachments("message_id", ids)
+	if err != nil {
+		return err
+	}
+	for _, message := range messages {
+		message.Attachments = byMessage[message.ID]
+	}
+	return nil
+}
+
+// uniqueIDs retire les doublons d'une liste d'identifiants en conservant l'ordre
+func uniqueIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	unique := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// placeholders retourne n marqueurs "?" séparés par des virgules, pour une clause IN
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,103 @@
+// fichier: database/audit.go
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+)
+
+// RecordAudit ajoute une entrée au journal d'audit
+func RecordAudit(entry *AuditEntry) error {
+	details := "{}"
+	if len(entry.Details) > 0 {
+		encoded, err := json.Marshal(entry.Details)
+		if err != nil {
+			return err
+		}
+		details = string(encoded)
+	}
+
+	_, err := DB.Exec(
+		"INSERT INTO audit_log (action, actor_id, target_type, target_id, ip, details) VALUES (?, ?, ?, ?, ?, ?)",
+		entry.Action, entry.ActorID, entry.TargetType, entry.TargetID, entry.IP, details,
+	)
+	return err
+}
+
+// GetAuditLog recherche dans le journal d'audit, de l'entrée la plus récente à la plus ancienne
+func GetAuditLog(filter AuditFilter, limit, offset int) ([]*AuditEntry, error) {
+	query := `
+		SELECT a.id, a.action, a.actor_id, COALESCE(u.username, ''), a.target_type, a.target_id, a.ip, a.details, a.created_at
+		FROM audit_log a
+		LEFT JOIN users u ON u.id = a.actor_id
+		WHERE 1 = 1`
+	args := make([]interface{}, 0)
+	if filter.Action != "" {
+		if prefix, ok := strings.CutSuffix(filter.Action, ".*"); ok {
+			query += " AND a.action LIKE ? ESCAPE '\\'"
+			args = append(args, escapeLike(prefix)+".%")
+		} else {
+			query += " AND a.action = ?"
+			args = append(args, filter.Action)
+		}
+	}
+	if filter.ActorID != 0 {
+		query += " AND a.actor_id = ?"
+		args = append(args, filter.ActorID)
+	}
+	if filter.TargetType != "" {
+		query += " AND a.target_type = ?"
+		args = append(args, filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		query += " AND a.target_id = ?"
+		args = append(args, filter.TargetID)
+	}
+	if filter.IP != "" {
+		query += " AND a.ip = ?"
+		args = append(args, filter.IP)
+	}
+	if !filter.Since.IsZero() {
+		query += " AND a.created_at >= ?"
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		query += " AND a.created_at < ?"
+		args = append(args, filter.Until.UTC())
+	}
+	query += " ORDER BY a.created_at DESC, a.id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]*AuditEntry, 0)
+	for rows.Next() {
+		entry := &AuditEntry{}
+		var actorID, targetID sql.NullInt64
+		var details string
+		err := rows.Scan(&entry.ID, &entry.Action, &actorID, &entry.Actor, &entry.TargetType, &targetID,
+			&entry.IP, &details, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entry.ActorID = nullIntPtr(actorID)
+		entry.TargetID = nullIntPtr(targetID)
+		if err := json.Unmarshal([]byte(details), &entry.Details); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// escapeLike échappe les caractères spéciaux d'un motif LIKE
+func escapeLike(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(value)
+}
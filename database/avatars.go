@@ -0,0 +1,61 @@
+// fichier: database/avatars.go
+package database
+
+import (
+	"fmt"
+)
+
+// Tailles (en pixels, carrées) dans lesquelles chaque avatar est enregistré
+const (
+	AvatarSize          = 256
+	AvatarThumbnailSize = 64
+)
+
+// AvatarSizes liste toutes les tailles générées pour un avatar
+var AvatarSizes = []int{AvatarSize, AvatarThumbnailSize}
+
+// MediaURLPrefix est le préfixe des URLs servant les objets du stockage
+const MediaURLPrefix = "/media/"
+
+// AvatarKey retourne la clé de stockage d'un avatar dans une taille donnée.
+// La version fait partie de la clé : un nouvel avatar a donc une nouvelle URL
+// et les anciennes peuvent être mises en cache indéfiniment.
+func AvatarKey(userID int, version string, size int) string {
+	return fmt.Sprintf("avatars/%d/%s-%d.png", userID, version, size)
+}
+
+// avatar retourne les URLs de l'avatar de l'utilisateur, ou nil s'il n'en a pas
+func (u *User) avatar() *Avatar {
+	if u.AvatarVersion == "" {
+		return nil
+	}
+	return &Avatar{
+		URL:          MediaURLPrefix + AvatarKey(u.ID, u.AvatarVersion, AvatarSize),
+		ThumbnailURL: MediaURLPrefix + AvatarKey(u.ID, u.AvatarVersion, AvatarThumbnailSize),
+	}
+}
+
+// SetAvatarVersion enregistre la version de l'avatar courant (vide pour le supprimer)
+// et retourne la version précédente, dont les fichiers peuvent alors être supprimés
+func SetAvatarVersion(userID int, version string) (string, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var previous string
+	if err := tx.QueryRow("SELECT avatar_version FROM users WHERE id = ?", userID).Scan(&previous); err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec("UPDATE users SET avatar_version = ? WHERE id = ?", version, userID); err != nil {
+		return "", err
+	}
+
+	return previous, tx.Commit()
+}
+
+// NewAvatarVersion génère une version aléatoire pour un nouvel avatar
+func NewAvatarVersion() (string, error) {
+	return generateToken(8)
+}
@@ -0,0 +1,207 @@
+// fichier: database/bookmarks.go
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// Erreurs des favoris
+var (
+	ErrBookmarkNotFound = errors.New("favori non trouvé")
+	ErrBookmarkExists   = errors.New("ce contenu est déjà dans vos favoris")
+)
+
+// Longueur de l'extrait de contenu affiché dans la liste des favoris
+const bookmarkExcerptLength = 200
+
+// BookmarkFilter restreint la liste des favoris d'un utilisateur (champs nil ignorés)
+type BookmarkFilter struct {
+	TargetType string
+	// Dossier ; une chaîne vide désigne les favoris sans dossier
+	Folder *string
+}
+
+// ==================================
+// Bookmark Operations
+// ==================================
+
+// CreateBookmark enregistre un favori ; le contenu visé doit avoir été vérifié par l'appelant
+func CreateBookmark(bookmark *Bookmark) (int, error) {
+	result, err := DB.Exec(
+		"INSERT INTO bookmarks (user_id, target_type, target_id, note, folder) VALUES (?, ?, ?, ?, ?)",
+		bookmark.UserID, bookmark.TargetType, bookmark.TargetID, bookmark.Note, bookmark.Folder,
+	)
+	if isUniqueViolation(err) {
+		return 0, ErrBookmarkExists
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// UpdateBookmark modifie la note et le dossier d'un favori de l'utilisateur
+func UpdateBookmark(bookmarkID, userID int, note, folder string) error {
+	result, err := DB.Exec(
+		"UPDATE bookmarks SET note = ?, folder = ? WHERE id = ? AND user_id = ?",
+		note, folder, bookmarkID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return ErrBookmarkNotFound
+	}
+	return nil
+}
+
+// DeleteBookmark retire un favori de l'utilisateur
+func DeleteBookmark(bookmarkID, userID int) error {
+	result, err := DB.Exec("DELETE FROM bookmarks WHERE id = ? AND user_id = ?", bookmarkID, userID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return ErrBookmarkNotFound
+	}
+	return nil
+}
+
+// bookmarkSelect sélectionne les favoris avec un aperçu du contenu visé, à lire avec scanBookmark.
+// Un contenu masqué ou supprimé n'est plus joint : le favori est alors signalé indisponible.
+const bookmarkSelect = `
+	SELECT b.id, b.user_id, b.target_type, b.target_id, b.note, b.folder, b.created_at,
+		p.id IS NOT NULL OR c.id IS NOT NULL OR m.id IS NOT NULL,
+		COALESCE(pu.username, cu.username, mu.username, ''),
+		COALESCE(p.title, cp.title, ''),
+		substr(COALESCE(p.content, c.content, m.content, ''), 1, ?),
+		COALESCE(p.id, cp.id, 0)
+	FROM bookmarks b
+	LEFT JOIN posts p ON b.target_type = 'post' AND p.id = b.target_id AND p.hidden = FALSE
+	LEFT JOIN comments c ON b.target_type = 'comment' AND c.id = b.target_id AND c.hidden = FALSE
+	LEFT JOIN posts cp ON cp.id = c.post_id AND cp.hidden = FALSE
+	LEFT JOIN private_messages m ON b.target_type = 'message' AND m.id = b.target_id AND m.hidden = FALSE
+	LEFT JOIN users pu ON pu.id = p.user_id
+	LEFT JOIN users cu ON cu.id = c.user_id
+	LEFT JOIN users mu ON mu.id = m.sender_id`
+
+// scanBookmark lit une ligne sélectionnée avec bookmarkSelect
+func scanBookmark(scanner interface{ Scan(...interface{}) error }) (*Bookmark, error) {
+	bookmark := &Bookmark{Preview: &BookmarkPreview{}}
+	err := scanner.Scan(
+		&bookmark.ID, &bookmark.UserID, &bookmark.TargetType, &bookmark.TargetID,
+		&bookmark.Note, &bookmark.Folder, &bookmark.CreatedAt,
+		&bookmark.Preview.Available, &bookmark.Preview.Author, &bookmark.Preview.Title,
+		&bookmark.Preview.Excerpt, &bookmark.Preview.PostID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return bookmark, nil
+}
+
+// GetBookmarkByID récupère un favori de l'utilisateur avec son aperçu
+func GetBookmarkByID(bookmarkID, userID int) (*Bookmark, error) {
+	bookmark, err := scanBookmark(DB.QueryRow(bookmarkSelect+" WHERE b.id = ? AND b.user_id = ?",
+		bookmarkExcerptLength, bookmarkID, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrBookmarkNotFound
+	}
+	return bookmark, err
+}
+
+// GetBookmarks liste les favoris d'un utilisateur, les plus récents d'abord
+func GetBookmarks(userID int, filter BookmarkFilter, limit, offset int) ([]*Bookmark, error) {
+	query := bookmarkSelect + " WHERE b.user_id = ?"
+	args := []interface{}{bookmarkExcerptLength, userID}
+	if filter.TargetType != "" {
+		query += " AND b.target_type = ?"
+		args = append(args, filter.TargetType)
+	}
+	if filter.Folder != nil {
+		query += " AND b.folder = ?"
+		args = append(args, *filter.Folder)
+	}
+	query += " ORDER BY b.created_at DESC, b.id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookmarks := make([]*Bookmark, 0)
+	for rows.Next() {
+		bookmark, err := scanBookmark(rows)
+		if err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+
+	return bookmarks, rows.Err()
+}
+
+// GetBookmarkFolders liste les dossiers de favoris d'un utilisateur avec leur nombre de favoris
+// (le dossier "" regroupe les favoris sans dossier)
+func GetBookmarkFolders(userID int) ([]*BookmarkFolder, error) {
+	rows, err := DB.Query(`
+		SELECT folder, COUNT(*) FROM bookmarks
+		WHERE user_id = ?
+		GROUP BY folder
+		ORDER BY folder COLLATE NOCASE
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	folders := make([]*BookmarkFolder, 0)
+	for rows.Next() {
+		folder := &BookmarkFolder{}
+		if err := rows.Scan(&folder.Name, &folder.Count); err != nil {
+			return nil, err
+		}
+		folders = append(folders, folder)
+	}
+
+	return folders, rows.Err()
+}
+
+// GetBookmarkStates retourne, par ID de contenu, les favoris d'un utilisateur parmi les contenus donnés
+func GetBookmarkStates(userID int, targetType string, targetIDs []int) (map[int]*Bookmark, error) {
+	states := make(map[int]*Bookmark)
+	if len(targetIDs) == 0 {
+		return states, nil
+	}
+
+	args := []interface{}{userID, targetType}
+	for _, id := range targetIDs {
+		args = append(args, id)
+	}
+
+	rows, err := DB.Query(`
+		SELECT id, target_type, target_id, note, folder, created_at FROM bookmarks
+		WHERE user_id = ? AND target_type = ? AND target_id IN (`+placeholders(len(targetIDs))+`)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		bookmark := &Bookmark{UserID: userID}
+		err := rows.Scan(&bookmark.ID, &bookmark.TargetType, &bookmark.TargetID,
+			&bookmark.Note, &bookmark.Folder, &bookmark.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		states[bookmark.TargetID] = bookmark
+	}
+
+	return states, rows.Err()
+}
@@ -0,0 +1,110 @@
+// fichier: database/bots.go
+package database
+
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Portées accordées aux jetons des comptes bots
+var botScopes = []string{ScopeBot, ScopePostsRead, ScopePostsWrite, ScopeMessagesSend}
+
+// ==================================
+// Bot Operations
+// ==================================
+
+// CreateBot crée un compte bot. Les bots n'ont pas de mot de passe utilisable :
+// ils s'authentifient uniquement avec un jeton délivré par IssueBotToken.
+func CreateBot(req BotRequest) (int, error) {
+	// Mot de passe aléatoire jamais communiqué, pour respecter le schéma
+	random, err := generateToken(32)
+	if err != nil {
+		return 0, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(random), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := DB.Exec(
+		"INSERT INTO users (username, age, gender, first_name, last_name, email, password, user_type) VALUES (?, 0, 'Autre', ?, ?, ?, ?, ?)",
+		req.Username, req.FirstName, req.LastName, req.Username+"@bots.invalid", string(hashedPassword), UserTypeBot,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// GetBots récupère tous les comptes bots
+func GetBots() ([]*User, error) {
+	rows, err := DB.Query("SELECT id FROM users WHERE user_type = ? ORDER BY username", UserTypeBot)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	bots := make([]*User, 0, len(ids))
+	for _, id := range ids {
+		bot, err := GetUserByID(id)
+		if err != nil {
+			return nil, err
+		}
+		bots = append(bots, bot)
+	}
+
+	return bots, nil
+}
+
+// IssueBotToken révoque les jetons existants d'un bot et lui en délivre un nouveau
+func IssueBotToken(botID int) (*APIToken, string, error) {
+	bot, err := GetUserByID(botID)
+	if err != nil {
+		return nil, "", err
+	}
+	if bot.Type != UserTypeBot {
+		return nil, "", errors.New("l'utilisateur n'est pas un bot")
+	}
+
+	_, err = DB.Exec("UPDATE api_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", time.Now(), botID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return CreateAPIToken(botID, APITokenRequest{Name: "bot", Scopes: botScopes})
+}
+
+// IsAPITokenActive indique si un jeton d'accès n'a été ni révoqué ni n'a expiré
+func IsAPITokenActive(tokenID int) (bool, error) {
+	var count int
+	err := DB.QueryRow(
+		"SELECT COUNT(*) FROM api_tokens WHERE id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)",
+		tokenID, time.Now(),
+	).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
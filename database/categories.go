@@ -0,0 +1,191 @@
+// fichier: database/categories.go
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Erreurs de gestion des catégories
+var (
+	ErrCategoryNotFound  = errors.New("catégorie non trouvée")
+	ErrCategoryNameTaken = errors.New("une catégorie porte déjà ce nom")
+	ErrCategoryNotEmpty  = errors.New("la catégorie contient des publications : précisez la catégorie qui les recevra")
+)
+
+// Catégorie courante et ses sous-catégories, pour les statistiques d'une catégorie principale
+const categoryTree = "(SELECT id FROM categories WHERE id = c.id OR parent_id = c.id)"
+
+// categorySelect sélectionne une catégorie avec son nombre de publications visibles et la date
+// (en secondes Unix, 0 si aucune) de sa dernière publication ou de son dernier commentaire visible
+const categorySelect = `
+	SELECT c.id, c.name, c.description, c.icon, c.parent_id, c.position, c.archived,
+		(SELECT COUNT(*) FROM posts p WHERE p.hidden = FALSE AND p.category_id IN ` + categoryTree + `),
+		MAX(
+			COALESCE((SELECT MAX(CAST(strftime('%s', p.created_at) AS INTEGER)) FROM posts p
+				WHERE p.hidden = FALSE AND p.category_id IN ` + categoryTree + `), 0),
+			COALESCE((SELECT MAX(CAST(strftime('%s', cm.created_at) AS INTEGER)) FROM comments cm
+				JOIN posts p ON cm.post_id = p.id
+				WHERE cm.hidden = FALSE AND p.hidden = FALSE AND p.category_id IN ` + categoryTree + `), 0)
+		)
+	FROM categories c`
+
+// ==================================
+// Category Operations
+// ==================================
+
+// scanCategory lit une ligne sélectionnée avec categorySelect
+func scanCategory(scanner interface{ Scan(...interface{}) error }) (*Category, error) {
+	category := &Category{}
+	var parentID sql.NullInt64
+	var lastActivity int64
+
+	err := scanner.Scan(&category.ID, &category.Name, &category.Description, &category.Icon, &parentID,
+		&category.Position, &category.Archived, &category.PostCount, &lastActivity)
+	if err != nil {
+		return nil, err
+	}
+
+	category.ParentID = nullIntPtr(parentID)
+	if lastActivity > 0 {
+		activity := time.Unix(lastActivity, 0).UTC()
+		category.LastActivityAt = &activity
+	}
+
+	return category, nil
+}
+
+// GetAllCategories récupère toutes les catégories, archivées comprises, dans l'ordre d'affichage
+func GetAllCategories() ([]*Category, error) {
+	rows, err := DB.Query(categorySelect + " ORDER BY c.position, c.id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make([]*Category, 0)
+	for rows.Next() {
+		category, err := scanCategory(rows)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+// GetCategoryByID récupère une catégorie par son ID
+func GetCategoryByID(categoryID int) (*Category, error) {
+	category, err := scanCategory(DB.QueryRow(categorySelect+" WHERE c.id = ?", categoryID))
+	if err == sql.ErrNoRows {
+		return nil, ErrCategoryNotFound
+	}
+	return category, err
+}
+
+// HasSubcategories indique si une catégorie a des sous-catégories
+func HasSubcategories(categoryID int) (bool, error) {
+	var exists bool
+	err := DB.QueryRow("SELECT EXISTS(SELECT 1 FROM categories WHERE parent_id = ?)", categoryID).Scan(&exists)
+	return exists, err
+}
+
+// CreateCategory crée une catégorie, placée après les catégories de même niveau
+func CreateCategory(category *Category) (int, error) {
+	result, err := DB.Exec(`
+		INSERT INTO categories (name, description, icon, parent_id, position)
+		VALUES (?, ?, ?, ?, (SELECT COALESCE(MAX(position) + 1, 0) FROM categories WHERE parent_id IS ?))
+	`, category.Name, category.Description, category.Icon, category.ParentID, category.ParentID)
+	if isUniqueViolation(err) {
+		return 0, ErrCategoryNameTaken
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// UpdateCategory enregistre le nom, la description, l'icône, le parent et l'archivage d'une catégorie
+func UpdateCategory(category *Category) error {
+	result, err := DB.Exec(`
+		UPDATE categories SET name = ?, description = ?, icon = ?, parent_id = ?, archived = ?
+		WHERE id = ?
+	`, category.Name, category.Description, category.Icon, category.ParentID, category.Archived, category.ID)
+	if isUniqueViolation(err) {
+		return ErrCategoryNameTaken
+	}
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+// ReorderCategories fixe l'ordre d'affichage des catégories données, dans l'ordre de la liste
+func ReorderCategories(categoryIDs []int) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for position, categoryID := range categoryIDs {
+		result, err := tx.Exec("UPDATE categories SET position = ? WHERE id = ?", position, categoryID)
+		if err != nil {
+			return err
+		}
+		if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+			return ErrCategoryNotFound
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteCategory supprime une catégorie. Ses publications (masquées comprises) sont déplacées
+// vers moveTo ; sans catégorie de destination (0), la suppression est refusée si elle en contient.
+// Ses sous-catégories deviennent des catégories principales. Retourne le nombre de publications déplacées.
+func DeleteCategory(categoryID, moveTo int) (int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var postCount int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM posts WHERE category_id = ?", categoryID).Scan(&postCount); err != nil {
+		return 0, err
+	}
+	if postCount > 0 {
+		if moveTo == 0 {
+			return 0, ErrCategoryNotEmpty
+		}
+		if _, err := tx.Exec("UPDATE posts SET category_id = ? WHERE category_id = ?", moveTo, categoryID); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE categories SET parent_id = NULL WHERE parent_id = ?", categoryID); err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec("DELETE FROM categories WHERE id = ?", categoryID)
+	if err != nil {
+		return 0, err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return 0, ErrCategoryNotFound
+	}
+
+	return postCount, tx.Commit()
+}
@@ -11,7 +11,8 @@ import (
 
 var DB *sql.DB
 
-// Initialize initialise la connexion à la base de données et applique le schéma
+// Initialize initialise la connexion à la base de données et applique le schéma,
+// en mettant à niveau une base créée par une version antérieure
 func Initialize() error {
 	// Vérifier si le fichier de base de données existe déjà
 	_, err := os.Stat("forum.db")
@@ -31,36 +32,34 @@ func Initialize() error {
 
 	DB = db
 
-	// Si c'est une nouvelle base de données, appliquer le schéma
 	if newDB {
 		log.Println("Création d'une nouvelle base de données...")
+	}
 
-		// Déterminer le chemin du fichier schema.sql
-		// Chercher d'abord dans le répertoire courant
-		schemaPath := "schema.sql"
+	// Déterminer le chemin du fichier schema.sql
+	// Chercher d'abord dans le répertoire courant
+	schemaPath := "schema.sql"
+	if _, err := os.Stat(schemaPath); os.IsNotExist(err) {
+		// Essayer dans le répertoire parent
+		schemaPath = filepath.Join("..", "schema.sql")
 		if _, err := os.Stat(schemaPath); os.IsNotExist(err) {
-			// Essayer dans le répertoire parent
-			schemaPath = filepath.Join("..", "schema.sql")
-			if _, err := os.Stat(schemaPath); os.IsNotExist(err) {
-				return err
-			}
-		}
-
-		// Lire le fichier schema.sql
-		schemaBytes, err := os.ReadFile(schemaPath)
-		if err != nil {
 			return err
 		}
+	}
 
-		// Exécuter les requêtes SQL
-		_, err = DB.Exec(string(schemaBytes))
-		if err != nil {
-			return err
-		}
+	// Lire le fichier schema.sql
+	schemaBytes, err := os.ReadFile(schemaPath)
+	if err != nil {
+		return err
+	}
 
-		log.Println("Schéma de base de données initialisé avec succès")
+	// Appliquer le schéma à chaque démarrage : une base existante est mise à niveau
+	if err := migrate(string(schemaBytes)); err != nil {
+		return err
 	}
 
+	log.Println("Schéma de base de données initialisé avec succès")
+
 	return nil
 }
 
@@ -0,0 +1,234 @@
+// fichier: database/drafts.go
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrDraftNotFound est retournée lorsqu'un brouillon n'existe pas, n'appartient pas à l'utilisateur
+// ou a déjà été publié
+var ErrDraftNotFound = errors.New("brouillon non trouvé")
+
+// ==================================
+// Draft Operations
+// ==================================
+
+// draftSelect sélectionne un brouillon, à lire avec scanDraft
+const draftSelect = `
+	SELECT id, user_id, title, content, category_id, tags, poll, publish_at, last_error, created_at, updated_at
+	FROM post_drafts`
+
+// scanDraft lit une ligne sélectionnée avec draftSelect
+func scanDraft(scanner interface{ Scan(...interface{}) error }) (*PostDraft, error) {
+	draft := &PostDraft{}
+	var tags string
+	var poll sql.NullString
+	var publishAt sql.NullTime
+	err := scanner.Scan(
+		&draft.ID, &draft.UserID, &draft.Title, &draft.Content, &draft.CategoryID,
+		&tags, &poll, &publishAt, &draft.LastError, &draft.CreatedAt, &draft.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(tags), &draft.Tags); err != nil {
+		return nil, err
+	}
+	if poll.Valid {
+		draft.Poll = &PollRequest{}
+		if err := json.Unmarshal([]byte(poll.String), draft.Poll); err != nil {
+			return nil, err
+		}
+	}
+	if publishAt.Valid {
+		draft.PublishAt = &publishAt.Time
+	}
+	return draft, nil
+}
+
+// draftColumns encode les champs modifiables d'un brouillon dans l'ordre
+// title, content, category_id, tags, poll, publish_at
+func draftColumns(draft *PostDraft) ([]interface{}, error) {
+	tags := draft.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	encodedTags, err := json.Marshal(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	var poll, publishAt interface{}
+	if draft.Poll != nil {
+		encodedPoll, err := json.Marshal(draft.Poll)
+		if err != nil {
+			return nil, err
+		}
+		poll = string(encodedPoll)
+	}
+	if draft.PublishAt != nil {
+		publishAt = draft.PublishAt.UTC()
+	}
+
+	return []interface{}{draft.Title, draft.Content, draft.CategoryID, string(encodedTags), poll, publishAt}, nil
+}
+
+// CreateDraft enregistre un nouveau brouillon
+func CreateDraft(draft *PostDraft) (int, error) {
+	columns, err := draftColumns(draft)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := DB.Exec(`
+		INSERT INTO post_drafts (title, content, category_id, tags, poll, publish_at, user_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, append(columns, draft.UserID)...)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// UpdateDraft remplace le contenu d'un brouillon de l'utilisateur et efface le dernier échec de publication
+func UpdateDraft(draft *PostDraft) error {
+	columns, err := draftColumns(draft)
+	if err != nil {
+		return err
+	}
+
+	result, err := DB.Exec(`
+		UPDATE post_drafts
+		SET title = ?, content = ?, category_id = ?, tags = ?, poll = ?, publish_at = ?,
+			last_error = '', updated_at = ?
+		WHERE id = ? AND user_id = ?
+	`, append(columns, time.Now().UTC(), draft.ID, draft.UserID)...)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return ErrDraftNotFound
+	}
+	return nil
+}
+
+// GetDraftByID récupère un brouillon de l'utilisateur
+func GetDraftByID(draftID, userID int) (*PostDraft, error) {
+	draft, err := scanDraft(DB.QueryRow(draftSelect+" WHERE id = ? AND user_id = ?", draftID, userID))
+	if err == sql.ErrNoRows {
+		return nil, ErrDraftNotFound
+	}
+	return draft, err
+}
+
+// GetUserDrafts liste les brouillons d'un utilisateur : les publications programmées d'abord,
+// par date de publication, puis les brouillons les plus récemment modifiés
+func GetUserDrafts(userID int) ([]*PostDraft, error) {
+	rows, err := DB.Query(draftSelect+`
+		WHERE user_id = ?
+		ORDER BY publish_at IS NULL, publish_at, updated_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drafts := make([]*PostDraft, 0)
+	for rows.Next() {
+		draft, err := scanDraft(rows)
+		if err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, draft)
+	}
+
+	return drafts, rows.Err()
+}
+
+// DeleteDraft abandonne un brouillon de l'utilisateur
+func DeleteDraft(draftID, userID int) error {
+	result, err := DB.Exec("DELETE FROM post_drafts WHERE id = ? AND user_id = ?", draftID, userID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return ErrDraftNotFound
+	}
+	return nil
+}
+
+// GetDueDrafts retourne les brouillons dont la publication programmée est arrivée à échéance
+func GetDueDrafts(limit int) ([]*PostDraft, error) {
+	rows, err := DB.Query(draftSelect+`
+		WHERE publish_at IS NOT NULL AND publish_at <= ?
+		ORDER BY publish_at
+		LIMIT ?
+	`, time.Now().UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drafts := make([]*PostDraft, 0)
+	for rows.Next() {
+		draft, err := scanDraft(rows)
+		if err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, draft)
+	}
+
+	return drafts, rows.Err()
+}
+
+// PublishDraft publie immédiatement un brouillon de l'utilisateur : la publication est créée
+// et le brouillon supprimé dans la même transaction
+func PublishDraft(draftID, userID int, post *Post) (int, error) {
+	return publishDraftWhere(post, "id = ? AND user_id = ?", draftID, userID)
+}
+
+// PublishDueDraft publie un brouillon programmé, à condition qu'il le soit toujours et soit arrivé
+// à échéance (il a pu être modifié ou déprogrammé entre-temps)
+func PublishDueDraft(draftID int, post *Post) (int, error) {
+	return publishDraftWhere(post, "id = ? AND publish_at IS NOT NULL AND publish_at <= ?",
+		draftID, time.Now().UTC())
+}
+
+// publishDraftWhere supprime le brouillon désigné par la condition et crée la publication
+func publishDraftWhere(post *Post, condition string, args ...interface{}) (int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM post_drafts WHERE "+condition, args...)
+	if err != nil {
+		return 0, err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return 0, ErrDraftNotFound
+	}
+
+	postID, err := createPost(tx, post)
+	if err != nil {
+		return 0, err
+	}
+
+	return postID, tx.Commit()
+}
+
+// FailScheduledDraft déprogramme un brouillon dont la publication a échoué et en conserve le motif
+func FailScheduledDraft(draftID int, reason string) error {
+	_, err := DB.Exec(
+		"UPDATE post_drafts SET publish_at = NULL, last_error = ? WHERE id = ?",
+		reason, draftID,
+	)
+	return err
+}
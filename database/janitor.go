@@ -0,0 +1,80 @@
+// fichier: database/janitor.go
+package database
+
+import (
+	"log"
+	"realtimeforum/config"
+	"time"
+)
+
+// StartJanitor lance en arrière-plan le nettoyage périodique des sessions expirées
+// et des indicateurs de frappe obsolètes
+func StartJanitor(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			runJanitor()
+		}
+	}()
+}
+
+// runJanitor effectue une passe de nettoyage
+func runJanitor() {
+	sessions, err := DeleteExpiredSessions()
+	if err != nil {
+		log.Printf("Erreur lors de la suppression des sessions expirées: %v", err)
+	} else if sessions > 0 {
+		log.Printf("%d session(s) expirée(s) supprimée(s)", sessions)
+	}
+
+	partials, err := DeleteExpiredPartialSessions()
+	if err != nil {
+		log.Printf("Erreur lors de la suppression des sessions partielles expirées: %v", err)
+	} else if partials > 0 {
+		log.Printf("%d session(s) partielle(s) expirée(s) supprimée(s)", partials)
+	}
+
+	tickets, err := DeleteExpiredWSTickets()
+	if err != nil {
+		log.Printf("Erreur lors de la suppression des tickets WebSocket expirés: %v", err)
+	} else if tickets > 0 {
+		log.Printf("%d ticket(s) WebSocket expiré(s) supprimé(s)", tickets)
+	}
+
+	throttles, err := DeleteStaleLoginThrottles()
+	if err != nil {
+		log.Printf("Erreur lors de la suppression des compteurs de connexion obsolètes: %v", err)
+	} else if throttles > 0 {
+		log.Printf("%d compteur(s) de connexion obsolète(s) supprimé(s)", throttles)
+	}
+
+	emailChanges, err := DeleteExpiredEmailChanges()
+	if err != nil {
+		log.Printf("Erreur lors de la suppression des changements d'email expirés: %v", err)
+	} else if emailChanges > 0 {
+		log.Printf("%d changement(s) d'email expiré(s) supprimé(s)", emailChanges)
+	}
+
+	deliveries, err := DeleteOldWebhookDeliveries(time.Now().Add(-config.App.WebhookRetention))
+	if err != nil {
+		log.Printf("Erreur lors de la suppression des anciennes livraisons de webhooks: %v", err)
+	} else if deliveries > 0 {
+		log.Printf("%d livraison(s) de webhook ancienne(s) supprimée(s)", deliveries)
+	}
+
+	attachments, err := DeleteOrphanAttachments(time.Now().Add(-config.App.AttachmentOrphanTTL))
+	if err != nil {
+		log.Printf("Erreur lors de la suppression des pièces jointes inutilisées: %v", err)
+	} else if attachments > 0 {
+		log.Printf("%d pièce(s) jointe(s) inutilisée(s) supprimée(s)", attachments)
+	}
+
+	indicators, err := DeleteStaleTypingIndicators(time.Now().Add(-config.App.TypingIndicatorTTL))
+	if err != nil {
+		log.Printf("Erreur lors de la suppression des indicateurs de frappe obsolètes: %v", err)
+	} else if indicators > 0 {
+		log.Printf("%d indicateur(s) de frappe obsolète(s) supprimé(s)", indicators)
+	}
+}
@@ -0,0 +1,210 @@
+// fichier: database/links.go
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"strconv"
+)
+
+// ErrQuoteSourceNotFound est retournée lorsque le commentaire cité n'existe pas ou a été masqué
+var ErrQuoteSourceNotFound = errors.New("commentaire cité non trouvé")
+
+// Sources des liens internes
+const (
+	LinkSourcePost    = "post"
+	LinkSourceComment = "comment"
+)
+
+// Nombre maximal de liens internes enregistrés par contenu
+const maxContentLinks = 20
+
+// postLinkPattern reconnaît les liens internes vers une publication (#post-123)
+var postLinkPattern = regexp.MustCompile(`#post-(\d+)\b`)
+
+// extractPostLinks retourne les IDs des publications référencées dans un contenu, sans doublon
+func extractPostLinks(content string) []int {
+	seen := make(map[int]bool)
+	ids := make([]int, 0)
+	for _, match := range postLinkPattern.FindAllStringSubmatch(content, -1) {
+		id, err := strconv.Atoi(match[1])
+		if err != nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+		if len(ids) == maxContentLinks {
+			break
+		}
+	}
+	return ids
+}
+
+// linkContent enregistre les liens internes d'un contenu vers les publications visibles qu'il référence,
+// hors la publication excludedPostID (la publication elle-même, ou celle d'un commentaire)
+func linkContent(tx *sql.Tx, sourceType string, sourceID int, content string, excludedPostID int) error {
+	ids := extractPostLinks(content)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := []interface{}{sourceType, sourceID, excludedPostID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	_, err := tx.Exec(`
+		INSERT OR IGNORE INTO content_links (source_type, source_id, target_post_id)
+		SELECT ?, ?, id FROM posts
+		WHERE hidden = FALSE AND id != ? AND id IN (`+placeholders(len(ids))+`)
+	`, args...)
+	return err
+}
+
+// loadContentLinks retourne, par ID de contenu source, les liens internes vers des publications.
+// Un lien vers une publication masquée depuis est conservé mais signalé indisponible.
+func loadContentLinks(sourceType string, sourceIDs []int) (map[int][]*PostLink, error) {
+	links := make(map[int][]*PostLink)
+	if len(sourceIDs) == 0 {
+		return links, nil
+	}
+
+	args := []interface{}{sourceType}
+	for _, id := range sourceIDs {
+		args = append(args, id)
+	}
+
+	rows, err := DB.Query(`
+		SELECT l.source_id, l.target_post_id, COALESCE(p.title, ''), p.id IS NOT NULL
+		FROM content_links l
+		LEFT JOIN posts p ON p.id = l.target_post_id AND p.hidden = FALSE
+		WHERE l.source_type = ? AND l.source_id IN (`+placeholders(len(sourceIDs))+`)
+		ORDER BY l.source_id, l.target_post_id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sourceID int
+		link := &PostLink{}
+		if err := rows.Scan(&sourceID, &link.PostID, &link.Title, &link.Available); err != nil {
+			return nil, err
+		}
+		links[sourceID] = append(links[sourceID], link)
+	}
+
+	return links, rows.Err()
+}
+
+// withPostLinks renseigne les liens internes du contenu des publications données
+func withPostLinks(posts []*Post) error {
+	ids := make([]int, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	links, err := loadContentLinks(LinkSourcePost, ids)
+	if err != nil {
+		return err
+	}
+	for _, post := range posts {
+		post.Links = links[post.ID]
+	}
+	return nil
+}
+
+// withCommentLinks renseigne les liens internes du contenu des commentaires donnés
+func withCommentLinks(comments []*Comment) error {
+	ids := make([]int, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+
+	links, err := loadContentLinks(LinkSourceComment, ids)
+	if err != nil {
+		return err
+	}
+	for _, comment := range comments {
+		comment.Links = links[comment.ID]
+	}
+	return nil
+}
+
+// withPostBacklinks renseigne, pour chaque publication donnée, les discussions visibles qui la référencent,
+// une seule fois par discussion, les plus récentes d'abord
+func withPostBacklinks(posts []*Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	ids := make([]interface{}, len(posts))
+	byID := make(map[int]*Post, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+		byID[post.ID] = post
+	}
+
+	// Une discussion qui référence la publication depuis son contenu est rattachée à celui-ci (commentaire 0),
+	// sinon à son premier commentaire contenant le lien
+	rows, err := DB.Query(`
+		SELECT target_post_id, post_id, title, MIN(comment_id)
+		FROM (
+			SELECT l.target_post_id, sp.id AS post_id, sp.title, 0 AS comment_id
+			FROM content_links l
+			JOIN posts sp ON sp.id = l.source_id AND sp.hidden = FALSE
+			WHERE l.source_type = 'post' AND l.target_post_id IN (`+placeholders(len(ids))+`)
+			UNION ALL
+			SELECT l.target_post_id, sp.id, sp.title, c.id
+			FROM content_links l
+			JOIN comments c ON c.id = l.source_id AND c.hidden = FALSE
+			JOIN posts sp ON sp.id = c.post_id AND sp.hidden = FALSE
+			WHERE l.source_type = 'comment' AND l.target_post_id IN (`+placeholders(len(ids))+`)
+		)
+		GROUP BY target_post_id, post_id
+		ORDER BY target_post_id, post_id DESC
+	`, append(ids, ids...)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var targetID int
+		backlink := &PostBacklink{}
+		if err := rows.Scan(&targetID, &backlink.PostID, &backlink.Title, &backlink.CommentID); err != nil {
+			return err
+		}
+		byID[targetID].Backlinks = append(byID[targetID].Backlinks, backlink)
+	}
+
+	return rows.Err()
+}
+
+// ==================================
+// Quote Operations
+// ==================================
+
+// GetQuoteSource récupère un commentaire visible, sur une publication visible, pouvant être cité
+func GetQuoteSource(commentID int) (*Comment, error) {
+	comment := &Comment{}
+	err := DB.QueryRow(`
+		SELECT c.id, c.post_id, c.user_id, u.username, c.content, c.created_at
+		FROM comments c
+		JOIN users u ON c.user_id = u.id
+		JOIN posts p ON p.id = c.post_id AND p.hidden = FALSE
+		WHERE c.id = ? AND c.hidden = FALSE
+	`, commentID).Scan(
+		&comment.ID, &comment.PostID, &comment.UserID, &comment.Username,
+		&comment.Content, &comment.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrQuoteSourceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
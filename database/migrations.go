@@ -0,0 +1,194 @@
+// fichier: database/migrations.go
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// columnMigration ajoute à une table existante une colonne apparue dans schema.sql après sa création.
+// SQLite n'accepte dans ALTER TABLE ADD COLUMN qu'une valeur par défaut constante :
+// backfill renseigne au besoin les lignes existantes.
+type columnMigration struct {
+	table      string
+	column     string
+	definition string
+	backfill   func(tx *sql.Tx) error
+}
+
+// columnMigrations liste, dans l'ordre, les colonnes ajoutées aux tables depuis leur création.
+// Les nouvelles tables et les index sont créés par schema.sql (CREATE ... IF NOT EXISTS).
+var columnMigrations = []columnMigration{
+	// Expiration glissante : les sessions antérieures, sans dates, sont fermées
+	{table: "sessions", column: "created_at", definition: "TIMESTAMP", backfill: execBackfill("DELETE FROM sessions")},
+	{table: "sessions", column: "last_seen_at", definition: "TIMESTAMP"},
+
+	// Authentification à deux facteurs
+	{table: "users", column: "totp_secret", definition: "TEXT"},
+	{table: "users", column: "totp_enabled", definition: "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{table: "users", column: "totp_last_step", definition: "INTEGER NOT NULL DEFAULT 0"},
+
+	// Rôles (déverrouillage des comptes par un administrateur)
+	{table: "users", column: "role", definition: "TEXT NOT NULL DEFAULT 'user'"},
+
+	// Jetons CSRF : les sessions antérieures, sans jeton, sont fermées
+	{table: "sessions", column: "csrf_token", definition: "TEXT NOT NULL DEFAULT ''", backfill: execBackfill("DELETE FROM sessions")},
+
+	// Comptes bots
+	{table: "users", column: "user_type", definition: "TEXT NOT NULL DEFAULT 'human'"},
+
+	// Profils
+	{table: "users", column: "bio", definition: "TEXT NOT NULL DEFAULT ''"},
+
+	// Confidentialité des profils
+	{table: "users", column: "privacy_real_name", definition: "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{table: "users", column: "privacy_age", definition: "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{table: "users", column: "privacy_gender", definition: "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{table: "users", column: "privacy_email", definition: "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{table: "users", column: "privacy_last_seen", definition: "BOOLEAN NOT NULL DEFAULT TRUE"},
+
+	// Avatars
+	{table: "users", column: "avatar_version", definition: "TEXT NOT NULL DEFAULT ''"},
+
+	// Masquage des contenus modérés
+	{table: "posts", column: "hidden", definition: "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{table: "comments", column: "hidden", definition: "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{table: "private_messages", column: "hidden", definition: "BOOLEAN NOT NULL DEFAULT FALSE"},
+
+	// Bannissement par IP et levée des sanctions
+	{table: "users", column: "last_ip", definition: "TEXT NOT NULL DEFAULT ''"},
+	{table: "sanctions", column: "ip", definition: "TEXT"},
+	{table: "sanctions", column: "revoked_at", definition: "TIMESTAMP"},
+	{table: "sanctions", column: "revoked_by", definition: "INTEGER"},
+
+	// Icônes, hiérarchie, ordre et archivage des catégories
+	{table: "categories", column: "icon", definition: "TEXT NOT NULL DEFAULT ''"},
+	{table: "categories", column: "parent_id", definition: "INTEGER"},
+	{table: "categories", column: "position", definition: "INTEGER NOT NULL DEFAULT 0", backfill: execBackfill("UPDATE categories SET position = id")},
+	{table: "categories", column: "archived", definition: "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{table: "categories", column: "created_at", definition: "TIMESTAMP", backfill: execBackfill("UPDATE categories SET created_at = CURRENT_TIMESTAMP")},
+
+	// Épinglage, verrouillage et annonces
+	{table: "posts", column: "pinned", definition: "TEXT NOT NULL DEFAULT ''"},
+	{table: "posts", column: "pinned_at", definition: "TIMESTAMP"},
+	{table: "posts", column: "locked", definition: "BOOLEAN NOT NULL DEFAULT FALSE"},
+	{table: "posts", column: "announcement", definition: "BOOLEAN NOT NULL DEFAULT FALSE"},
+
+	// Compteur de vues des publications
+	{table: "posts", column: "view_count", definition: "INTEGER NOT NULL DEFAULT 0"},
+
+	// Votes et tris des publications
+	{table: "posts", column: "score", definition: "INTEGER NOT NULL DEFAULT 0"},
+	{table: "posts", column: "hot_rank", definition: "REAL NOT NULL DEFAULT 0", backfill: backfillHotRanks},
+	{table: "posts", column: "last_activity_at", definition: "TIMESTAMP", backfill: execBackfill(`
+		UPDATE posts SET last_activity_at = COALESCE(
+			(SELECT MAX(created_at) FROM comments WHERE comments.post_id = posts.id), created_at
+		)
+	`)},
+
+	// Citations des commentaires
+	{table: "comments", column: "quote_comment_id", definition: "INTEGER"},
+	{table: "comments", column: "quote_excerpt", definition: "TEXT NOT NULL DEFAULT ''"},
+}
+
+// migrate met à niveau une base existante : ajoute les colonnes manquantes puis applique le schéma,
+// qui crée les tables, index et déclencheurs absents. Chaque étape est idempotente.
+func migrate(schema string) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, migration := range columnMigrations {
+		columns, err := tableColumns(tx, migration.table)
+		if err != nil {
+			return err
+		}
+		// Une table absente sera créée complète par le schéma
+		if len(columns) == 0 || columns[migration.column] {
+			continue
+		}
+
+		log.Printf("Migration: ajout de la colonne %s.%s", migration.table, migration.column)
+		if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s",
+			migration.table, migration.column, migration.definition)); err != nil {
+			return fmt.Errorf("ajout de la colonne %s.%s: %w", migration.table, migration.column, err)
+		}
+		if migration.backfill != nil {
+			if err := migration.backfill(tx); err != nil {
+				return fmt.Errorf("mise à niveau de la colonne %s.%s: %w", migration.table, migration.column, err)
+			}
+		}
+	}
+
+	if _, err := tx.Exec(schema); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// tableColumns retourne les colonnes d'une table (aucune si la table n'existe pas)
+func tableColumns(tx *sql.Tx, table string) (map[string]bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, kind       string
+			defaultValue     sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &kind, &notNull, &defaultValue, &pk); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+
+	return columns, rows.Err()
+}
+
+// execBackfill retourne une mise à niveau exécutant une requête SQL
+func execBackfill(query string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(query)
+		return err
+	}
+}
+
+// backfillHotRanks calcule le rang « hot » des publications existantes à partir de leur score
+func backfillHotRanks(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT id, score, created_at FROM posts")
+	if err != nil {
+		return err
+	}
+
+	ranks := make(map[int]float64)
+	for rows.Next() {
+		var id, score int
+		var createdAt time.Time
+		if err := rows.Scan(&id, &score, &createdAt); err != nil {
+			rows.Close()
+			return err
+		}
+		ranks[id] = hotRank(score, createdAt)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, rank := range ranks {
+		if _, err := tx.Exec("UPDATE posts SET hot_rank = ? WHERE id = ?", rank, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,21 +3,95 @@ package database
 
 import "time"
 
-// User représente un utilisateur du forum
+// User représente un utilisateur du forum tel que stocké en base.
+// Ne jamais le sérialiser directement : passer par Public ou Private (voir projections.go).
 type User struct {
-	ID        int       `json:"id"`
-	Username  string    `json:"username"`
-	Age       int       `json:"age"`
-	Gender    string    `json:"gender"`
-	FirstName string    `json:"firstName"`
-	LastName  string    `json:"lastName"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"` // Ne pas exposer le mot de passe
-	CreatedAt time.Time `json:"createdAt"`
-	LastLogin time.Time `json:"lastLogin,omitempty"`
-	Online    bool      `json:"online"`
+	ID        int        `json:"id"`
+	Username  string     `json:"username"`
+	Age       int        `json:"age"`
+	Gender    string     `json:"gender"`
+	FirstName string     `json:"firstName"`
+	LastName  string     `json:"lastName"`
+	Email     string     `json:"email"`
+	Password  string     `json:"-"` // Ne pas exposer le mot de passe
+	CreatedAt time.Time  `json:"createdAt"`
+	LastLogin *time.Time `json:"lastLogin,omitempty"`
+	Online    bool       `json:"online"`
+	// Indique si l'authentification à deux facteurs (TOTP) est activée
+	TwoFactorEnabled bool `json:"twoFactorEnabled"`
+	// Rôle de l'utilisateur (user, moderator ou admin)
+	Role string `json:"role"`
+	// Type du compte : humain ou bot
+	Type string `json:"type"`
+	// Courte présentation affichée sur le profil public
+	Bio string `json:"bio"`
+	// Champs que l'utilisateur accepte de rendre publics
+	Privacy PrivacySettings `json:"privacy"`
+	// Version de l'avatar courant, vide si l'utilisateur n'en a pas
+	AvatarVersion string `json:"-"`
 }
 
+// PrivacySettings indique, champ par champ, ce qu'un utilisateur rend visible aux autres
+type PrivacySettings struct {
+	ShowRealName bool `json:"showRealName"`
+	ShowAge      bool `json:"showAge"`
+	ShowGender   bool `json:"showGender"`
+	ShowEmail    bool `json:"showEmail"`
+	ShowLastSeen bool `json:"showLastSeen"`
+}
+
+// PublicUser est la projection d'un utilisateur visible par les autres utilisateurs.
+// Les champs personnels ne sont renseignés que si les paramètres de confidentialité le permettent.
+type PublicUser struct {
+	ID        int        `json:"id"`
+	Username  string     `json:"username"`
+	Type      string     `json:"type"`
+	Role      string     `json:"role"`
+	Bio       string     `json:"bio"`
+	Online    bool       `json:"online"`
+	CreatedAt time.Time  `json:"createdAt"`
+	FirstName string     `json:"firstName,omitempty"`
+	LastName  string     `json:"lastName,omitempty"`
+	Age       int        `json:"age,omitempty"`
+	Gender    string     `json:"gender,omitempty"`
+	Email     string     `json:"email,omitempty"`
+	LastSeen  *time.Time `json:"lastSeen,omitempty"`
+	Avatar    *Avatar    `json:"avatar,omitempty"`
+}
+
+// PrivateUser est la projection d'un utilisateur destinée à lui-même (ou à un administrateur)
+type PrivateUser struct {
+	ID               int             `json:"id"`
+	Username         string          `json:"username"`
+	Age              int             `json:"age"`
+	Gender           string          `json:"gender"`
+	FirstName        string          `json:"firstName"`
+	LastName         string          `json:"lastName"`
+	Email            string          `json:"email"`
+	CreatedAt        time.Time       `json:"createdAt"`
+	LastLogin        *time.Time      `json:"lastLogin,omitempty"`
+	Online           bool            `json:"online"`
+	TwoFactorEnabled bool            `json:"twoFactorEnabled"`
+	Role             string          `json:"role"`
+	Type             string          `json:"type"`
+	Bio              string          `json:"bio"`
+	Privacy          PrivacySettings `json:"privacy"`
+	Avatar           *Avatar         `json:"avatar,omitempty"`
+}
+
+// Avatar contient les URLs des différentes tailles de l'avatar d'un utilisateur
+type Avatar struct {
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnailUrl"`
+}
+
+// Rôles possibles pour un utilisateur
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 // UserDTO est utilisé pour l'inscription et la connexion
 type UserDTO struct {
 	Username  string `json:"username"`
@@ -35,18 +109,392 @@ type LoginRequest struct {
 	Password   string `json:"password"`
 }
 
-// Session représente une session utilisateur
-type Session struct {
+// Types de compte
+const (
+	UserTypeHuman = "human"
+	UserTypeBot   = "bot"
+)
+
+// Relations qu'un utilisateur peut établir envers un autre
+const (
+	// Le blocage interdit les messages privés dans les deux sens et masque présence et frappe
+	RelationBlock = "block"
+	// La sourdine masque les publications et commentaires de l'utilisateur visé
+	RelationMute = "mute"
+)
+
+// Types de contenu pouvant être signalés
+const (
+	ReportTargetPost    = "post"
+	ReportTargetComment = "comment"
+	ReportTargetMessage = "message"
+)
+
+// ReportReasons liste les motifs de signalement acceptés
+var ReportReasons = []string{"spam", "harassment", "hate", "violence", "sexual", "misinformation", "other"}
+
+// Statuts d'un signalement
+const (
+	ReportOpen      = "open"
+	ReportClaimed   = "claimed"
+	ReportResolved  = "resolved"
+	ReportDismissed = "dismissed"
+)
+
+// Actions pouvant accompagner la résolution d'un signalement
+const (
+	ModerationHide    = "hide"
+	ModerationWarn    = "warn"
+	ModerationSuspend = "suspend"
+)
+
+// Types de sanction
+const (
+	SanctionWarning    = "warning"
+	SanctionSuspension = "suspension"
+	// Bannissement définitif, éventuellement étendu à l'adresse IP
+	SanctionBan = "ban"
+	// Lecture seule : l'utilisateur ne peut plus publier, commenter ni envoyer de messages
+	SanctionMute = "mute"
+)
+
+// ProfileUpdateRequest représente une modification partielle du profil (champs absents inchangés)
+type ProfileUpdateRequest struct {
+	FirstName *string `json:"firstName"`
+	LastName  *string `json:"lastName"`
+	Age       *int    `json:"age"`
+	Gender    *string `json:"gender"`
+	Bio       *string `json:"bio"`
+}
+
+// PasswordChangeRequest représente une demande de changement de mot de passe
+type PasswordChangeRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+// EmailChangeRequest représente une demande de changement d'adresse email
+type EmailChangeRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// PublicProfile représente le profil public d'un utilisateur, sans données privées
+type PublicProfile struct {
+	*PublicUser
+	PostCount    int     `json:"postCount"`
+	CommentCount int     `json:"commentCount"`
+	RecentPosts  []*Post `json:"recentPosts"`
+}
+
+// APIToken représente un jeton d'accès personnel utilisé par les scripts et intégrations
+type APIToken struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"userId"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // Début du jeton, pour l'identifier dans la liste
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+// HasScope indique si le jeton dispose de la portée donnée
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APITokenRequest représente une demande de création de jeton d'accès
+type APITokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expiresInDays,omitempty"` // 0 = pas d'expiration
+}
+
+// Portées disponibles pour les jetons d'accès personnels
+const (
+	ScopePostsRead    = "posts:read"
+	ScopePostsWrite   = "posts:write"
+	ScopeMessagesSend = "messages:send"
+	// ScopeBot permet la connexion WebSocket d'un compte bot ; réservée aux jetons des bots
+	ScopeBot = "bot"
+)
+
+// BotRequest représente une demande de création de compte bot
+type BotRequest struct {
+	Username  string `json:"username"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+}
+
+// ValidScopes liste les portées acceptées à la création d'un jeton
+var ValidScopes = []string{ScopePostsRead, ScopePostsWrite, ScopeMessagesSend}
+
+// Webhook représente un webhook sortant notifié lors d'événements du forum
+type Webhook struct {
+	ID        int       `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"` // Clé HMAC, retournée uniquement à la création
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedBy int       `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// WebhookRequest représente une demande de création de webhook
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret,omitempty"` // Généré si absent
+}
+
+// WebhookDelivery représente une livraison de webhook (file et journal)
+type WebhookDelivery struct {
+	ID             int        `json:"id"`
+	WebhookID      int        `json:"webhookId"`
+	Event          string     `json:"event"`
+	Payload        string     `json:"payload"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"nextAttemptAt"`
+	LastAttemptAt  *time.Time `json:"lastAttemptAt,omitempty"`
+	ResponseStatus *int       `json:"responseStatus,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+	RedeliveryOf   *int       `json:"redeliveryOf,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+// Événements pouvant déclencher un webhook
+const (
+	EventPostCreated    = "post.created"
+	EventCommentCreated = "comment.created"
+	EventUserRegistered = "user.registered"
+)
+
+// WebhookEvents liste les événements acceptés à la création d'un webhook
+var WebhookEvents = []string{EventPostCreated, EventCommentCreated, EventUserRegistered}
+
+// États d'une livraison de webhook
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Report représente le signalement d'un contenu par un utilisateur
+type Report struct {
+	ID              int        `json:"id"`
+	ReporterID      int        `json:"reporterId"`
+	Reporter        string     `json:"reporter,omitempty"` // Pour l'affichage
+	TargetType      string     `json:"targetType"`
+	TargetID        int        `json:"targetId"`
+	TargetUserID    int        `json:"targetUserId"`
+	TargetUsername  string     `json:"targetUsername,omitempty"` // Pour l'affichage
+	ContentSnapshot string     `json:"contentSnapshot"`
+	Reason          string     `json:"reason"`
+	Details         string     `json:"details"`
+	Status          string     `json:"status"`
+	ModeratorID     *int       `json:"moderatorId,omitempty"`
+	Actions         []string   `json:"actions,omitempty"`
+	ResolutionNote  string     `json:"resolutionNote,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+	ResolvedAt      *time.Time `json:"resolvedAt,omitempty"`
+}
+
+// ReportRequest représente la demande de signalement d'un contenu
+type ReportRequest struct {
+	TargetType string `json:"targetType"`
+	TargetID   int    `json:"targetId"`
+	Reason     string `json:"reason"`
+	Details    string `json:"details"`
+}
+
+// ResolveReportRequest représente la résolution d'un signalement et les actions à appliquer
+type ResolveReportRequest struct {
+	Actions []string `json:"actions"`
+	Note    string   `json:"note"`
+	// Durée de la suspension en heures (action "suspend")
+	SuspensionHours int `json:"suspensionHours"`
+}
+
+// Sanction représente une sanction prononcée contre un utilisateur
+type Sanction struct {
+	ID          int        `json:"id"`
+	UserID      int        `json:"userId"`
+	Kind        string     `json:"kind"`
+	Reason      string     `json:"reason"`
+	ModeratorID *int       `json:"moderatorId,omitempty"`
+	ReportID    *int       `json:"reportId,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	IP          string     `json:"ip,omitempty"`
+	RevokedAt   *time.Time `json:"revokedAt,omitempty"`
+	RevokedBy   *int       `json:"revokedBy,omitempty"`
+}
+
+// SanctionRequest représente une sanction prononcée directement par un modérateur
+type SanctionRequest struct {
+	Kind   string `json:"kind"`
+	Reason string `json:"reason"`
+	// Durée en heures (suspension obligatoirement, mise en lecture seule facultativement)
+	DurationHours int `json:"durationHours"`
+	// Bannir aussi la dernière adresse IP connue de l'utilisateur
+	BanIP bool `json:"banIp"`
+}
+
+// SanctionStatus regroupe les sanctions en cours d'un utilisateur
+type SanctionStatus struct {
+	// Suspension ou bannissement empêchant tout accès
+	Suspension *Sanction
+	// Mise en lecture seule
+	Mute *Sanction
+}
+
+// WSTicket représente un ticket d'authentification WebSocket à usage unique
+type WSTicket struct {
+	ID        string    `json:"ticket"`
+	SessionID string    `json:"-"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// TwoFactorLoginRequest représente la seconde étape d'une connexion avec 2FA
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challengeToken"`         // ID de la session partielle
+	Code           string `json:"code,omitempty"`         // Code TOTP
+	RecoveryCode   string `json:"recoveryCode,omitempty"` // Code de récupération à usage unique
+}
+
+// PartialSession représente une connexion dont le mot de passe a été vérifié
+// mais dont le second facteur n'a pas encore été fourni
+type PartialSession struct {
 	ID        string    `json:"id"`
 	UserID    int       `json:"userId"`
+	Attempts  int       `json:"-"`
 	ExpiresAt time.Time `json:"expiresAt"`
 }
 
+// LoginThrottle représente l'état de limitation des tentatives de connexion pour une clé
+type LoginThrottle struct {
+	Key           string     `json:"key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"lastFailureAt"`
+	LockedUntil   *time.Time `json:"lockedUntil,omitempty"`
+}
+
+// LoginFailure représente une tentative de connexion échouée enregistrée dans le journal
+type LoginFailure struct {
+	ID         int       `json:"id"`
+	Identifier string    `json:"identifier"`
+	UserID     *int      `json:"userId,omitempty"`
+	IP         string    `json:"ip"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Actions enregistrées dans le journal d'audit
+const (
+	AuditLogin             = "auth.login"
+	AuditLoginFailed       = "auth.login_failed"
+	AuditLogout            = "auth.logout"
+	AuditSessionsRevoked   = "auth.sessions_revoked"
+	AuditTokenCreated      = "auth.token_created"
+	AuditTokenRevoked      = "auth.token_revoked"
+	AuditTwoFactorEnabled  = "auth.2fa_enabled"
+	AuditTwoFactorDisabled = "auth.2fa_disabled"
+	AuditRoleChanged       = "user.role_changed"
+	AuditUserUnlocked      = "user.unlocked"
+	AuditReportClaimed     = "report.claimed"
+	AuditReportResolved    = "report.resolved"
+	AuditReportDismissed   = "report.dismissed"
+	AuditSanctionCreated   = "sanction.created"
+	AuditSanctionRevoked   = "sanction.revoked"
+	AuditContentHidden     = "content.hidden"
+	AuditContentDeleted    = "content.deleted"
+	AuditWebhookDeleted    = "webhook.deleted"
+	AuditCategoryCreated   = "category.created"
+	AuditCategoryUpdated   = "category.updated"
+	AuditCategoryDeleted   = "category.deleted"
+	AuditTagDeleted        = "tag.deleted"
+	AuditPostStateChanged  = "post.state_changed"
+	AuditLogExported       = "audit.exported"
+)
+
+// Types de cible des entrées du journal d'audit (en plus des types de contenu signalables)
+const (
+	AuditTargetUser       = "user"
+	AuditTargetToken      = "token"
+	AuditTargetReport     = "report"
+	AuditTargetSanction   = "sanction"
+	AuditTargetAttachment = "attachment"
+	AuditTargetWebhook    = "webhook"
+	AuditTargetCategory   = "category"
+	AuditTargetTag        = "tag"
+)
+
+// AuditEntry représente une entrée du journal d'audit
+type AuditEntry struct {
+	ID         int                    `json:"id"`
+	Action     string                 `json:"action"`
+	ActorID    *int                   `json:"actorId,omitempty"`
+	Actor      string                 `json:"actor,omitempty"` // Pour l'affichage
+	TargetType string                 `json:"targetType,omitempty"`
+	TargetID   *int                   `json:"targetId,omitempty"`
+	IP         string                 `json:"ip"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	CreatedAt  time.Time              `json:"createdAt"`
+}
+
+// AuditFilter restreint une recherche dans le journal d'audit (champs vides ignorés)
+type AuditFilter struct {
+	// Action exacte, ou famille d'actions avec un préfixe suivi de ".*" (par exemple "report.*")
+	Action     string
+	ActorID    int
+	TargetType string
+	TargetID   int
+	IP         string
+	Since      time.Time
+	Until      time.Time
+}
+
+// Session représente une session utilisateur
+type Session struct {
+	ID         string    `json:"id"`
+	UserID     int       `json:"userId"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	CSRFToken  string    `json:"-"` // Jeton anti-CSRF associé à la session
+}
+
 // Category représente une catégorie de publication
 type Category struct {
 	ID          int    `json:"id"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	Icon        string `json:"icon,omitempty"`
+	ParentID    *int   `json:"parentId,omitempty"`
+	Position    int    `json:"position"`
+	Archived    bool   `json:"archived"`
+	// Publications visibles et dernière activité (publication ou commentaire),
+	// sous-catégories comprises
+	PostCount      int        `json:"postCount"`
+	LastActivityAt *time.Time `json:"lastActivityAt,omitempty"`
+}
+
+// CategoryRequest décrit la création ou la modification d'une catégorie.
+// En modification, les champs absents restent inchangés ; parentId à 0 en fait une catégorie principale.
+type CategoryRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Icon        *string `json:"icon"`
+	ParentID    *int    `json:"parentId"`
+	Archived    *bool   `json:"archived"`
 }
 
 // Post représente une publication
@@ -60,6 +508,149 @@ type Post struct {
 	Category   string    `json:"category,omitempty"` // Pour l'affichage
 	CreatedAt  time.Time `json:"createdAt"`
 	UpdatedAt  time.Time `json:"updatedAt"`
+	// Pièces jointes à rattacher (à la création) et pièces jointes rattachées
+	AttachmentIDs []int         `json:"attachmentIds,omitempty"`
+	Attachments   []*Attachment `json:"attachments,omitempty"`
+	// Étiquettes normalisées
+	Tags []string `json:"tags,omitempty"`
+	// Sondage à créer avec la publication et sondage rattaché (résultats)
+	NewPoll *PollRequest `json:"newPoll,omitempty"`
+	Poll    *Poll        `json:"poll,omitempty"`
+	// État fixé par la modération
+	Pinned       string `json:"pinned,omitempty"` // PinCategory ou PinGlobal
+	Locked       bool   `json:"locked"`
+	Announcement bool   `json:"announcement"`
+	// Nombre de vues uniques
+	ViewCount int `json:"viewCount"`
+	// Somme des votes, vote de l'utilisateur authentifié (+1, -1 ou absent) et date du dernier commentaire
+	Score          int       `json:"score"`
+	MyVote         int       `json:"myVote,omitempty"`
+	LastActivityAt time.Time `json:"lastActivityAt"`
+	// Liens internes du contenu et discussions qui référencent la publication
+	Links     []*PostLink     `json:"links,omitempty"`
+	Backlinks []*PostBacklink `json:"backlinks,omitempty"`
+	// Favori de l'utilisateur authentifié
+	Bookmark *Bookmark `json:"bookmark,omitempty"`
+	// Suivi de lecture de l'utilisateur authentifié : publication jamais ouverte
+	// et nombre de commentaires d'autres membres non lus
+	New            bool `json:"new,omitempty"`
+	UnreadComments *int `json:"unreadComments,omitempty"`
+}
+
+// PostReadState représente le suivi de lecture d'une publication par un utilisateur
+type PostReadState struct {
+	Read           bool
+	UnreadComments int
+}
+
+// PostDraft représente un brouillon de publication, éventuellement programmé
+type PostDraft struct {
+	ID         int          `json:"id"`
+	UserID     int          `json:"userId"`
+	Title      string       `json:"title"`
+	Content    string       `json:"content"`
+	CategoryID int          `json:"categoryId"`
+	Tags       []string     `json:"tags"`
+	Poll       *PollRequest `json:"poll,omitempty"`
+	// Date de publication programmée ; absente pour un simple brouillon
+	PublishAt *time.Time `json:"publishAt,omitempty"`
+	// Motif du dernier échec de publication programmée
+	LastError string    `json:"lastError,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Portées d'épinglage d'une publication
+const (
+	PinCategory = "category"
+	PinGlobal   = "global"
+)
+
+// PostStateRequest décrit une modification de l'état d'une publication (champs absents inchangés) ;
+// pinned vaut "category", "global" ou "" pour désépingler
+type PostStateRequest struct {
+	Pinned       *string `json:"pinned"`
+	Locked       *bool   `json:"locked"`
+	Announcement *bool   `json:"announcement"`
+}
+
+// PostState est diffusé aux clients lorsque l'état d'une publication change
+type PostState struct {
+	PostID       int    `json:"postId"`
+	CategoryID   int    `json:"categoryId"`
+	Pinned       string `json:"pinned"`
+	Locked       bool   `json:"locked"`
+	Announcement bool   `json:"announcement"`
+}
+
+// PollRequest décrit un sondage créé avec une publication
+type PollRequest struct {
+	Question  string     `json:"question"`
+	Options   []string   `json:"options"`
+	Multiple  bool       `json:"multiple"`
+	Anonymous bool       `json:"anonymous"`
+	ClosesAt  *time.Time `json:"closesAt"`
+}
+
+// Poll représente un sondage et ses résultats
+type Poll struct {
+	ID          int           `json:"id"`
+	PostID      int           `json:"postId"`
+	Question    string        `json:"question"`
+	Multiple    bool          `json:"multiple"`
+	Anonymous   bool          `json:"anonymous"`
+	ClosesAt    *time.Time    `json:"closesAt,omitempty"`
+	Closed      bool          `json:"closed"`
+	TotalVoters int           `json:"totalVoters"`
+	Options     []*PollOption `json:"options"`
+	// Choix de l'utilisateur courant (résultats détaillés uniquement)
+	MyVotes []int `json:"myVotes,omitempty"`
+}
+
+// PollOption représente un choix de sondage et son nombre de voix
+type PollOption struct {
+	ID    int    `json:"id"`
+	Label string `json:"label"`
+	Votes int    `json:"votes"`
+	// Noms des votants, pour les sondages publics (résultats détaillés uniquement)
+	Voters []string `json:"voters,omitempty"`
+}
+
+// PostFilter restreint la liste des publications (champs vides ignorés)
+type PostFilter struct {
+	// Catégorie, sous-catégories comprises
+	CategoryID int
+	// Étiquettes normalisées ; toutes (ET) si MatchAllTags, sinon au moins une (OU)
+	Tags         []string
+	MatchAllTags bool
+	// Tri (PostSortNew par défaut) ; Since limite aux publications créées depuis cette date (zéro : aucune limite)
+	Sort  string
+	Since time.Time
+	// Auteurs dont les publications sont exclues (mis en sourdine ou bloqués par l'appelant)
+	ExcludedAuthors []int
+	// Page des publications non épinglées ; les épinglées s'ajoutent en tête de la première page (Offset nul)
+	Limit  int
+	Offset int
+}
+
+// Modes de tri des publications
+const (
+	// Les plus récentes d'abord
+	PostSortNew = "new"
+	// Dernier commentaire (ou création) le plus récent d'abord
+	PostSortActive = "active"
+	// Meilleur score d'abord
+	PostSortTop = "top"
+	// Rang « hot » : score pondéré par l'ancienneté
+	PostSortHot = "hot"
+)
+
+// Tag représente une étiquette et son nombre de publications visibles
+type Tag struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Curated   bool   `json:"curated"`
+	PostCount int    `json:"postCount"`
 }
 
 // Comment représente un commentaire sur une publication
@@ -70,6 +661,67 @@ type Comment struct {
 	Username  string    `json:"username,omitempty"` // Pour l'affichage
 	Content   string    `json:"content"`
 	CreatedAt time.Time `json:"createdAt"`
+	// Commentaire cité (à la création : commentId et extrait) et liens internes du contenu
+	Quote *CommentQuote `json:"quote,omitempty"`
+	Links []*PostLink   `json:"links,omitempty"`
+	// Favori de l'utilisateur authentifié
+	Bookmark *Bookmark `json:"bookmark,omitempty"`
+}
+
+// CommentQuote représente la citation d'un commentaire. Lorsque le commentaire source a été masqué
+// par la modération, la citation est indisponible et son extrait n'est plus renvoyé.
+type CommentQuote struct {
+	CommentID int    `json:"commentId"`
+	PostID    int    `json:"postId,omitempty"`
+	Author    string `json:"author,omitempty"`
+	Excerpt   string `json:"excerpt"`
+	Available bool   `json:"available"`
+}
+
+// PostLink représente un lien interne (#post-123) vers une publication ; indisponible si elle a été masquée
+type PostLink struct {
+	PostID    int    `json:"postId"`
+	Title     string `json:"title,omitempty"`
+	Available bool   `json:"available"`
+}
+
+// PostBacklink représente une discussion qui référence une publication, depuis son contenu
+// ou depuis l'un de ses commentaires (le premier, le cas échéant)
+type PostBacklink struct {
+	PostID    int    `json:"postId"`
+	Title     string `json:"title"`
+	CommentID int    `json:"commentId,omitempty"`
+}
+
+// Bookmark représente un favori : une publication, un commentaire ou un message privé
+// (types ReportTarget*) enregistré par un utilisateur
+type Bookmark struct {
+	ID         int       `json:"id"`
+	UserID     int       `json:"-"`
+	TargetType string    `json:"targetType"`
+	TargetID   int       `json:"targetId"`
+	Note       string    `json:"note"`
+	Folder     string    `json:"folder"`
+	CreatedAt  time.Time `json:"createdAt"`
+	// Aperçu du contenu enregistré (liste des favoris uniquement) ; Available est faux
+	// si le contenu a été masqué ou supprimé depuis
+	Preview *BookmarkPreview `json:"preview,omitempty"`
+}
+
+// BookmarkPreview résume le contenu d'un favori
+type BookmarkPreview struct {
+	Available bool   `json:"available"`
+	Author    string `json:"author,omitempty"`
+	Title     string `json:"title,omitempty"`
+	Excerpt   string `json:"excerpt,omitempty"`
+	// Publication à ouvrir (pour une publication ou un commentaire)
+	PostID int `json:"postId,omitempty"`
+}
+
+// BookmarkFolder représente un dossier de favoris et son nombre de favoris
+type BookmarkFolder struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
 }
 
 // PrivateMessage représente un message privé entre utilisateurs
@@ -77,11 +729,38 @@ type PrivateMessage struct {
 	ID         int       `json:"id"`
 	SenderID   int       `json:"senderId"`
 	ReceiverID int       `json:"receiverId"`
-	Sender     string    `json:"sender,omitempty"`     // Pour l'affichage
-	Receiver   string    `json:"receiver,omitempty"`   // Pour l'affichage
+	Sender     string    `json:"sender,omitempty"`   // Pour l'affichage
+	Receiver   string    `json:"receiver,omitempty"` // Pour l'affichage
 	Content    string    `json:"content"`
 	Read       bool      `json:"read"`
 	CreatedAt  time.Time `json:"createdAt"`
+	// Pièces jointes à rattacher (à l'envoi) et pièces jointes rattachées
+	AttachmentIDs []int         `json:"attachmentIds,omitempty"`
+	Attachments   []*Attachment `json:"attachments,omitempty"`
+}
+
+// Attachment représente un fichier envoyé, rattaché au plus à une publication ou à un message privé
+type Attachment struct {
+	ID           int       `json:"id"`
+	UserID       int       `json:"userId"`
+	StorageKey   string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	Filename     string    `json:"filename"`
+	ContentType  string    `json:"contentType"`
+	Size         int64     `json:"size"`
+	Width        int       `json:"width,omitempty"`
+	Height       int       `json:"height,omitempty"`
+	PostID       *int      `json:"postId,omitempty"`
+	MessageID    *int      `json:"messageId,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnailUrl,omitempty"`
+}
+
+// AttachmentUsage indique l'espace utilisé par les pièces jointes d'un utilisateur
+type AttachmentUsage struct {
+	Used  int64 `json:"used"`
+	Limit int64 `json:"limit"`
 }
 
 // TypingIndicator représente un indicateur de frappe
@@ -0,0 +1,390 @@
+// fichier: database/moderation.go
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrDuplicateReport est retournée lorsque l'utilisateur a déjà un signalement en cours pour ce contenu
+var ErrDuplicateReport = errors.New("vous avez déjà signalé ce contenu")
+
+// ErrReportTargetNotFound est retournée lorsque le contenu signalé n'existe pas ou n'est pas visible
+var ErrReportTargetNotFound = errors.New("contenu signalé introuvable")
+
+// ErrReportNotPending est retournée pour une action sur un signalement déjà traité
+// ou pris en charge par un autre modérateur
+var ErrReportNotPending = errors.New("signalement déjà traité ou pris en charge par un autre modérateur")
+
+// ReportTarget décrit le contenu visé par un signalement
+type ReportTarget struct {
+	// Auteur du contenu
+	AuthorID int
+	// Destinataire (messages privés uniquement)
+	RecipientID int
+	// Texte du contenu au moment du signalement
+	Content string
+}
+
+// ==================================
+// Report Operations
+// ==================================
+
+// GetReportTarget récupère le contenu visé par un signalement, s'il existe et n'est pas déjà masqué
+func GetReportTarget(targetType string, targetID int) (*ReportTarget, error) {
+	target := &ReportTarget{}
+	var err error
+
+	switch targetType {
+	case ReportTargetPost:
+		var title, content string
+		err = DB.QueryRow("SELECT user_id, title, content FROM posts WHERE id = ? AND hidden = FALSE", targetID).
+			Scan(&target.AuthorID, &title, &content)
+		target.Content = title + "\n\n" + content
+	case ReportTargetComment:
+		err = DB.QueryRow("SELECT user_id, content FROM comments WHERE id = ? AND hidden = FALSE", targetID).
+			Scan(&target.AuthorID, &target.Content)
+	case ReportTargetMessage:
+		err = DB.QueryRow("SELECT sender_id, receiver_id, content FROM private_messages WHERE id = ? AND hidden = FALSE", targetID).
+			Scan(&target.AuthorID, &target.RecipientID, &target.Content)
+	default:
+		return nil, ErrReportTargetNotFound
+	}
+
+	if err == sql.ErrNoRows {
+		return nil, ErrReportTargetNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// CreateReport enregistre un signalement avec une copie du contenu visé
+func CreateReport(reporterID int, request ReportRequest, target *ReportTarget) (*Report, error) {
+	result, err := DB.Exec(`
+		INSERT INTO reports (reporter_id, target_type, target_id, target_user_id, content_snapshot, reason, details)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, reporterID, request.TargetType, request.TargetID, target.AuthorID, target.Content, request.Reason, request.Details)
+	if isUniqueViolation(err) {
+		return nil, ErrDuplicateReport
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return GetReportByID(int(id))
+}
+
+// Colonnes lues par scanReport (requêtes joignant reporter et target_user)
+const reportColumns = `r.id, r.reporter_id, reporter.username, r.target_type, r.target_id, r.target_user_id, target.username,
+	r.content_snapshot, r.reason, r.details, r.status, r.moderator_id, r.actions, r.resolution_note, r.created_at, r.resolved_at`
+
+// Jointures nécessaires à reportColumns
+const reportJoins = `FROM reports r
+	JOIN users reporter ON reporter.id = r.reporter_id
+	JOIN users target ON target.id = r.target_user_id`
+
+// scanReport lit une ligne sélectionnée avec reportColumns
+func scanReport(scanner interface{ Scan(...interface{}) error }) (*Report, error) {
+	report := &Report{}
+	var moderatorID sql.NullInt64
+	var actions string
+	var resolvedAt sql.NullTime
+
+	err := scanner.Scan(&report.ID, &report.ReporterID, &report.Reporter, &report.TargetType, &report.TargetID,
+		&report.TargetUserID, &report.TargetUsername, &report.ContentSnapshot, &report.Reason, &report.Details,
+		&report.Status, &moderatorID, &actions, &report.ResolutionNote, &report.CreatedAt, &resolvedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if moderatorID.Valid {
+		id := int(moderatorID.Int64)
+		report.ModeratorID = &id
+	}
+	if actions != "" {
+		report.Actions = strings.Split(actions, ",")
+	}
+	if resolvedAt.Valid {
+		report.ResolvedAt = &resolvedAt.Time
+	}
+
+	return report, nil
+}
+
+// GetReportByID récupère un signalement par son ID
+func GetReportByID(id int) (*Report, error) {
+	report, err := scanReport(DB.QueryRow("SELECT "+reportColumns+" "+reportJoins+" WHERE r.id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("signalement non trouvé")
+	}
+	return report, err
+}
+
+// GetReports liste les signalements, éventuellement filtrés par statut, du plus ancien au plus récent
+// (la file de modération se traite dans l'ordre d'arrivée)
+func GetReports(status string, limit, offset int) ([]*Report, error) {
+	query := "SELECT " + reportColumns + " " + reportJoins
+	args := []interface{}{}
+	if status != "" {
+		query += " WHERE r.status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY r.created_at ASC, r.id ASC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := make([]*Report, 0)
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, rows.Err()
+}
+
+// ClaimReport attribue un signalement ouvert à un modérateur
+func ClaimReport(reportID, moderatorID int) error {
+	result, err := DB.Exec(
+		"UPDATE reports SET status = ?, moderator_id = ? WHERE id = ? AND status = ?",
+		ReportClaimed, moderatorID, reportID, ReportOpen,
+	)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(result, ErrReportNotPending.Error())
+}
+
+// CloseReport clôt un signalement (résolu ou rejeté) ouvert ou pris en charge par ce modérateur
+func CloseReport(reportID, moderatorID int, status string, actions []string, note string) error {
+	result, err := DB.Exec(`
+		UPDATE reports SET status = ?, moderator_id = ?, actions = ?, resolution_note = ?, resolved_at = ?
+		WHERE id = ? AND (status = ? OR (status = ? AND moderator_id = ?))
+	`, status, moderatorID, strings.Join(actions, ","), note, time.Now().UTC(),
+		reportID, ReportOpen, ReportClaimed, moderatorID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrReportNotPending
+	}
+	return nil
+}
+
+// HideContent masque un contenu signalé : il disparaît des listes et n'est plus accessible
+func HideContent(targetType string, targetID int) error {
+	var table string
+	switch targetType {
+	case ReportTargetPost:
+		table = "posts"
+	case ReportTargetComment:
+		table = "comments"
+	case ReportTargetMessage:
+		table = "private_messages"
+	default:
+		return ErrReportTargetNotFound
+	}
+
+	_, err := DB.Exec("UPDATE "+table+" SET hidden = TRUE WHERE id = ?", targetID)
+	return err
+}
+
+// ==================================
+// Sanction Operations
+// ==================================
+
+// Colonnes lues par scanSanction
+const sanctionColumns = "id, user_id, kind, reason, moderator_id, report_id, created_at, expires_at, ip, revoked_at, revoked_by"
+
+// scanSanction lit une ligne de la table sanctions sélectionnée avec sanctionColumns
+func scanSanction(scanner interface{ Scan(...interface{}) error }) (*Sanction, error) {
+	sanction := &Sanction{}
+	var moderatorID, reportID, revokedBy sql.NullInt64
+	var expiresAt, revokedAt sql.NullTime
+	var ip sql.NullString
+
+	err := scanner.Scan(&sanction.ID, &sanction.UserID, &sanction.Kind, &sanction.Reason, &moderatorID, &reportID,
+		&sanction.CreatedAt, &expiresAt, &ip, &revokedAt, &revokedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	sanction.ModeratorID = nullIntPtr(moderatorID)
+	sanction.ReportID = nullIntPtr(reportID)
+	sanction.RevokedBy = nullIntPtr(revokedBy)
+	if expiresAt.Valid {
+		sanction.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		sanction.RevokedAt = &revokedAt.Time
+	}
+	sanction.IP = ip.String
+
+	return sanction, nil
+}
+
+// nullIntPtr convertit un entier pouvant être NULL en pointeur
+func nullIntPtr(value sql.NullInt64) *int {
+	if !value.Valid {
+		return nil
+	}
+	id := int(value.Int64)
+	return &id
+}
+
+// CreateSanction enregistre une sanction contre un utilisateur et complète son ID et sa date de création
+func CreateSanction(sanction *Sanction) error {
+	var ip interface{}
+	if sanction.IP != "" {
+		ip = sanction.IP
+	}
+
+	created, err := scanSanction(DB.QueryRow(`
+		INSERT INTO sanctions (user_id, kind, reason, moderator_id, report_id, expires_at, ip)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING `+sanctionColumns,
+		sanction.UserID, sanction.Kind, sanction.Reason, sanction.ModeratorID, sanction.ReportID, sanction.ExpiresAt, ip))
+	if err != nil {
+		return err
+	}
+
+	*sanction = *created
+	return nil
+}
+
+// GetActiveSanctions retourne les sanctions en cours d'un utilisateur : la suspension ou le bannissement
+// qui le tient éloigné le plus longtemps (un bannissement l'emporte), et sa mise en lecture seule éventuelle
+func GetActiveSanctions(userID int) (*SanctionStatus, error) {
+	rows, err := DB.Query(`
+		SELECT `+sanctionColumns+`
+		FROM sanctions
+		WHERE user_id = ? AND kind IN (?, ?, ?) AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY expires_at IS NOT NULL, expires_at DESC
+	`, userID, SanctionSuspension, SanctionBan, SanctionMute, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	status := &SanctionStatus{}
+	for rows.Next() {
+		sanction, err := scanSanction(rows)
+		if err != nil {
+			return nil, err
+		}
+		// Les lignes arrivent de la plus longue à la plus courte : garder la première de chaque sorte
+		switch {
+		case sanction.Kind == SanctionMute && status.Mute == nil:
+			status.Mute = sanction
+		case sanction.Kind != SanctionMute && status.Suspension == nil:
+			status.Suspension = sanction
+		}
+	}
+
+	return status, rows.Err()
+}
+
+// GetIPBan retourne le bannissement en cours visant une adresse IP, ou nil
+func GetIPBan(ip string) (*Sanction, error) {
+	if ip == "" {
+		return nil, nil
+	}
+	sanction, err := scanSanction(DB.QueryRow(`
+		SELECT `+sanctionColumns+`
+		FROM sanctions
+		WHERE ip = ? AND kind = ? AND revoked_at IS NULL
+		LIMIT 1
+	`, ip, SanctionBan))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return sanction, err
+}
+
+// GetUserSanctions retourne l'historique des sanctions d'un utilisateur, de la plus récente à la plus ancienne
+func GetUserSanctions(userID int) ([]*Sanction, error) {
+	rows, err := DB.Query(`
+		SELECT `+sanctionColumns+`
+		FROM sanctions
+		WHERE user_id = ?
+		ORDER BY created_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sanctions := make([]*Sanction, 0)
+	for rows.Next() {
+		sanction, err := scanSanction(rows)
+		if err != nil {
+			return nil, err
+		}
+		sanctions = append(sanctions, sanction)
+	}
+
+	return sanctions, rows.Err()
+}
+
+// RevokeSanction lève une sanction avant son terme et retourne la sanction mise à jour
+func RevokeSanction(sanctionID, moderatorID int) (*Sanction, error) {
+	sanction, err := scanSanction(DB.QueryRow(`
+		UPDATE sanctions SET revoked_at = ?, revoked_by = ?
+		WHERE id = ? AND revoked_at IS NULL
+		RETURNING `+sanctionColumns, time.Now().UTC(), moderatorID, sanctionID))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("sanction non trouvée ou déjà levée")
+	}
+	return sanction, err
+}
+
+// GetLastLoginIP retourne l'adresse IP de la dernière connexion d'un utilisateur (vide si inconnue)
+func GetLastLoginIP(userID int) (string, error) {
+	var ip string
+	err := DB.QueryRow("SELECT last_ip FROM users WHERE id = ?", userID).Scan(&ip)
+	return ip, err
+}
+
+// DeleteUserSessions supprime toutes les sessions d'un utilisateur
+func DeleteUserSessions(userID int) error {
+	_, err := DB.Exec("DELETE FROM sessions WHERE user_id = ?", userID)
+	return err
+}
+
+// ==================================
+// Role Operations
+// ==================================
+
+// GetStaffIDs retourne les IDs des modérateurs et administrateurs
+func GetStaffIDs() (map[int]bool, error) {
+	return queryIDSet("SELECT id FROM users WHERE role IN (?, ?)", RoleModerator, RoleAdmin)
+}
+
+// SetUserRole change le rôle d'un utilisateur
+func SetUserRole(userID int, role string) error {
+	result, err := DB.Exec("UPDATE users SET role = ? WHERE id = ?", role, userID)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(result, "utilisateur non trouvé")
+}
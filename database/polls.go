@@ -0,0 +1,239 @@
+// fichier: database/polls.go
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Erreurs de vote
+var (
+	ErrPollNotFound   = errors.New("sondage non trouvé")
+	ErrPollClosed     = errors.New("sondage clos")
+	ErrAlreadyVoted   = errors.New("vous avez déjà voté à ce sondage")
+	ErrInvalidOptions = errors.New("choix invalides pour ce sondage")
+)
+
+// ==================================
+// Poll Operations
+// ==================================
+
+// createPoll enregistre le sondage d'une publication et ses choix, dans l'ordre donné
+func createPoll(tx *sql.Tx, postID int, request *PollRequest) error {
+	var closesAt interface{}
+	if request.ClosesAt != nil {
+		closesAt = request.ClosesAt.UTC()
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO polls (post_id, question, multiple, anonymous, closes_at) VALUES (?, ?, ?, ?, ?)",
+		postID, request.Question, request.Multiple, request.Anonymous, closesAt,
+	)
+	if err != nil {
+		return err
+	}
+	pollID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	for position, label := range request.Options {
+		_, err := tx.Exec(
+			"INSERT INTO poll_options (poll_id, label, position) VALUES (?, ?, ?)",
+			pollID, label, position,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadPolls charge les sondages des publications données avec le nombre de voix de chaque choix
+func loadPolls(postIDs []int) (map[int]*Poll, error) {
+	byPost := make(map[int]*Poll)
+	if len(postIDs) == 0 {
+		return byPost, nil
+	}
+
+	args := make([]interface{}, len(postIDs))
+	for i, id := range postIDs {
+		args[i] = id
+	}
+
+	rows, err := DB.Query(`
+		SELECT id, post_id, question, multiple, anonymous, closes_at,
+			(SELECT COUNT(*) FROM poll_ballots b WHERE b.poll_id = polls.id)
+		FROM polls
+		WHERE post_id IN (`+placeholders(len(postIDs))+`)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byID := make(map[int]*Poll)
+	now := time.Now()
+	for rows.Next() {
+		poll := &Poll{Options: make([]*PollOption, 0)}
+		var closesAt sql.NullTime
+		err := rows.Scan(&poll.ID, &poll.PostID, &poll.Question, &poll.Multiple, &poll.Anonymous,
+			&closesAt, &poll.TotalVoters)
+		if err != nil {
+			return nil, err
+		}
+		if closesAt.Valid {
+			poll.ClosesAt = &closesAt.Time
+			poll.Closed = !now.Before(closesAt.Time)
+		}
+		byPost[poll.PostID] = poll
+		byID[poll.ID] = poll
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(byID) == 0 {
+		return byPost, nil
+	}
+
+	pollIDs := make([]interface{}, 0, len(byID))
+	for id := range byID {
+		pollIDs = append(pollIDs, id)
+	}
+
+	optionRows, err := DB.Query(`
+		SELECT o.id, o.poll_id, o.label, COUNT(v.user_id)
+		FROM poll_options o
+		LEFT JOIN poll_votes v ON v.option_id = o.id
+		WHERE o.poll_id IN (`+placeholders(len(pollIDs))+`)
+		GROUP BY o.id
+		ORDER BY o.poll_id, o.position
+	`, pollIDs...)
+	if err != nil {
+		return nil, err
+	}
+	defer optionRows.Close()
+
+	for optionRows.Next() {
+		option := &PollOption{}
+		var pollID int
+		if err := optionRows.Scan(&option.ID, &pollID, &option.Label, &option.Votes); err != nil {
+			return nil, err
+		}
+		byID[pollID].Options = append(byID[pollID].Options, option)
+	}
+
+	return byPost, optionRows.Err()
+}
+
+// withPostPolls renseigne le sondage (totaux seulement) des publications données
+func withPostPolls(posts []*Post) error {
+	ids := make([]int, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	byPost, err := loadPolls(ids)
+	if err != nil {
+		return err
+	}
+	for _, post := range posts {
+		post.Poll = byPost[post.ID]
+	}
+	return nil
+}
+
+// GetPollByPostID récupère le sondage d'une publication visible avec ses résultats détaillés :
+// noms des votants pour un sondage public et choix de l'utilisateur donné (0 pour un visiteur)
+func GetPollByPostID(postID, viewerID int) (*Poll, error) {
+	var visible bool
+	err := DB.QueryRow("SELECT EXISTS(SELECT 1 FROM posts WHERE id = ? AND hidden = FALSE)", postID).Scan(&visible)
+	if err != nil {
+		return nil, err
+	}
+	if !visible {
+		return nil, ErrPollNotFound
+	}
+
+	byPost, err := loadPolls([]int{postID})
+	if err != nil {
+		return nil, err
+	}
+	poll, ok := byPost[postID]
+	if !ok {
+		return nil, ErrPollNotFound
+	}
+
+	rows, err := DB.Query(`
+		SELECT v.option_id, v.user_id, u.username
+		FROM poll_votes v
+		JOIN users u ON u.id = v.user_id
+		JOIN poll_ballots b ON b.poll_id = v.poll_id AND b.user_id = v.user_id
+		WHERE v.poll_id = ?
+		ORDER BY b.created_at, u.username
+	`, poll.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	options := make(map[int]*PollOption, len(poll.Options))
+	for _, option := range poll.Options {
+		options[option.ID] = option
+	}
+	for rows.Next() {
+		var optionID, userID int
+		var username string
+		if err := rows.Scan(&optionID, &userID, &username); err != nil {
+			return nil, err
+		}
+		if userID == viewerID {
+			poll.MyVotes = append(poll.MyVotes, optionID)
+		}
+		if !poll.Anonymous && options[optionID] != nil {
+			options[optionID].Voters = append(options[optionID].Voters, username)
+		}
+	}
+
+	return poll, rows.Err()
+}
+
+// CastVote enregistre le bulletin d'un utilisateur. Le bulletin est refusé si le sondage est clos
+// ou si l'utilisateur a déjà voté ; chaque choix doit appartenir au sondage.
+func CastVote(pollID, userID int, optionIDs []int) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO poll_ballots (poll_id, user_id)
+		SELECT id, ? FROM polls WHERE id = ? AND (closes_at IS NULL OR closes_at > ?)
+	`, userID, pollID, time.Now().UTC())
+	if isUniqueViolation(err) {
+		return ErrAlreadyVoted
+	}
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return ErrPollClosed
+	}
+
+	for _, optionID := range optionIDs {
+		result, err := tx.Exec(`
+			INSERT INTO poll_votes (poll_id, user_id, option_id)
+			SELECT poll_id, ?, id FROM poll_options WHERE id = ? AND poll_id = ?
+		`, userID, optionID, pollID)
+		if err != nil {
+			return ErrInvalidOptions
+		}
+		if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+			return ErrInvalidOptions
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,227 @@
+// fichier: database/profiles.go
+package database
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"realtimeforum/config"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Erreurs retournées lors d'un changement d'adresse email
+var (
+	ErrEmailTaken         = errors.New("adresse email déjà utilisée")
+	ErrEmailChangeExpired = errors.New("lien de vérification invalide ou expiré")
+)
+
+// ==================================
+// Profile Operations
+// ==================================
+
+// UpdateUserProfile applique une modification partielle du profil ; les champs nil sont inchangés
+func UpdateUserProfile(userID int, update ProfileUpdateRequest) error {
+	_, err := DB.Exec(`
+		UPDATE users SET
+			first_name = COALESCE(?, first_name),
+			last_name = COALESCE(?, last_name),
+			age = COALESCE(?, age),
+			gender = COALESCE(?, gender),
+			bio = COALESCE(?, bio)
+		WHERE id = ?
+	`, update.FirstName, update.LastName, update.Age, update.Gender, update.Bio, userID)
+	return err
+}
+
+// ChangePassword remplace le mot de passe d'un utilisateur et ferme ses autres sessions
+func ChangePassword(userID int, newPassword, keepSessionID string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE users SET password = ? WHERE id = ?", string(hashedPassword), userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ? AND id != ?", userID, keepSessionID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// hashEmailToken hache un jeton de vérification d'email (seul le haché est stocké)
+func hashEmailToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateEmailChange enregistre un changement d'adresse en attente et retourne le jeton de vérification.
+// L'adresse actuelle reste en vigueur jusqu'à la vérification.
+func CreateEmailChange(userID int, newEmail string) (string, error) {
+	var count int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM users WHERE email = ?", newEmail).Scan(&count); err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return "", ErrEmailTaken
+	}
+
+	token, err := generateToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// Une seule demande en attente par utilisateur
+	if _, err := tx.Exec("DELETE FROM email_changes WHERE user_id = ?", userID); err != nil {
+		return "", err
+	}
+	_, err = tx.Exec(
+		"INSERT INTO email_changes (token_hash, user_id, new_email, expires_at) VALUES (?, ?, ?, ?)",
+		hashEmailToken(token), userID, newEmail, time.Now().Add(config.App.EmailVerificationTTL),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ConfirmEmailChange applique le changement d'adresse correspondant au jeton et retourne l'ID utilisateur
+func ConfirmEmailChange(token string) (int, error) {
+	var userID int
+	var newEmail string
+	var expiresAt time.Time
+
+	// Le jeton est à usage unique : il est supprimé dès sa lecture
+	err := DB.QueryRow(
+		"DELETE FROM email_changes WHERE token_hash = ? RETURNING user_id, new_email, expires_at",
+		hashEmailToken(token),
+	).Scan(&userID, &newEmail, &expiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrEmailChangeExpired
+		}
+		return 0, err
+	}
+	if time.Now().After(expiresAt) {
+		return 0, ErrEmailChangeExpired
+	}
+
+	if _, err := DB.Exec("UPDATE users SET email = ? WHERE id = ?", newEmail, userID); err != nil {
+		if isUniqueViolation(err) {
+			return 0, ErrEmailTaken
+		}
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// DeleteExpiredEmailChanges supprime les demandes de changement d'email expirées
+func DeleteExpiredEmailChanges() (int64, error) {
+	result, err := DB.Exec("DELETE FROM email_changes WHERE expires_at < ?", time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// isUniqueViolation indique si l'erreur provient d'une contrainte d'unicité
+func isUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// ==================================
+// Public Profile Operations
+// ==================================
+
+// Nombre de publications récentes affichées sur un profil public
+const profileRecentPosts = 5
+
+// GetPublicProfile construit le profil public d'un utilisateur
+func GetPublicProfile(userID int) (*PublicProfile, error) {
+	user, err := GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Les champs personnels suivent les paramètres de confidentialité de l'utilisateur
+	profile := &PublicProfile{PublicUser: user.Public()}
+
+	err = DB.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM posts WHERE user_id = ? AND hidden = FALSE),
+			(SELECT COUNT(*) FROM comments WHERE user_id = ? AND hidden = FALSE)
+	`, userID, userID).Scan(&profile.PostCount, &profile.CommentCount)
+	if err != nil {
+		return nil, err
+	}
+
+	profile.RecentPosts, err = GetPostsByUser(userID, profileRecentPosts)
+	if err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// GetPostsByUser récupère les publications les plus récentes d'un utilisateur
+func GetPostsByUser(userID, limit int) ([]*Post, error) {
+	rows, err := DB.Query(postSelect+`
+		WHERE p.user_id = ? AND p.hidden = FALSE
+		ORDER BY p.created_at DESC
+		LIMIT ?
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := make([]*Post, 0)
+	for rows.Next() {
+		post, err := scanPost(rows)
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := withPostTags(posts); err != nil {
+		return nil, err
+	}
+	if err := withPostPolls(posts); err != nil {
+		return nil, err
+	}
+	if err := withPostLinks(posts); err != nil {
+		return nil, err
+	}
+	if err := withPostBacklinks(posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
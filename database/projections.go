@@ -0,0 +1,89 @@
+// fichier: database/projections.go
+package database
+
+import "strings"
+
+// ==================================
+// User Projections
+// ==================================
+
+// Public retourne la vue de l'utilisateur destinée aux autres utilisateurs.
+// Les champs personnels n'y figurent que si l'utilisateur l'a autorisé.
+func (u *User) Public() *PublicUser {
+	public := &PublicUser{
+		ID:        u.ID,
+		Username:  u.Username,
+		Type:      u.Type,
+		Role:      u.Role,
+		Bio:       u.Bio,
+		Online:    u.Online,
+		CreatedAt: u.CreatedAt,
+		Avatar:    u.avatar(),
+	}
+
+	if u.Privacy.ShowRealName {
+		public.FirstName = u.FirstName
+		public.LastName = u.LastName
+	}
+	if u.Privacy.ShowAge {
+		public.Age = u.Age
+	}
+	if u.Privacy.ShowGender {
+		public.Gender = u.Gender
+	}
+	if u.Privacy.ShowEmail {
+		public.Email = u.Email
+	}
+	if u.Privacy.ShowLastSeen && u.LastLogin != nil {
+		lastSeen := *u.LastLogin
+		public.LastSeen = &lastSeen
+	}
+
+	return public
+}
+
+// Private retourne la vue complète de l'utilisateur, réservée à lui-même et aux administrateurs.
+// Le mot de passe n'y figure jamais.
+func (u *User) Private() *PrivateUser {
+	return &PrivateUser{
+		ID:               u.ID,
+		Username:         u.Username,
+		Age:              u.Age,
+		Gender:           u.Gender,
+		FirstName:        u.FirstName,
+		LastName:         u.LastName,
+		Email:            u.Email,
+		CreatedAt:        u.CreatedAt,
+		LastLogin:        u.LastLogin,
+		Online:           u.Online,
+		TwoFactorEnabled: u.TwoFactorEnabled,
+		Role:             u.Role,
+		Type:             u.Type,
+		Bio:              u.Bio,
+		Privacy:          u.Privacy,
+		Avatar:           u.avatar(),
+	}
+}
+
+// prefixColumns préfixe chaque colonne d'une liste par l'alias de table donné
+func prefixColumns(prefix, columns string) string {
+	parts := strings.Split(columns, ",")
+	for i, part := range parts {
+		parts[i] = prefix + strings.TrimSpace(part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ==================================
+// Privacy Operations
+// ==================================
+
+// UpdatePrivacySettings enregistre les paramètres de confidentialité d'un utilisateur
+func UpdatePrivacySettings(userID int, settings PrivacySettings) error {
+	_, err := DB.Exec(`
+		UPDATE users SET
+			privacy_real_name = ?, privacy_age = ?, privacy_gender = ?, privacy_email = ?, privacy_last_seen = ?
+		WHERE id = ?
+	`, settings.ShowRealName, settings.ShowAge, settings.ShowGender, settings.ShowEmail, settings.ShowLastSeen, userID)
+	return err
+}
@@ -0,0 +1,240 @@
+// fichier: database/projections_test.go
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Données personnelles reconnaissables, qui ne doivent jamais apparaître dans la vue publique
+const (
+	secretFirstName = "Prénomsecret"
+	secretLastName  = "Nomsecret"
+	secretEmail     = "adresse-secrete@example.com"
+	secretGender    = "Autre"
+	secretAge       = 42
+)
+
+// Champs personnels ou sensibles d'un utilisateur
+var privateKeys = []string{"email", "firstName", "lastName", "age", "gender", "lastSeen", "lastLogin",
+	"twoFactorEnabled", "privacy", "password", "totpSecret", "token", "tokens", "csrfToken", "sessionId"}
+
+// setupTestDB ouvre une base temporaire dotée du schéma complet
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	schema, err := os.ReadFile(filepath.Join("..", "schema.sql"))
+	if err != nil {
+		t.Fatalf("lecture du schéma: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "forum.db"))
+	if err != nil {
+		t.Fatalf("ouverture de la base: %v", err)
+	}
+	previous := DB
+	DB = db
+	t.Cleanup(func() {
+		db.Close()
+		DB = previous
+	})
+
+	if err := migrate(string(schema)); err != nil {
+		t.Fatalf("application du schéma: %v", err)
+	}
+}
+
+// testUser retourne un utilisateur dont tous les champs personnels sont renseignés
+func testUser(privacy PrivacySettings) *User {
+	lastLogin := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	return &User{
+		ID:               7,
+		Username:         "alice",
+		Age:              secretAge,
+		Gender:           secretGender,
+		FirstName:        secretFirstName,
+		LastName:         secretLastName,
+		Email:            secretEmail,
+		Password:         "$2a$10$hachedumotdepasse",
+		CreatedAt:        time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC),
+		LastLogin:        &lastLogin,
+		Online:           true,
+		TwoFactorEnabled: true,
+		Role:             RoleUser,
+		Type:             UserTypeHuman,
+		Bio:              "Bonjour",
+		Privacy:          privacy,
+	}
+}
+
+// jsonKeys retourne toutes les clés d'un document JSON, à toute profondeur
+func jsonKeys(t *testing.T, data []byte) map[string]bool {
+	t.Helper()
+
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		t.Fatalf("JSON invalide: %v", err)
+	}
+
+	keys := make(map[string]bool)
+	var walk func(value interface{})
+	walk = func(value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for key, child := range v {
+				keys[key] = true
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(document)
+	return keys
+}
+
+// assertNoPrivateData vérifie qu'un document JSON destiné aux autres utilisateurs ne contient
+// aucun champ personnel hors ceux autorisés, ni aucune des valeurs secrètes données
+func assertNoPrivateData(t *testing.T, data []byte, allowed []string, secrets ...string) {
+	t.Helper()
+
+	keys := jsonKeys(t, data)
+	for _, key := range allowed {
+		delete(keys, key)
+	}
+	for _, key := range privateKeys {
+		if keys[key] {
+			t.Errorf("le champ %q ne doit pas figurer dans %s", key, data)
+		}
+	}
+	for _, secret := range append(secrets, secretFirstName, secretLastName, secretEmail) {
+		if secret != "" && strings.Contains(string(data), secret) {
+			t.Errorf("la valeur %q ne doit pas figurer dans %s", secret, data)
+		}
+	}
+}
+
+func TestUserPublicProjection(t *testing.T) {
+	tests := []struct {
+		name    string
+		privacy PrivacySettings
+		visible []string
+	}{
+		{name: "rien d'autorisé", privacy: PrivacySettings{}},
+		{name: "dernière connexion", privacy: PrivacySettings{ShowLastSeen: true}, visible: []string{"lastSeen"}},
+		{name: "nom réel", privacy: PrivacySettings{ShowRealName: true}, visible: []string{"firstName", "lastName"}},
+		{name: "âge et genre", privacy: PrivacySettings{ShowAge: true, ShowGender: true}, visible: []string{"age", "gender"}},
+		{name: "email", privacy: PrivacySettings{ShowEmail: true}, visible: []string{"email"}},
+		{
+			name:    "tout autorisé",
+			privacy: PrivacySettings{ShowRealName: true, ShowAge: true, ShowGender: true, ShowEmail: true, ShowLastSeen: true},
+			visible: []string{"firstName", "lastName", "age", "gender", "email", "lastSeen"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(testUser(tt.privacy).Public())
+			if err != nil {
+				t.Fatalf("sérialisation: %v", err)
+			}
+
+			keys := jsonKeys(t, data)
+			allowed := make(map[string]bool)
+			for _, key := range tt.visible {
+				allowed[key] = true
+				if !keys[key] {
+					t.Errorf("le champ autorisé %q est absent de %s", key, data)
+				}
+			}
+			for _, key := range privateKeys {
+				if keys[key] && !allowed[key] {
+					t.Errorf("le champ %q ne doit pas figurer dans %s", key, data)
+				}
+			}
+			for _, key := range []string{"id", "username", "online", "createdAt"} {
+				if !keys[key] {
+					t.Errorf("le champ public %q est absent de %s", key, data)
+				}
+			}
+		})
+	}
+}
+
+func TestUserPrivateProjection(t *testing.T) {
+	user := testUser(PrivacySettings{})
+
+	data, err := json.Marshal(user.Private())
+	if err != nil {
+		t.Fatalf("sérialisation: %v", err)
+	}
+
+	keys := jsonKeys(t, data)
+	for _, key := range []string{"email", "firstName", "lastName", "age", "gender", "twoFactorEnabled", "privacy"} {
+		if !keys[key] {
+			t.Errorf("le champ %q est absent de la vue privée %s", key, data)
+		}
+	}
+	if keys["password"] || strings.Contains(string(data), user.Password) {
+		t.Errorf("le mot de passe ne doit jamais être sérialisé: %s", data)
+	}
+}
+
+func TestGetOnlineUsersHidesPrivateFields(t *testing.T) {
+	setupTestDB(t)
+
+	userID, err := CreateUser(UserDTO{
+		Username:  "alice",
+		Age:       secretAge,
+		Gender:    secretGender,
+		FirstName: secretFirstName,
+		LastName:  secretLastName,
+		Email:     secretEmail,
+		Password:  "motdepasse-secret",
+	})
+	if err != nil {
+		t.Fatalf("création de l'utilisateur: %v", err)
+	}
+
+	// Renseigner tout ce qui ne doit pas être diffusé : 2FA, jeton d'accès, session
+	const totpSecret = "JBSWY3DPEHPK3PXPSECRET"
+	if _, err := DB.Exec("UPDATE users SET totp_secret = ?, totp_enabled = TRUE WHERE id = ?", totpSecret, userID); err != nil {
+		t.Fatalf("activation de la 2FA: %v", err)
+	}
+	_, tokenValue, err := CreateAPIToken(userID, APITokenRequest{Name: "test", Scopes: []string{ScopePostsRead}})
+	if err != nil {
+		t.Fatalf("création du jeton: %v", err)
+	}
+	session, err := CreateSession(userID)
+	if err != nil {
+		t.Fatalf("création de la session: %v", err)
+	}
+	if err := RecordUserLogin(userID); err != nil {
+		t.Fatalf("connexion: %v", err)
+	}
+	if err := UpdateUserOnlineStatus(userID, true); err != nil {
+		t.Fatalf("statut en ligne: %v", err)
+	}
+
+	users, err := GetOnlineUsers()
+	if err != nil {
+		t.Fatalf("GetOnlineUsers: %v", err)
+	}
+	if len(users) != 1 || users[0].ID != userID {
+		t.Fatalf("utilisateurs en ligne inattendus: %+v", users)
+	}
+
+	data, err := json.Marshal(users)
+	if err != nil {
+		t.Fatalf("sérialisation: %v", err)
+	}
+	// Par défaut, seule la dernière connexion est visible des autres utilisateurs
+	assertNoPrivateData(t, data, []string{"lastSeen"}, totpSecret, tokenValue, session.ID, session.CSRFToken)
+}
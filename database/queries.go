@@ -2,8 +2,11 @@
 package database
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
 	"errors"
+	"realtimeforum/config"
 	"strings"
 	"time"
 
@@ -41,97 +44,63 @@ func CreateUser(user UserDTO) (int, error) {
 	return int(id), nil
 }
 
-// GetUserByID récupère un utilisateur par son ID
-func GetUserByID(id int) (*User, error) {
+// Colonnes lues pour un utilisateur (voir scanUser)
+const userColumns = `id, username, age, gender, first_name, last_name, email, password, created_at, last_login,
+	online, totp_enabled, role, user_type, bio,
+	privacy_real_name, privacy_age, privacy_gender, privacy_email, privacy_last_seen, avatar_version`
+
+// scanUser lit une ligne de la table users sélectionnée avec userColumns
+func scanUser(scanner interface{ Scan(...interface{}) error }) (*User, error) {
 	user := &User{}
 
 	// Utiliser des variables temporaires pour les champs qui peuvent être NULL
 	var lastLoginNull sql.NullTime
 
-	err := DB.QueryRow(
-		"SELECT id, username, age, gender, first_name, last_name, email, password, created_at, last_login, online FROM users WHERE id = ?",
-		id,
-	).Scan(&user.ID, &user.Username, &user.Age, &user.Gender, &user.FirstName, &user.LastName, &user.Email,
-		&user.Password, &user.CreatedAt, &lastLoginNull, &user.Online)
-
+	err := scanner.Scan(&user.ID, &user.Username, &user.Age, &user.Gender, &user.FirstName, &user.LastName, &user.Email,
+		&user.Password, &user.CreatedAt, &lastLoginNull, &user.Online, &user.TwoFactorEnabled, &user.Role, &user.Type, &user.Bio,
+		&user.Privacy.ShowRealName, &user.Privacy.ShowAge, &user.Privacy.ShowGender, &user.Privacy.ShowEmail, &user.Privacy.ShowLastSeen,
+		&user.AvatarVersion)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("utilisateur non trouvé")
-		}
 		return nil, err
 	}
 
-	// Si lastLogin est valide, l'assigner, sinon utiliser l'heure actuelle
+	// Un utilisateur qui ne s'est jamais connecté n'a pas de date de dernière connexion
 	if lastLoginNull.Valid {
-		user.LastLogin = lastLoginNull.Time
-	} else {
-		user.LastLogin = time.Now()
+		user.LastLogin = &lastLoginNull.Time
 	}
 
 	return user, nil
 }
 
-// GetUserByEmail récupère un utilisateur par son email
-func GetUserByEmail(email string) (*User, error) {
-	user := &User{}
-
-	// Utiliser des variables temporaires pour les champs qui peuvent être NULL
-	var lastLoginNull sql.NullTime
-
-	err := DB.QueryRow(
-		"SELECT id, username, age, gender, first_name, last_name, email, password, created_at, last_login, online FROM users WHERE email = ?",
-		email,
-	).Scan(&user.ID, &user.Username, &user.Age, &user.Gender, &user.FirstName, &user.LastName, &user.Email,
-		&user.Password, &user.CreatedAt, &lastLoginNull, &user.Online)
-
+// getUserWhere récupère un utilisateur selon une condition sur une colonne
+func getUserWhere(condition string, value interface{}) (*User, error) {
+	user, err := scanUser(DB.QueryRow("SELECT "+userColumns+" FROM users WHERE "+condition, value))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("utilisateur non trouvé")
 		}
 		return nil, err
 	}
+	return user, nil
+}
 
-	// Si lastLogin est valide, l'assigner, sinon utiliser l'heure actuelle
-	if lastLoginNull.Valid {
-		user.LastLogin = lastLoginNull.Time
-	} else {
-		user.LastLogin = time.Now()
-	}
+// GetUserByID récupère un utilisateur par son ID
+func GetUserByID(id int) (*User, error) {
+	return getUserWhere("id = ?", id)
+}
 
-	return user, nil
+// GetUserByEmail récupère un utilisateur par son email
+func GetUserByEmail(email string) (*User, error) {
+	return getUserWhere("email = ?", email)
 }
 
 // GetUserByUsername récupère un utilisateur par son nom d'utilisateur
 func GetUserByUsername(username string) (*User, error) {
-	user := &User{}
-
-	// Utiliser des variables temporaires pour les champs qui peuvent être NULL
-	var lastLoginNull sql.NullTime
-
-	err := DB.QueryRow(
-		"SELECT id, username, age, gender, first_name, last_name, email, password, created_at, last_login, online FROM users WHERE username = ?",
-		username,
-	).Scan(&user.ID, &user.Username, &user.Age, &user.Gender, &user.FirstName, &user.LastName, &user.Email,
-		&user.Password, &user.CreatedAt, &lastLoginNull, &user.Online)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, errors.New("utilisateur non trouvé")
-		}
-		return nil, err
-	}
-
-	// Si lastLogin est valide, l'assigner, sinon utiliser l'heure actuelle
-	if lastLoginNull.Valid {
-		user.LastLogin = lastLoginNull.Time
-	} else {
-		user.LastLogin = time.Now()
-	}
-
-	return user, nil
+	return getUserWhere("username = ?", username)
 }
 
-// AuthenticateUser authentifie un utilisateur par identifiant (email ou username) et mot de passe
+// AuthenticateUser vérifie l'identifiant (email ou username) et le mot de passe d'un utilisateur.
+// La connexion n'est enregistrée qu'avec RecordUserLogin, une fois tous les facteurs vérifiés.
 func AuthenticateUser(identifier, password string) (*User, error) {
 	var user *User
 	var err error
@@ -153,13 +122,43 @@ func AuthenticateUser(identifier, password string) (*User, error) {
 		return nil, errors.New("mot de passe incorrect")
 	}
 
-	// Mettre à jour last_login et online
-	_, err = DB.Exec("UPDATE users SET last_login = ?, online = TRUE WHERE id = ?", time.Now(), user.ID)
+	return user, nil
+}
+
+// RecordUserLogin enregistre une connexion réussie (last_login et statut en ligne)
+func RecordUserLogin(userID int) error {
+	_, err := DB.Exec("UPDATE users SET last_login = ?, online = TRUE WHERE id = ?", time.Now(), userID)
+	return err
+}
+
+// RecordUserIP mémorise l'adresse IP de la dernière connexion, utilisée pour un bannissement par IP
+func RecordUserIP(userID int, ip string) error {
+	_, err := DB.Exec("UPDATE users SET last_ip = ? WHERE id = ?", ip, userID)
+	return err
+}
+
+// GetUserRole récupère le rôle d'un utilisateur
+func GetUserRole(userID int) (string, error) {
+	var role string
+	err := DB.QueryRow("SELECT role FROM users WHERE id = ?", userID).Scan(&role)
 	if err != nil {
-		return nil, err
+		if err == sql.ErrNoRows {
+			return "", errors.New("utilisateur non trouvé")
+		}
+		return "", err
 	}
+	return role, nil
+}
 
-	return user, nil
+// PromoteAdmins attribue le rôle administrateur aux utilisateurs listés (configuration initiale)
+func PromoteAdmins(usernames []string) error {
+	for _, username := range usernames {
+		_, err := DB.Exec("UPDATE users SET role = ? WHERE username = ?", RoleAdmin, username)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // UpdateUserOnlineStatus met à jour le statut en ligne d'un utilisateur
@@ -168,10 +167,11 @@ func UpdateUserOnlineStatus(userID int, online bool) error {
 	return err
 }
 
-// GetOnlineUsers récupère tous les utilisateurs en ligne triés par dernier message
-func GetOnlineUsers() ([]*User, error) {
+// GetOnlineUsers récupère tous les utilisateurs en ligne triés par dernier message,
+// sous leur forme publique : cette liste est diffusée à tous les clients connectés
+func GetOnlineUsers() ([]*PublicUser, error) {
 	rows, err := DB.Query(`
-		SELECT u.id, u.username, u.age, u.gender, u.first_name, u.last_name, u.email, u.created_at, u.last_login, u.online
+		SELECT ` + prefixColumns("u.", userColumns) + `
 		FROM users u
 		LEFT JOIN (
 			SELECT sender_id, MAX(created_at) as last_msg
@@ -186,14 +186,13 @@ func GetOnlineUsers() ([]*User, error) {
 	}
 	defer rows.Close()
 
-	users := make([]*User, 0)
+	users := make([]*PublicUser, 0)
 	for rows.Next() {
-		user := &User{}
-		err := rows.Scan(&user.ID, &user.Username, &user.Age, &user.Gender, &user.FirstName, &user.LastName, &user.Email, &user.CreatedAt, &user.LastLogin, &user.Online)
+		user, err := scanUser(rows)
 		if err != nil {
 			return nil, err
 		}
-		users = append(users, user)
+		users = append(users, user.Public())
 	}
 
 	if err = rows.Err(); err != nil {
@@ -207,27 +206,63 @@ func GetOnlineUsers() ([]*User, error) {
 // Session Operations
 // ==================================
 
+// Erreurs retournées lors de la validation d'une session
+var (
+	ErrSessionNotFound = errors.New("session non trouvée")
+	ErrSessionExpired  = errors.New("session expirée")
+)
+
+// sessionExpiry calcule l'expiration d'une session à partir de sa dernière activité,
+// sans jamais dépasser sa durée de vie absolue
+func sessionExpiry(createdAt, lastSeenAt time.Time) time.Time {
+	idleExpiry := lastSeenAt.Add(config.App.SessionIdleTimeout)
+	absoluteExpiry := createdAt.Add(config.App.SessionAbsoluteTimeout)
+	if idleExpiry.After(absoluteExpiry) {
+		return absoluteExpiry
+	}
+	return idleExpiry
+}
+
+// generateToken génère un jeton aléatoire de n octets encodé en hexadécimal
+func generateToken(n int) (string, error) {
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
 // CreateSession crée une nouvelle session pour un utilisateur
 func CreateSession(userID int) (*Session, error) {
 	// Générer un ID de session unique
 	sessionID := uuid.NewString()
 
-	// Définir l'expiration (24 heures)
-	expiresAt := time.Now().Add(24 * time.Hour)
+	// Générer le jeton anti-CSRF de la session
+	csrfToken, err := generateToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	// Définir l'expiration à partir des délais d'inactivité et absolu configurés
+	now := time.Now()
+	expiresAt := sessionExpiry(now, now)
 
 	// Insérer la session dans la base de données
-	_, err := DB.Exec(
-		"INSERT INTO sessions (id, user_id, expires_at) VALUES (?, ?, ?)",
-		sessionID, userID, expiresAt,
+	_, err = DB.Exec(
+		"INSERT INTO sessions (id, user_id, created_at, last_seen_at, expires_at, csrf_token) VALUES (?, ?, ?, ?, ?, ?)",
+		sessionID, userID, now, now, expiresAt, csrfToken,
 	)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Session{
-		ID:        sessionID,
-		UserID:    userID,
-		ExpiresAt: expiresAt,
+		ID:         sessionID,
+		UserID:     userID,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt,
+		CSRFToken:  csrfToken,
 	}, nil
 }
 
@@ -235,42 +270,152 @@ func CreateSession(userID int) (*Session, error) {
 func GetSessionByID(sessionID string) (*Session, error) {
 	session := &Session{}
 	err := DB.QueryRow(
-		"SELECT id, user_id, expires_at FROM sessions WHERE id = ?",
+		"SELECT id, user_id, created_at, last_seen_at, expires_at, csrf_token FROM sessions WHERE id = ?",
 		sessionID,
-	).Scan(&session.ID, &session.UserID, &session.ExpiresAt)
+	).Scan(&session.ID, &session.UserID, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &session.CSRFToken)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("session non trouvée")
+			return nil, ErrSessionNotFound
 		}
 		return nil, err
 	}
 
-	// Vérifier si la session est expirée
-	if time.Now().After(session.ExpiresAt) {
+	// Vérifier si la session est expirée (inactivité ou durée de vie absolue)
+	now := time.Now()
+	if now.After(session.ExpiresAt) || now.After(session.CreatedAt.Add(config.App.SessionAbsoluteTimeout)) {
 		// Supprimer la session expirée
 		_, _ = DB.Exec("DELETE FROM sessions WHERE id = ?", sessionID)
-		return nil, errors.New("session expirée")
+		return nil, ErrSessionExpired
 	}
 
 	return session, nil
 }
 
+// RenewSession prolonge une session active suite à une activité de l'utilisateur.
+// Le renouvellement est limité à un par SessionRenewInterval pour éviter une écriture à chaque requête.
+// Le booléen retourné indique si l'expiration a été modifiée.
+func RenewSession(session *Session) (*Session, bool, error) {
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) < config.App.SessionRenewInterval {
+		return session, false, nil
+	}
+
+	expiresAt := sessionExpiry(session.CreatedAt, now)
+	_, err := DB.Exec(
+		"UPDATE sessions SET last_seen_at = ?, expires_at = ? WHERE id = ?",
+		now, expiresAt, session.ID,
+	)
+	if err != nil {
+		return session, false, err
+	}
+
+	renewed := *session
+	renewed.LastSeenAt = now
+	renewed.ExpiresAt = expiresAt
+	return &renewed, true, nil
+}
+
 // DeleteSession supprime une session
 func DeleteSession(sessionID string) error {
 	_, err := DB.Exec("DELETE FROM sessions WHERE id = ?", sessionID)
 	return err
 }
 
+// CreateWSTicket crée un ticket WebSocket à usage unique rattaché à une session
+func CreateWSTicket(sessionID string) (*WSTicket, error) {
+	id, err := generateToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	ticket := &WSTicket{
+		ID:        id,
+		SessionID: sessionID,
+		ExpiresAt: time.Now().Add(config.App.WSTicketTTL),
+	}
+
+	_, err = DB.Exec(
+		"INSERT INTO ws_tickets (id, session_id, expires_at) VALUES (?, ?, ?)",
+		ticket.ID, ticket.SessionID, ticket.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return ticket, nil
+}
+
+// ConsumeWSTicket consomme un ticket WebSocket et retourne la session associée.
+// Le ticket est supprimé dans tous les cas : il ne peut servir qu'une seule fois.
+func ConsumeWSTicket(ticketID string) (*Session, error) {
+	var sessionID string
+	var expiresAt time.Time
+	err := DB.QueryRow(
+		"DELETE FROM ws_tickets WHERE id = ? RETURNING session_id, expires_at",
+		ticketID,
+	).Scan(&sessionID, &expiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("ticket non trouvé")
+		}
+		return nil, err
+	}
+
+	if time.Now().After(expiresAt) {
+		return nil, errors.New("ticket expiré")
+	}
+
+	return GetSessionByID(sessionID)
+}
+
+// DeleteExpiredWSTickets supprime les tickets WebSocket expirés
+func DeleteExpiredWSTickets() (int64, error) {
+	result, err := DB.Exec("DELETE FROM ws_tickets WHERE expires_at < ?", time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteExpiredSessions supprime toutes les sessions expirées et retourne leur nombre
+func DeleteExpiredSessions() (int64, error) {
+	now := time.Now()
+	result, err := DB.Exec(
+		"DELETE FROM sessions WHERE expires_at < ? OR created_at < ?",
+		now, now.Add(-config.App.SessionAbsoluteTimeout),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 // ==================================
 // Post Operations
 // ==================================
 
-// CreatePost crée une nouvelle publication
+// CreatePost crée une nouvelle publication et y rattache ses pièces jointes, ses étiquettes et son sondage
 func CreatePost(post *Post) (int, error) {
-	result, err := DB.Exec(
-		"INSERT INTO posts (user_id, title, content, category_id) VALUES (?, ?, ?, ?)",
-		post.UserID, post.Title, post.Content, post.CategoryID,
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := createPost(tx, post)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+// createPost insère une publication dans la transaction donnée (voir CreatePost)
+func createPost(tx *sql.Tx, post *Post) (int, error) {
+	result, err := tx.Exec(
+		"INSERT INTO posts (user_id, title, content, category_id, hot_rank) VALUES (?, ?, ?, ?, ?)",
+		post.UserID, post.Title, post.Content, post.CategoryID, hotRank(0, time.Now()),
 	)
 	if err != nil {
 		return 0, err
@@ -281,23 +426,50 @@ func CreatePost(post *Post) (int, error) {
 		return 0, err
 	}
 
+	if err := linkAttachments(tx, "post_id", int(id), post.UserID, post.AttachmentIDs); err != nil {
+		return 0, err
+	}
+	if err := tagPost(tx, int(id), post.Tags); err != nil {
+		return 0, err
+	}
+	if err := linkContent(tx, LinkSourcePost, int(id), post.Content, int(id)); err != nil {
+		return 0, err
+	}
+	if post.NewPoll != nil {
+		if err := createPoll(tx, int(id), post.NewPoll); err != nil {
+			return 0, err
+		}
+	}
+
 	return int(id), nil
 }
 
-// GetPostByID récupère une publication par son ID
-func GetPostByID(postID int) (*Post, error) {
+// postSelect sélectionne une publication avec son auteur et sa catégorie, à lire avec scanPost
+const postSelect = `
+	SELECT p.id, p.user_id, u.username, p.title, p.content, p.category_id, c.name, p.created_at, p.updated_at,
+		p.pinned, p.locked, p.announcement, p.view_count, p.score, p.last_activity_at
+	FROM posts p
+	JOIN users u ON p.user_id = u.id
+	JOIN categories c ON p.category_id = c.id`
+
+// scanPost lit une ligne sélectionnée avec postSelect
+func scanPost(scanner interface{ Scan(...interface{}) error }) (*Post, error) {
 	post := &Post{}
-	err := DB.QueryRow(`
-		SELECT p.id, p.user_id, u.username, p.title, p.content, p.category_id, c.name, p.created_at, p.updated_at
-		FROM posts p
-		JOIN users u ON p.user_id = u.id
-		JOIN categories c ON p.category_id = c.id
-		WHERE p.id = ?
-	`, postID).Scan(
+	err := scanner.Scan(
 		&post.ID, &post.UserID, &post.Username, &post.Title, &post.Content,
 		&post.CategoryID, &post.Category, &post.CreatedAt, &post.UpdatedAt,
+		&post.Pinned, &post.Locked, &post.Announcement, &post.ViewCount,
+		&post.Score, &post.LastActivityAt,
 	)
+	if err != nil {
+		return nil, err
+	}
+	return post, nil
+}
 
+// GetPostByID récupère une publication par son ID
+func GetPostByID(postID int) (*Post, error) {
+	post, err := scanPost(DB.QueryRow(postSelect+" WHERE p.id = ? AND p.hidden = FALSE", postID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("publication non trouvée")
@@ -305,53 +477,94 @@ func GetPostByID(postID int) (*Post, error) {
 		return nil, err
 	}
 
+	if err := withPostAttachments([]*Post{post}); err != nil {
+		return nil, err
+	}
+	if err := withPostTags([]*Post{post}); err != nil {
+		return nil, err
+	}
+	if err := withPostPolls([]*Post{post}); err != nil {
+		return nil, err
+	}
+	if err := withPostLinks([]*Post{post}); err != nil {
+		return nil, err
+	}
+	if err := withPostBacklinks([]*Post{post}); err != nil {
+		return nil, err
+	}
+
 	return post, nil
 }
 
-// GetAllPosts récupère toutes les publications
-func GetAllPosts() ([]*Post, error) {
-	rows, err := DB.Query(`
-		SELECT p.id, p.user_id, u.username, p.title, p.content, p.category_id, c.name, p.created_at, p.updated_at
-		FROM posts p
-		JOIN users u ON p.user_id = u.id
-		JOIN categories c ON p.category_id = c.id
-		ORDER BY p.created_at DESC
-	`)
+// SetPostState enregistre l'épinglage, le verrouillage et le statut d'annonce d'une publication.
+// La date d'épinglage est renouvelée lorsque la portée change.
+func SetPostState(postID int, pinned string, locked, announcement bool) error {
+	result, err := DB.Exec(`
+		UPDATE posts SET
+			pinned_at = CASE WHEN ? = '' THEN NULL WHEN pinned = ? THEN pinned_at ELSE CURRENT_TIMESTAMP END,
+			pinned = ?, locked = ?, announcement = ?
+		WHERE id = ?
+	`, pinned, pinned, pinned, locked, announcement, postID)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
+	return expectOneRow(result, "publication non trouvée")
+}
 
-	posts := make([]*Post, 0)
-	for rows.Next() {
-		post := &Post{}
-		err := rows.Scan(
-			&post.ID, &post.UserID, &post.Username, &post.Title, &post.Content,
-			&post.CategoryID, &post.Category, &post.CreatedAt, &post.UpdatedAt,
-		)
-		if err != nil {
-			return nil, err
-		}
-		posts = append(posts, post)
+// postSortOrder retourne l'ordre SQL d'un mode de tri ; chaque mode s'appuie sur une colonne indexée
+func postSortOrder(sort string) string {
+	switch sort {
+	case PostSortActive:
+		return "p.last_activity_at DESC, p.id DESC"
+	case PostSortTop:
+		return "p.score DESC, p.id DESC"
+	case PostSortHot:
+		return "p.hot_rank DESC, p.id DESC"
+	default:
+		return "p.created_at DESC, p.id DESC"
 	}
+}
 
-	if err = rows.Err(); err != nil {
-		return nil, err
+// postFilterConditions retourne les conditions SQL (et leurs arguments) communes à toutes les requêtes
+// de GetPosts : publications visibles correspondant au filtre, hors auteurs masqués
+func postFilterConditions(filter PostFilter) (string, []interface{}) {
+	conditions := " WHERE p.hidden = FALSE"
+	args := make([]interface{}, 0)
+	if filter.CategoryID != 0 {
+		conditions += " AND (p.category_id = ? OR c.parent_id = ?)"
+		args = append(args, filter.CategoryID, filter.CategoryID)
+	}
+	if len(filter.Tags) > 0 {
+		conditions += `
+		AND p.id IN (
+			SELECT pt.post_id FROM post_tags pt
+			JOIN tags t ON pt.tag_id = t.id
+			WHERE t.name IN (` + placeholders(len(filter.Tags)) + `)`
+		for _, tag := range filter.Tags {
+			args = append(args, tag)
+		}
+		if filter.MatchAllTags {
+			conditions += " GROUP BY pt.post_id HAVING COUNT(*) = ?"
+			args = append(args, len(filter.Tags))
+		}
+		conditions += ")"
 	}
-
-	return posts, nil
+	if !filter.Since.IsZero() {
+		conditions += " AND p.created_at >= ?"
+		args = append(args, filter.Since.UTC())
+	}
+	if len(filter.ExcludedAuthors) > 0 {
+		conditions += " AND p.user_id NOT IN (" + placeholders(len(filter.ExcludedAuthors)) + ")"
+		for _, id := range filter.ExcludedAuthors {
+			args = append(args, id)
+		}
+	}
+	return conditions, args
 }
 
-// GetPostsByCategory récupère les publications par catégorie
-func GetPostsByCategory(categoryID int) ([]*Post, error) {
-	rows, err := DB.Query(`
-		SELECT p.id, p.user_id, u.username, p.title, p.content, p.category_id, c.name, p.created_at, p.updated_at
-		FROM posts p
-		JOIN users u ON p.user_id = u.id
-		JOIN categories c ON p.category_id = c.id
-		WHERE p.category_id = ?
-		ORDER BY p.created_at DESC
-	`, categoryID)
+// queryPosts exécute une requête sélectionnant des publications avec postSelect
+func queryPosts(query string, args ...interface{}) ([]*Post, error) {
+	rows, err := DB.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -359,58 +572,91 @@ func GetPostsByCategory(categoryID int) ([]*Post, error) {
 
 	posts := make([]*Post, 0)
 	for rows.Next() {
-		post := &Post{}
-		err := rows.Scan(
-			&post.ID, &post.UserID, &post.Username, &post.Title, &post.Content,
-			&post.CategoryID, &post.Category, &post.CreatedAt, &post.UpdatedAt,
-		)
+		post, err := scanPost(rows)
 		if err != nil {
 			return nil, err
 		}
 		posts = append(posts, post)
 	}
 
-	if err = rows.Err(); err != nil {
-		return nil, err
-	}
-
-	return posts, nil
+	return posts, rows.Err()
 }
 
-// GetAllCategories récupère toutes les catégories
-func GetAllCategories() ([]*Category, error) {
-	rows, err := DB.Query("SELECT id, name, description FROM categories")
-	if err != nil {
-		return nil, err
+// GetPosts récupère une page des publications visibles correspondant au filtre, selon le tri demandé.
+// Les publications épinglées pour tout le forum, ainsi que celles épinglées dans leur catégorie lorsque
+// la liste est filtrée par catégorie, sont lues à part et placées en tête de la première page,
+// les plus récemment épinglées d'abord : la pagination ne porte que sur les autres publications,
+// parcourues dans l'ordre de l'index de la colonne de tri.
+func GetPosts(filter PostFilter) ([]*Post, error) {
+	conditions, args := postFilterConditions(filter)
+
+	pinnedScopes := "'" + PinGlobal + "'"
+	if filter.CategoryID != 0 {
+		pinnedScopes += ", '" + PinCategory + "'"
 	}
-	defer rows.Close()
 
-	categories := make([]*Category, 0)
-	for rows.Next() {
-		category := &Category{}
-		err := rows.Scan(&category.ID, &category.Name, &category.Description)
+	posts := make([]*Post, 0)
+	if filter.Offset == 0 {
+		pinned, err := queryPosts(postSelect+conditions+`
+			AND p.pinned IN (`+pinnedScopes+`)
+			ORDER BY p.pinned_at DESC, p.id DESC`, args...)
 		if err != nil {
 			return nil, err
 		}
-		categories = append(categories, category)
+		posts = append(posts, pinned...)
 	}
 
-	if err = rows.Err(); err != nil {
+	page, err := queryPosts(postSelect+conditions+`
+		AND p.pinned NOT IN (`+pinnedScopes+`)
+		ORDER BY `+postSortOrder(filter.Sort)+`
+		LIMIT ? OFFSET ?`, append(args, filter.Limit, filter.Offset)...)
+	if err != nil {
 		return nil, err
 	}
+	posts = append(posts, page...)
 
-	return categories, nil
+	if err := withPostAttachments(posts); err != nil {
+		return nil, err
+	}
+	if err := withPostTags(posts); err != nil {
+		return nil, err
+	}
+	if err := withPostPolls(posts); err != nil {
+		return nil, err
+	}
+	if err := withPostLinks(posts); err != nil {
+		return nil, err
+	}
+	if err := withPostBacklinks(posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
 }
 
 // ==================================
 // Comment Operations
 // ==================================
 
-// CreateComment crée un nouveau commentaire
+// CreateComment crée un nouveau commentaire, avec sa citation et ses liens internes,
+// et met à jour la dernière activité de la publication. La citation doit avoir été vérifiée par l'appelant.
 func CreateComment(comment *Comment) (int, error) {
-	result, err := DB.Exec(
-		"INSERT INTO comments (post_id, user_id, content) VALUES (?, ?, ?)",
-		comment.PostID, comment.UserID, comment.Content,
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var quoteID interface{}
+	quoteExcerpt := ""
+	if comment.Quote != nil {
+		quoteID = comment.Quote.CommentID
+		quoteExcerpt = comment.Quote.Excerpt
+	}
+
+	result, err := tx.Exec(
+		"INSERT INTO comments (post_id, user_id, content, quote_comment_id, quote_excerpt) VALUES (?, ?, ?, ?, ?)",
+		comment.PostID, comment.UserID, comment.Content, quoteID, quoteExcerpt,
 	)
 	if err != nil {
 		return 0, err
@@ -421,16 +667,29 @@ func CreateComment(comment *Comment) (int, error) {
 		return 0, err
 	}
 
-	return int(id), nil
+	if err := linkContent(tx, LinkSourceComment, int(id), comment.Content, comment.PostID); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("UPDATE posts SET last_activity_at = CURRENT_TIMESTAMP WHERE id = ?", comment.PostID); err != nil {
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
 }
 
-// GetCommentsByPostID récupère les commentaires d'une publication
+// GetCommentsByPostID récupère les commentaires d'une publication avec leurs citations et liens internes.
+// La citation d'un commentaire masqué depuis (ou d'une publication masquée) est signalée indisponible.
 func GetCommentsByPostID(postID int) ([]*Comment, error) {
 	rows, err := DB.Query(`
-		SELECT c.id, c.post_id, c.user_id, u.username, c.content, c.created_at
+		SELECT c.id, c.post_id, c.user_id, u.username, c.content, c.created_at,
+			c.quote_comment_id, c.quote_excerpt, qp.id IS NOT NULL, COALESCE(qp.id, 0), COALESCE(qu.username, '')
 		FROM comments c
 		JOIN users u ON c.user_id = u.id
-		WHERE c.post_id = ?
+		LEFT JOIN comments qc ON qc.id = c.quote_comment_id AND qc.hidden = FALSE
+		LEFT JOIN posts qp ON qp.id = qc.post_id AND qp.hidden = FALSE
+		LEFT JOIN users qu ON qu.id = qc.user_id AND qp.id IS NOT NULL
+		WHERE c.post_id = ? AND c.hidden = FALSE
 		ORDER BY c.created_at ASC
 	`, postID)
 	if err != nil {
@@ -441,13 +700,23 @@ func GetCommentsByPostID(postID int) ([]*Comment, error) {
 	comments := make([]*Comment, 0)
 	for rows.Next() {
 		comment := &Comment{}
+		var quoteID sql.NullInt64
+		quote := &CommentQuote{}
 		err := rows.Scan(
 			&comment.ID, &comment.PostID, &comment.UserID, &comment.Username,
 			&comment.Content, &comment.CreatedAt,
+			&quoteID, &quote.Excerpt, &quote.Available, &quote.PostID, &quote.Author,
 		)
 		if err != nil {
 			return nil, err
 		}
+		if quoteID.Valid {
+			quote.CommentID = int(quoteID.Int64)
+			if !quote.Available {
+				quote.Excerpt = ""
+			}
+			comment.Quote = quote
+		}
 		comments = append(comments, comment)
 	}
 
@@ -455,6 +724,10 @@ func GetCommentsByPostID(postID int) ([]*Comment, error) {
 		return nil, err
 	}
 
+	if err := withCommentLinks(comments); err != nil {
+		return nil, err
+	}
+
 	return comments, nil
 }
 
@@ -462,9 +735,15 @@ func GetCommentsByPostID(postID int) ([]*Comment, error) {
 // Private Message Operations
 // ==================================
 
-// CreatePrivateMessage crée un nouveau message privé
+// CreatePrivateMessage crée un nouveau message privé et y rattache ses pièces jointes
 func CreatePrivateMessage(message *PrivateMessage) (int, error) {
-	result, err := DB.Exec(
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
 		"INSERT INTO private_messages (sender_id, receiver_id, content) VALUES (?, ?, ?)",
 		message.SenderID, message.ReceiverID, message.Content,
 	)
@@ -477,7 +756,11 @@ func CreatePrivateMessage(message *PrivateMessage) (int, error) {
 		return 0, err
 	}
 
-	return int(id), nil
+	if err := linkAttachments(tx, "message_id", int(id), message.SenderID, message.AttachmentIDs); err != nil {
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
 }
 
 // GetPrivateMessagesByUsers récupère les messages entre deux utilisateurs avec limite et pagination
@@ -487,7 +770,8 @@ func GetPrivateMessagesByUsers(userID1, userID2 int, limit, offset int) ([]*Priv
 		FROM private_messages pm
 		JOIN users s ON pm.sender_id = s.id
 		JOIN users r ON pm.receiver_id = r.id
-		WHERE (pm.sender_id = ? AND pm.receiver_id = ?) OR (pm.sender_id = ? AND pm.receiver_id = ?)
+		WHERE ((pm.sender_id = ? AND pm.receiver_id = ?) OR (pm.sender_id = ? AND pm.receiver_id = ?))
+			AND pm.hidden = FALSE
 		ORDER BY pm.created_at DESC
 		LIMIT ? OFFSET ?
 	`, userID1, userID2, userID2, userID1, limit, offset)
@@ -513,6 +797,10 @@ func GetPrivateMessagesByUsers(userID1, userID2 int, limit, offset int) ([]*Priv
 		return nil, err
 	}
 
+	if err := withMessageAttachments(messages); err != nil {
+		return nil, err
+	}
+
 	// Inverser l'ordre pour obtenir les plus anciens en premier
 	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
 		messages[i], messages[j] = messages[j], messages[i]
@@ -591,3 +879,12 @@ func GetTypingStatus(userID, targetUserID int) (*TypingIndicator, error) {
 
 	return indicator, nil
 }
+
+// DeleteStaleTypingIndicators supprime les indicateurs de frappe non mis à jour depuis la date donnée
+func DeleteStaleTypingIndicators(before time.Time) (int64, error) {
+	result, err := DB.Exec("DELETE FROM typing_indicators WHERE updated_at < ?", before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,139 @@
+// fichier: database/reads.go
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrReadPostNotFound est retournée lorsqu'une publication à marquer comme lue n'existe pas ou est masquée
+var ErrReadPostNotFound = errors.New("publication non trouvée")
+
+// ==================================
+// View Operations
+// ==================================
+
+// RecordPostView compte la vue d'une publication par un lecteur ('user:{id}' ou 'visitor:{jeton}').
+// Un même lecteur n'est compté qu'une fois ; retourne true si la vue a été comptée.
+func RecordPostView(postID int, viewer string) (bool, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT OR IGNORE INTO post_views (post_id, viewer) VALUES (?, ?)", postID, viewer)
+	if err != nil {
+		return false, err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return false, err
+	}
+
+	if _, err := tx.Exec("UPDATE posts SET view_count = view_count + 1 WHERE id = ?", postID); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// ==================================
+// Read Tracking Operations
+// ==================================
+
+// markReadUpsert enregistre le dernier commentaire lu sans jamais faire reculer le suivi de lecture
+const markReadUpsert = `
+	ON CONFLICT (user_id, post_id) DO UPDATE SET
+		last_comment_id = MAX(last_comment_id, excluded.last_comment_id),
+		read_at = excluded.read_at`
+
+// MarkPostRead marque une publication visible comme lue jusqu'au commentaire donné
+// (nil pour tous les commentaires actuels)
+func MarkPostRead(userID, postID int, lastCommentID *int) error {
+	// Le dernier commentaire lu ne peut dépasser le dernier commentaire de la publication
+	upTo := -1
+	if lastCommentID != nil {
+		upTo = *lastCommentID
+	}
+
+	result, err := DB.Exec(`
+		INSERT INTO post_reads (user_id, post_id, last_comment_id, read_at)
+		SELECT ?, p.id,
+			CASE WHEN ? < 0 THEN m.last_id ELSE MIN(?, m.last_id) END,
+			?
+		FROM posts p,
+			(SELECT COALESCE(MAX(id), 0) AS last_id FROM comments WHERE post_id = ?) m
+		WHERE p.id = ? AND p.hidden = FALSE
+	`+markReadUpsert, userID, upTo, upTo, time.Now().UTC(), postID, postID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return ErrReadPostNotFound
+	}
+	return nil
+}
+
+// MarkCategoryRead marque comme lues toutes les publications visibles d'une catégorie,
+// sous-catégories comprises ; retourne le nombre de publications marquées
+func MarkCategoryRead(userID, categoryID int) (int64, error) {
+	result, err := DB.Exec(`
+		INSERT INTO post_reads (user_id, post_id, last_comment_id, read_at)
+		SELECT ?, p.id,
+			COALESCE((SELECT MAX(cm.id) FROM comments cm WHERE cm.post_id = p.id), 0),
+			?
+		FROM posts p
+		JOIN categories c ON p.category_id = c.id
+		WHERE p.hidden = FALSE AND (p.category_id = ? OR c.parent_id = ?)
+	`+markReadUpsert, userID, time.Now().UTC(), categoryID, categoryID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetPostReadStates retourne, par ID de publication, le suivi de lecture d'un utilisateur.
+// Les commentaires de l'utilisateur et ceux des auteurs qu'il masque ne sont pas comptés comme non lus.
+func GetPostReadStates(userID int, postIDs []int, hiddenAuthors map[int]bool) (map[int]*PostReadState, error) {
+	states := make(map[int]*PostReadState)
+	if len(postIDs) == 0 {
+		return states, nil
+	}
+
+	hiddenFilter := ""
+	args := []interface{}{userID, userID}
+	if len(hiddenAuthors) > 0 {
+		hiddenFilter = " AND c.user_id NOT IN (" + placeholders(len(hiddenAuthors)) + ")"
+		for authorID := range hiddenAuthors {
+			args = append(args, authorID)
+		}
+	}
+	for _, id := range postIDs {
+		args = append(args, id)
+	}
+
+	rows, err := DB.Query(`
+		SELECT p.id, r.post_id IS NOT NULL, COUNT(c.id)
+		FROM posts p
+		LEFT JOIN post_reads r ON r.post_id = p.id AND r.user_id = ?
+		LEFT JOIN comments c ON c.post_id = p.id AND c.hidden = FALSE AND c.user_id != ?
+			AND c.id > COALESCE(r.last_comment_id, 0)`+hiddenFilter+`
+		WHERE p.id IN (`+placeholders(len(postIDs))+`)
+		GROUP BY p.id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int
+		state := &PostReadState{}
+		if err := rows.Scan(&postID, &state.Read, &state.UnreadComments); err != nil {
+			return nil, err
+		}
+		states[postID] = state
+	}
+
+	return states, rows.Err()
+}
@@ -0,0 +1,135 @@
+// fichier: database/relations.go
+package database
+
+// ==================================
+// Block & Mute Operations
+// ==================================
+
+// AddUserRelation bloque ou met en sourdine (selon kind) l'utilisateur cible ; sans effet si c'est déjà le cas
+func AddUserRelation(userID, targetID int, kind string) error {
+	_, err := DB.Exec(
+		"INSERT OR IGNORE INTO user_relations (user_id, target_id, kind) VALUES (?, ?, ?)",
+		userID, targetID, kind,
+	)
+	return err
+}
+
+// RemoveUserRelation débloque ou rétablit (selon kind) l'utilisateur cible
+func RemoveUserRelation(userID, targetID int, kind string) error {
+	_, err := DB.Exec(
+		"DELETE FROM user_relations WHERE user_id = ? AND target_id = ? AND kind = ?",
+		userID, targetID, kind,
+	)
+	return err
+}
+
+// GetRelatedUsers retourne les utilisateurs bloqués ou mis en sourdine (selon kind) par un utilisateur
+func GetRelatedUsers(userID int, kind string) ([]*PublicUser, error) {
+	rows, err := DB.Query(`
+		SELECT `+prefixColumns("u.", userColumns)+`
+		FROM user_relations r
+		JOIN users u ON u.id = r.target_id
+		WHERE r.user_id = ? AND r.kind = ?
+		ORDER BY r.created_at DESC
+	`, userID, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]*PublicUser, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user.Public())
+	}
+
+	return users, rows.Err()
+}
+
+// IsBlockedBetween indique si l'un des deux utilisateurs a bloqué l'autre
+func IsBlockedBetween(userID1, userID2 int) (bool, error) {
+	var count int
+	err := DB.QueryRow(`
+		SELECT COUNT(*) FROM user_relations
+		WHERE kind = ? AND ((user_id = ? AND target_id = ?) OR (user_id = ? AND target_id = ?))
+	`, RelationBlock, userID1, userID2, userID2, userID1).Scan(&count)
+	return count > 0, err
+}
+
+// GetBlockedPeers retourne les utilisateurs que l'utilisateur a bloqués ou qui l'ont bloqué
+func GetBlockedPeers(userID int) (map[int]bool, error) {
+	return queryIDSet(`
+		SELECT target_id FROM user_relations WHERE kind = ? AND user_id = ?
+		UNION
+		SELECT user_id FROM user_relations WHERE kind = ? AND target_id = ?
+	`, RelationBlock, userID, RelationBlock, userID)
+}
+
+// GetHiddenAuthors retourne les utilisateurs dont les publications et commentaires sont masqués
+// pour l'utilisateur : ceux qu'il a mis en sourdine ou bloqués
+func GetHiddenAuthors(userID int) (map[int]bool, error) {
+	return queryIDSet("SELECT target_id FROM user_relations WHERE user_id = ?", userID)
+}
+
+// GetUsersHiding retourne les utilisateurs qui ont mis en sourdine ou bloqué l'auteur donné
+// (ceux à qui ses nouvelles publications ne doivent pas être diffusées)
+func GetUsersHiding(authorID int) (map[int]bool, error) {
+	return queryIDSet("SELECT user_id FROM user_relations WHERE target_id = ?", authorID)
+}
+
+// GetOnlineBlockedPeers retourne, pour chaque utilisateur en ligne, les utilisateurs en ligne
+// avec lesquels il est en situation de blocage (dans un sens ou dans l'autre)
+func GetOnlineBlockedPeers() (map[int]map[int]bool, error) {
+	rows, err := DB.Query(`
+		SELECT r.user_id, r.target_id
+		FROM user_relations r
+		JOIN users a ON a.id = r.user_id
+		JOIN users b ON b.id = r.target_id
+		WHERE r.kind = ? AND a.online = TRUE AND b.online = TRUE
+	`, RelationBlock)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	peers := make(map[int]map[int]bool)
+	add := func(from, to int) {
+		if peers[from] == nil {
+			peers[from] = make(map[int]bool)
+		}
+		peers[from][to] = true
+	}
+	for rows.Next() {
+		var userID, targetID int
+		if err := rows.Scan(&userID, &targetID); err != nil {
+			return nil, err
+		}
+		add(userID, targetID)
+		add(targetID, userID)
+	}
+
+	return peers, rows.Err()
+}
+
+// queryIDSet exécute une requête retournant une colonne d'identifiants et les rassemble en ensemble
+func queryIDSet(query string, args ...interface{}) (map[int]bool, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids[id] = true
+	}
+
+	return ids, rows.Err()
+}
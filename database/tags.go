@@ -0,0 +1,182 @@
+// fichier: database/tags.go
+package database
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// ErrTagNotFound est retournée lorsqu'une étiquette n'existe pas
+var ErrTagNotFound = errors.New("étiquette non trouvée")
+
+// ==================================
+// Tag Operations
+// ==================================
+
+// tagPost rattache à une publication des étiquettes déjà normalisées, en créant celles qui n'existent pas
+func tagPost(tx *sql.Tx, postID int, tags []string) error {
+	for _, tag := range tags {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO tags (name) VALUES (?)", tag); err != nil {
+			return err
+		}
+		_, err := tx.Exec(
+			"INSERT OR IGNORE INTO post_tags (post_id, tag_id) SELECT ?, id FROM tags WHERE name = ?",
+			postID, tag,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withPostTags renseigne les étiquettes des publications données
+func withPostTags(posts []*Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(posts))
+	byID := make(map[int]*Post, len(posts))
+	for i, post := range posts {
+		args[i] = post.ID
+		byID[post.ID] = post
+	}
+
+	rows, err := DB.Query(`
+		SELECT pt.post_id, t.name FROM post_tags pt
+		JOIN tags t ON pt.tag_id = t.id
+		WHERE pt.post_id IN (`+placeholders(len(posts))+`)
+		ORDER BY t.name
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int
+		var name string
+		if err := rows.Scan(&postID, &name); err != nil {
+			return err
+		}
+		byID[postID].Tags = append(byID[postID].Tags, name)
+	}
+
+	return rows.Err()
+}
+
+// tagSelect sélectionne les étiquettes avec leur nombre de publications visibles
+const tagSelect = `
+	SELECT t.id, t.name, t.curated, COUNT(p.id) AS post_count
+	FROM tags t
+	LEFT JOIN post_tags pt ON pt.tag_id = t.id
+	LEFT JOIN posts p ON p.id = pt.post_id AND p.hidden = FALSE`
+
+// scanTags lit les étiquettes sélectionnées avec tagSelect
+func scanTags(rows *sql.Rows) ([]*Tag, error) {
+	defer rows.Close()
+
+	tags := make([]*Tag, 0)
+	for rows.Next() {
+		tag := &Tag{}
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Curated, &tag.PostCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+// GetPopularTags retourne les étiquettes les plus utilisées par les publications visibles
+func GetPopularTags(limit int) ([]*Tag, error) {
+	rows, err := DB.Query(tagSelect+`
+		GROUP BY t.id
+		HAVING post_count > 0
+		ORDER BY post_count DESC, t.name
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanTags(rows)
+}
+
+// SuggestTags retourne les étiquettes commençant par le préfixe donné (déjà normalisé) :
+// les étiquettes officielles d'abord, puis les plus utilisées. Les étiquettes libres
+// qui ne sont plus portées par aucune publication visible ne sont pas proposées.
+func SuggestTags(prefix string, limit int) ([]*Tag, error) {
+	rows, err := DB.Query(tagSelect+`
+		WHERE t.name LIKE ? ESCAPE '\'
+		GROUP BY t.id
+		HAVING post_count > 0 OR t.curated
+		ORDER BY t.curated DESC, post_count DESC, t.name
+		LIMIT ?
+	`, escapeLike(prefix)+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanTags(rows)
+}
+
+// GetTagByID récupère une étiquette par son ID
+func GetTagByID(tagID int) (*Tag, error) {
+	rows, err := DB.Query(tagSelect+" WHERE t.id = ? GROUP BY t.id", tagID)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := scanTags(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, ErrTagNotFound
+	}
+	return tags[0], nil
+}
+
+// CurateTag crée une étiquette officielle, ou rend officielle une étiquette existante
+func CurateTag(name string) (int, error) {
+	var tagID int
+	err := DB.QueryRow(`
+		INSERT INTO tags (name, curated) VALUES (?, TRUE)
+		ON CONFLICT(name) DO UPDATE SET curated = TRUE
+		RETURNING id
+	`, name).Scan(&tagID)
+	return tagID, err
+}
+
+// UncurateTag retire le statut officiel d'une étiquette sans la retirer des publications
+func UncurateTag(tagID int) error {
+	result, err := DB.Exec("UPDATE tags SET curated = FALSE WHERE id = ?", tagID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return ErrTagNotFound
+	}
+	return nil
+}
+
+// DeleteTag supprime une étiquette et la retire de toutes les publications
+func DeleteTag(tagID int) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM post_tags WHERE tag_id = ?", tagID); err != nil {
+		return err
+	}
+	result, err := tx.Exec("DELETE FROM tags WHERE id = ?", tagID)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return ErrTagNotFound
+	}
+
+	return tx.Commit()
+}
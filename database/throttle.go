@@ -0,0 +1,241 @@
+// fichier: database/throttle.go
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"realtimeforum/config"
+	"strings"
+	"time"
+)
+
+// ==================================
+// Login Throttle Operations
+// ==================================
+
+// LoginThrottleKeys calcule les clés de limitation d'une tentative de connexion.
+// Lorsque l'identifiant correspond à un compte, la clé porte sur l'ID utilisateur afin
+// que l'email et le nom d'utilisateur partagent le même compteur.
+func LoginThrottleKeys(identifier, ip string) (accountKey, ipKey string, userID *int) {
+	var user *User
+	var err error
+	if strings.Contains(identifier, "@") {
+		user, err = GetUserByEmail(identifier)
+	} else {
+		user, err = GetUserByUsername(identifier)
+	}
+
+	if err == nil {
+		id := user.ID
+		return AccountThrottleKey(id), IPThrottleKey(ip), &id
+	}
+	return "ident:" + strings.ToLower(strings.TrimSpace(identifier)), IPThrottleKey(ip), nil
+}
+
+// AccountThrottleKey retourne la clé de limitation d'un compte existant
+func AccountThrottleKey(userID int) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// IPThrottleKey retourne la clé de limitation d'une adresse IP
+func IPThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// GetLoginLockout retourne la fin du verrouillage le plus long parmi les clés données
+// (valeur nulle si aucune n'est verrouillée)
+func GetLoginLockout(keys ...string) (time.Time, error) {
+	var lockedUntil time.Time
+	now := time.Now()
+
+	for _, key := range keys {
+		var until sql.NullTime
+		err := DB.QueryRow("SELECT locked_until FROM login_throttles WHERE key = ?", key).Scan(&until)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				continue
+			}
+			return time.Time{}, err
+		}
+		if until.Valid && until.Time.After(now) && until.Time.After(lockedUntil) {
+			lockedUntil = until.Time
+		}
+	}
+
+	return lockedUntil, nil
+}
+
+// RecordLoginFailure journalise un échec de connexion, incrémente les compteurs du compte
+// et de l'IP, et retourne la fin du verrouillage éventuellement déclenché
+func RecordLoginFailure(accountKey, ipKey, identifier string, userID *int, ip, reason string) (time.Time, error) {
+	_, err := DB.Exec(
+		"INSERT INTO login_failures (identifier, user_id, ip, reason, created_at) VALUES (?, ?, ?, ?, ?)",
+		identifier, userID, ip, reason, time.Now(),
+	)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	accountLock, err := incrementLoginThrottle(accountKey, config.App.LoginMaxFailuresPerAccount)
+	if err != nil {
+		return time.Time{}, err
+	}
+	ipLock, err := incrementLoginThrottle(ipKey, config.App.LoginMaxFailuresPerIP)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if ipLock.After(accountLock) {
+		return ipLock, nil
+	}
+	return accountLock, nil
+}
+
+// incrementLoginThrottle incrémente le compteur d'une clé et applique un verrouillage
+// exponentiel une fois le seuil atteint : base, 2×base, 4×base… plafonné à LoginLockoutMax
+func incrementLoginThrottle(key string, threshold int) (time.Time, error) {
+	now := time.Now()
+	windowStart := now.Add(-config.App.LoginFailureWindow)
+
+	// Le compteur repart de 1 si le dernier échec et la fin du dernier verrouillage
+	// sont hors de la fenêtre ; sinon la durée de verrouillage continue de croître
+	_, err := DB.Exec(`
+		INSERT INTO login_throttles (key, failures, last_failure_at) VALUES (?, 1, ?)
+		ON CONFLICT(key) DO UPDATE SET
+			failures = CASE
+				WHEN last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?) THEN 1
+				ELSE failures + 1
+			END,
+			last_failure_at = excluded.last_failure_at
+	`, key, now, windowStart, windowStart)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var failures int
+	err = DB.QueryRow("SELECT failures FROM login_throttles WHERE key = ?", key).Scan(&failures)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if failures < threshold {
+		return time.Time{}, nil
+	}
+
+	lockout := config.App.LoginLockoutBase
+	for i := threshold; i < failures && lockout < config.App.LoginLockoutMax; i++ {
+		lockout *= 2
+	}
+	if lockout > config.App.LoginLockoutMax {
+		lockout = config.App.LoginLockoutMax
+	}
+
+	lockedUntil := now.Add(lockout)
+	_, err = DB.Exec("UPDATE login_throttles SET locked_until = ? WHERE key = ?", lockedUntil, key)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return lockedUntil, nil
+}
+
+// ResetLoginThrottle efface le compteur d'une clé (après une connexion réussie)
+func ResetLoginThrottle(key string) error {
+	_, err := DB.Exec("DELETE FROM login_throttles WHERE key = ?", key)
+	return err
+}
+
+// UnlockUser lève le verrouillage et remet à zéro le compteur d'échecs d'un compte
+func UnlockUser(userID int) error {
+	return ResetLoginThrottle(AccountThrottleKey(userID))
+}
+
+// GetActiveLockouts récupère les verrouillages en cours
+func GetActiveLockouts() ([]*LoginThrottle, error) {
+	rows, err := DB.Query(`
+		SELECT key, failures, last_failure_at, locked_until
+		FROM login_throttles
+		WHERE locked_until > ?
+		ORDER BY locked_until DESC
+	`, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	throttles := make([]*LoginThrottle, 0)
+	for rows.Next() {
+		throttle := &LoginThrottle{}
+		var lockedUntil sql.NullTime
+		err := rows.Scan(&throttle.Key, &throttle.Failures, &throttle.LastFailureAt, &lockedUntil)
+		if err != nil {
+			return nil, err
+		}
+		if lockedUntil.Valid {
+			throttle.LockedUntil = &lockedUntil.Time
+		}
+		throttles = append(throttles, throttle)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return throttles, nil
+}
+
+// GetLoginFailures récupère le journal des échecs de connexion, filtré par identifiant et/ou IP
+func GetLoginFailures(identifier, ip string, limit, offset int) ([]*LoginFailure, error) {
+	query := "SELECT id, identifier, user_id, ip, reason, created_at FROM login_failures WHERE 1 = 1"
+	args := make([]interface{}, 0)
+	if identifier != "" {
+		query += " AND identifier = ?"
+		args = append(args, identifier)
+	}
+	if ip != "" {
+		query += " AND ip = ?"
+		args = append(args, ip)
+	}
+	query += " ORDER BY created_at DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	failures := make([]*LoginFailure, 0)
+	for rows.Next() {
+		failure := &LoginFailure{}
+		var userID sql.NullInt64
+		err := rows.Scan(&failure.ID, &failure.Identifier, &userID, &failure.IP, &failure.Reason, &failure.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if userID.Valid {
+			id := int(userID.Int64)
+			failure.UserID = &id
+		}
+		failures = append(failures, failure)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return failures, nil
+}
+
+// DeleteStaleLoginThrottles supprime les compteurs sans échec ni verrouillage récent
+func DeleteStaleLoginThrottles() (int64, error) {
+	windowStart := time.Now().Add(-config.App.LoginFailureWindow)
+	result, err := DB.Exec(
+		"DELETE FROM login_throttles WHERE last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)",
+		windowStart, windowStart,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
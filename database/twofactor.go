@@ -0,0 +1,296 @@
+// fichier: database/twofactor.go
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"realtimeforum/config"
+	"realtimeforum/totp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Nombre de codes de récupération générés lors de l'activation de la 2FA
+const recoveryCodeCount = 10
+
+// ErrTOTPAlreadyEnabled est retournée lorsqu'un secret est demandé alors que la 2FA est déjà activée
+var ErrTOTPAlreadyEnabled = errors.New("authentification à deux facteurs déjà activée")
+
+// ==================================
+// TOTP Operations
+// ==================================
+
+// SetPendingTOTPSecret enregistre un secret TOTP en attente de confirmation.
+// Le secret n'est actif qu'après l'appel à EnableTOTP.
+func SetPendingTOTPSecret(userID int, secret string) error {
+	result, err := DB.Exec(
+		"UPDATE users SET totp_secret = ?, totp_last_step = 0 WHERE id = ? AND totp_enabled = FALSE",
+		secret, userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrTOTPAlreadyEnabled
+	}
+	return nil
+}
+
+// GetTOTPSecret récupère le secret TOTP (actif ou en attente) d'un utilisateur
+func GetTOTPSecret(userID int) (string, bool, error) {
+	var secret sql.NullString
+	var enabled bool
+	err := DB.QueryRow(
+		"SELECT totp_secret, totp_enabled FROM users WHERE id = ?",
+		userID,
+	).Scan(&secret, &enabled)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", false, errors.New("utilisateur non trouvé")
+		}
+		return "", false, err
+	}
+	return secret.String, enabled, nil
+}
+
+// VerifyTOTPCode vérifie un code TOTP pour un utilisateur et empêche la réutilisation
+// d'un code déjà accepté (ou d'un code plus ancien)
+func VerifyTOTPCode(userID int, code string) (bool, error) {
+	secret, _, err := GetTOTPSecret(userID)
+	if err != nil {
+		return false, err
+	}
+	if secret == "" {
+		return false, nil
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now(), 1)
+	if !ok {
+		return false, nil
+	}
+
+	// Mise à jour conditionnelle : échoue si cette période a déjà été consommée
+	result, err := DB.Exec(
+		"UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?",
+		step, userID, step,
+	)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// EnableTOTP active la 2FA pour un utilisateur et génère ses codes de récupération
+func EnableTOTP(userID int) ([]string, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE users SET totp_enabled = TRUE WHERE id = ? AND totp_secret IS NOT NULL", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP désactive la 2FA, efface le secret et supprime les codes de récupération
+func DisableTOTP(userID int) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0 WHERE id = ?", userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ==================================
+// Recovery Code Operations
+// ==================================
+
+// RegenerateRecoveryCodes remplace les codes de récupération d'un utilisateur
+func RegenerateRecoveryCodes(userID int) ([]string, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	codes, err := replaceRecoveryCodes(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// replaceRecoveryCodes supprime les anciens codes et en insère de nouveaux (seuls les hachés sont stockés)
+func replaceRecoveryCodes(tx *sql.Tx, userID int) ([]string, error) {
+	_, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.Exec(
+			"INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)",
+			userID, hashRecoveryCode(code),
+		)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+// UseRecoveryCode consomme un code de récupération s'il est valide et inutilisé
+func UseRecoveryCode(userID int, code string) (bool, error) {
+	result, err := DB.Exec(
+		"UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+		time.Now(), userID, hashRecoveryCode(code),
+	)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// CountRemainingRecoveryCodes compte les codes de récupération encore utilisables
+func CountRemainingRecoveryCodes(userID int) (int, error) {
+	var count int
+	err := DB.QueryRow(
+		"SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL",
+		userID,
+	).Scan(&count)
+	return count, err
+}
+
+// generateRecoveryCode génère un code de récupération lisible de la forme xxxxx-xxxxx
+func generateRecoveryCode() (string, error) {
+	raw := make([]byte, 5)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	code := hex.EncodeToString(raw)
+	return code[:5] + "-" + code[5:], nil
+}
+
+// hashRecoveryCode normalise puis hache un code de récupération.
+// Les codes étant aléatoires et à haute entropie, un SHA-256 suffit.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// ==================================
+// Partial Session Operations
+// ==================================
+
+// Nombre maximal de tentatives de second facteur pour une session partielle
+const maxPartialSessionAttempts = 5
+
+// CreatePartialSession crée une session partielle de courte durée après vérification du mot de passe
+func CreatePartialSession(userID int) (*PartialSession, error) {
+	partial := &PartialSession{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(config.App.TwoFactorChallengeTTL),
+	}
+
+	_, err := DB.Exec(
+		"INSERT INTO partial_sessions (id, user_id, expires_at) VALUES (?, ?, ?)",
+		partial.ID, partial.UserID, partial.ExpiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return partial, nil
+}
+
+// ConsumePartialSessionAttempt récupère une session partielle valide en comptabilisant une tentative.
+// La vérification et l'incrément forment une seule requête, pour que des tentatives parallèles
+// ne puissent pas dépasser la limite. Une session expirée ou épuisée est supprimée.
+func ConsumePartialSessionAttempt(id string) (*PartialSession, error) {
+	partial := &PartialSession{ID: id}
+	err := DB.QueryRow(`
+		UPDATE partial_sessions SET attempts = attempts + 1
+		WHERE id = ? AND attempts < ? AND expires_at > ?
+		RETURNING user_id, attempts, expires_at
+	`, id, maxPartialSessionAttempts, time.Now()).Scan(&partial.UserID, &partial.Attempts, &partial.ExpiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			_, _ = DB.Exec("DELETE FROM partial_sessions WHERE id = ?", id)
+			return nil, errors.New("session partielle expirée")
+		}
+		return nil, err
+	}
+
+	return partial, nil
+}
+
+// DeletePartialSession supprime une session partielle
+func DeletePartialSession(id string) error {
+	_, err := DB.Exec("DELETE FROM partial_sessions WHERE id = ?", id)
+	return err
+}
+
+// DeleteExpiredPartialSessions supprime les sessions partielles expirées
+func DeleteExpiredPartialSessions() (int64, error) {
+	result, err := DB.Exec("DELETE FROM partial_sessions WHERE expires_at < ?", time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
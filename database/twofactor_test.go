@@ -0,0 +1,90 @@
+// fichier: database/twofactor_test.go
+package database
+
+import (
+	"realtimeforum/totp"
+	"testing"
+	"time"
+)
+
+// twoFactorUser crée un utilisateur dont la 2FA est activée avec un nouveau secret
+func twoFactorUser(t *testing.T) (int, string) {
+	t.Helper()
+
+	userID, err := CreateUser(UserDTO{
+		Username: "alice", Age: 30, Gender: "F", FirstName: "Alice", LastName: "Martin",
+		Email: "alice@example.com", Password: "motdepasse-alice",
+	})
+	if err != nil {
+		t.Fatalf("création de l'utilisateur: %v", err)
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatalf("génération du secret: %v", err)
+	}
+	if _, err := DB.Exec("UPDATE users SET totp_secret = ?, totp_enabled = TRUE WHERE id = ?", secret, userID); err != nil {
+		t.Fatalf("activation de la 2FA: %v", err)
+	}
+	return userID, secret
+}
+
+func TestVerifyTOTPCodeRejectsReplay(t *testing.T) {
+	setupTestDB(t)
+	userID, secret := twoFactorUser(t)
+
+	step := totp.Step(time.Now())
+	code, err := totp.Code(secret, step)
+	if err != nil {
+		t.Fatalf("Code: %v", err)
+	}
+
+	if ok, err := VerifyTOTPCode(userID, code); err != nil || !ok {
+		t.Fatalf("premier usage du code refusé (ok=%v, err=%v)", ok, err)
+	}
+	if ok, err := VerifyTOTPCode(userID, code); err != nil || ok {
+		t.Errorf("la réutilisation du code doit être refusée (ok=%v, err=%v)", ok, err)
+	}
+
+	// Un code d'une période antérieure, bien que dans la tolérance d'horloge, est aussi refusé
+	previous, err := totp.Code(secret, step-1)
+	if err != nil {
+		t.Fatalf("Code: %v", err)
+	}
+	if ok, err := VerifyTOTPCode(userID, previous); err != nil || ok {
+		t.Errorf("un code plus ancien que le dernier accepté doit être refusé (ok=%v, err=%v)", ok, err)
+	}
+}
+
+func TestConsumePartialSessionAttemptLimit(t *testing.T) {
+	setupTestDB(t)
+	userID, _ := twoFactorUser(t)
+
+	partial, err := CreatePartialSession(userID)
+	if err != nil {
+		t.Fatalf("création de la session partielle: %v", err)
+	}
+	for i := 1; i <= maxPartialSessionAttempts; i++ {
+		consumed, err := ConsumePartialSessionAttempt(partial.ID)
+		if err != nil {
+			t.Fatalf("tentative %d refusée: %v", i, err)
+		}
+		if consumed.UserID != userID || consumed.Attempts != i {
+			t.Errorf("tentative %d: utilisateur %d, %d tentatives comptées", i, consumed.UserID, consumed.Attempts)
+		}
+	}
+	if _, err := ConsumePartialSessionAttempt(partial.ID); err == nil {
+		t.Errorf("la session partielle doit être épuisée après %d tentatives", maxPartialSessionAttempts)
+	}
+
+	// Une session partielle expirée est refusée dès la première tentative
+	expired, err := CreatePartialSession(userID)
+	if err != nil {
+		t.Fatalf("création de la session partielle: %v", err)
+	}
+	if _, err := DB.Exec("UPDATE partial_sessions SET expires_at = ? WHERE id = ?", time.Now().Add(-time.Minute), expired.ID); err != nil {
+		t.Fatalf("expiration de la session partielle: %v", err)
+	}
+	if _, err := ConsumePartialSessionAttempt(expired.ID); err == nil {
+		t.Errorf("une session partielle expirée doit être refusée")
+	}
+}
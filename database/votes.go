@@ -0,0 +1,111 @@
+// fichier: database/votes.go
+package database
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// ErrVotePostNotFound est retournée lorsqu'une publication à voter n'existe pas ou est masquée
+var ErrVotePostNotFound = errors.New("publication non trouvée")
+
+// Paramètres du rang « hot » : une publication doit recevoir dix fois plus de votes
+// pour devancer une publication plus récente de hotRankPeriod
+var hotRankEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+const hotRankPeriod = 45000 // secondes (12 h 30)
+
+// hotRank calcule le rang « hot » d'une publication : l'ordre de grandeur du score, signé,
+// augmenté de l'ancienneté de la publication. Le rang ne dépend que du score et de la date de création :
+// il n'est recalculé qu'à chaque vote, et les publications anciennes sont naturellement dépassées.
+func hotRank(score int, createdAt time.Time) float64 {
+	order := math.Log10(math.Max(math.Abs(float64(score)), 1))
+	sign := 0.0
+	if score > 0 {
+		sign = 1
+	} else if score < 0 {
+		sign = -1
+	}
+	return sign*order + createdAt.Sub(hotRankEpoch).Seconds()/hotRankPeriod
+}
+
+// ==================================
+// Vote Operations
+// ==================================
+
+// SetPostVote enregistre le vote d'un utilisateur sur une publication visible (+1, -1, ou 0 pour le retirer),
+// puis recalcule le score et le rang « hot » de la publication ; retourne le nouveau score
+func SetPostVote(postID, userID, value int) (int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if value == 0 {
+		_, err = tx.Exec("DELETE FROM post_votes WHERE post_id = ? AND user_id = ?", postID, userID)
+	} else {
+		_, err = tx.Exec(`
+			INSERT INTO post_votes (post_id, user_id, value, created_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT (post_id, user_id) DO UPDATE SET value = excluded.value, created_at = excluded.created_at
+		`, postID, userID, value, time.Now().UTC())
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE posts SET score = (SELECT COALESCE(SUM(value), 0) FROM post_votes WHERE post_id = ?)
+		WHERE id = ? AND hidden = FALSE
+	`, postID, postID)
+	if err != nil {
+		return 0, err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return 0, ErrVotePostNotFound
+	}
+
+	var score int
+	var createdAt time.Time
+	if err := tx.QueryRow("SELECT score, created_at FROM posts WHERE id = ?", postID).Scan(&score, &createdAt); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("UPDATE posts SET hot_rank = ? WHERE id = ?", hotRank(score, createdAt), postID); err != nil {
+		return 0, err
+	}
+
+	return score, tx.Commit()
+}
+
+// GetPostVotes retourne, par ID de publication, le vote d'un utilisateur parmi les publications données
+func GetPostVotes(userID int, postIDs []int) (map[int]int, error) {
+	votes := make(map[int]int)
+	if len(postIDs) == 0 {
+		return votes, nil
+	}
+
+	args := []interface{}{userID}
+	for _, id := range postIDs {
+		args = append(args, id)
+	}
+
+	rows, err := DB.Query(`
+		SELECT post_id, value FROM post_votes
+		WHERE user_id = ? AND post_id IN (`+placeholders(len(postIDs))+`)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID, value int
+		if err := rows.Scan(&postID, &value); err != nil {
+			return nil, err
+		}
+		votes[postID] = value
+	}
+
+	return votes, rows.Err()
+}
@@ -0,0 +1,292 @@
+// fichier: database/webhooks.go
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrWebhookNotFound est retournée lorsqu'un webhook n'existe pas (ou plus)
+var ErrWebhookNotFound = errors.New("webhook non trouvé")
+
+// ==================================
+// Webhook Operations
+// ==================================
+
+// CreateWebhook enregistre un webhook ; un secret est généré s'il n'est pas fourni
+func CreateWebhook(createdBy int, req WebhookRequest) (*Webhook, error) {
+	secret := req.Secret
+	if secret == "" {
+		random, err := generateToken(24)
+		if err != nil {
+			return nil, err
+		}
+		secret = "whsec_" + random
+	}
+
+	webhook := &Webhook{
+		URL:       req.URL,
+		Secret:    secret,
+		Events:    req.Events,
+		Active:    true,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+
+	result, err := DB.Exec(
+		"INSERT INTO webhooks (url, secret, events, active, created_by, created_at) VALUES (?, ?, ?, TRUE, ?, ?)",
+		webhook.URL, webhook.Secret, strings.Join(webhook.Events, ","), createdBy, webhook.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	webhook.ID = int(id)
+
+	return webhook, nil
+}
+
+// scanWebhook lit une ligne de la table webhooks
+func scanWebhook(scanner interface{ Scan(...interface{}) error }) (*Webhook, error) {
+	webhook := &Webhook{}
+	var events string
+	var createdBy sql.NullInt64
+
+	err := scanner.Scan(&webhook.ID, &webhook.URL, &webhook.Secret, &events, &webhook.Active, &createdBy, &webhook.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.Events = strings.Split(events, ",")
+	if createdBy.Valid {
+		webhook.CreatedBy = int(createdBy.Int64)
+	}
+
+	return webhook, nil
+}
+
+// GetWebhooks récupère tous les webhooks
+func GetWebhooks() ([]*Webhook, error) {
+	rows, err := DB.Query("SELECT id, url, secret, events, active, created_by, created_at FROM webhooks ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := make([]*Webhook, 0)
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+// GetWebhookByID récupère un webhook par son ID
+func GetWebhookByID(id int) (*Webhook, error) {
+	row := DB.QueryRow("SELECT id, url, secret, events, active, created_by, created_at FROM webhooks WHERE id = ?", id)
+
+	webhook, err := scanWebhook(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+// SetWebhookActive active ou désactive un webhook
+func SetWebhookActive(id int, active bool) error {
+	result, err := DB.Exec("UPDATE webhooks SET active = ? WHERE id = ?", active, id)
+	if err != nil {
+		return err
+	}
+	return expectOneRow(result, "webhook non trouvé")
+}
+
+// DeleteWebhook supprime un webhook et son journal de livraisons
+func DeleteWebhook(id int) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = ?", id); err != nil {
+		return err
+	}
+	result, err := tx.Exec("DELETE FROM webhooks WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if err := expectOneRow(result, "webhook non trouvé"); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// expectOneRow retourne une erreur si la requête n'a modifié aucune ligne
+func expectOneRow(result sql.Result, message string) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errors.New(message)
+	}
+	return nil
+}
+
+// ==================================
+// Webhook Delivery Operations
+// ==================================
+
+// EnqueueWebhookEvent ajoute une livraison en file pour chaque webhook actif abonné à l'événement
+func EnqueueWebhookEvent(event, payload string) (int64, error) {
+	result, err := DB.Exec(`
+		INSERT INTO webhook_deliveries (webhook_id, event, payload, status, next_attempt_at, created_at)
+		SELECT id, ?, ?, ?, ?, ?
+		FROM webhooks
+		WHERE active = TRUE AND (',' || events || ',') LIKE '%,' || ? || ',%'
+	`, event, payload, DeliveryPending, time.Now(), time.Now(), event)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Colonnes lues pour une livraison de webhook
+const deliveryColumns = `id, webhook_id, event, payload, status, attempts, next_attempt_at,
+	last_attempt_at, response_status, last_error, redelivery_of, created_at`
+
+// scanDelivery lit une ligne de la table webhook_deliveries
+func scanDelivery(scanner interface{ Scan(...interface{}) error }) (*WebhookDelivery, error) {
+	delivery := &WebhookDelivery{}
+	var lastAttemptAt sql.NullTime
+	var responseStatus, redeliveryOf sql.NullInt64
+	var lastError sql.NullString
+
+	err := scanner.Scan(
+		&delivery.ID, &delivery.WebhookID, &delivery.Event, &delivery.Payload, &delivery.Status,
+		&delivery.Attempts, &delivery.NextAttemptAt, &lastAttemptAt, &responseStatus, &lastError,
+		&redeliveryOf, &delivery.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if lastAttemptAt.Valid {
+		delivery.LastAttemptAt = &lastAttemptAt.Time
+	}
+	if responseStatus.Valid {
+		status := int(responseStatus.Int64)
+		delivery.ResponseStatus = &status
+	}
+	if redeliveryOf.Valid {
+		original := int(redeliveryOf.Int64)
+		delivery.RedeliveryOf = &original
+	}
+	delivery.LastError = lastError.String
+
+	return delivery, nil
+}
+
+// queryDeliveries exécute une requête retournant des livraisons
+func queryDeliveries(query string, args ...interface{}) ([]*WebhookDelivery, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := make([]*WebhookDelivery, 0)
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// GetDueWebhookDeliveries récupère les livraisons en attente dont l'échéance est atteinte
+func GetDueWebhookDeliveries(limit int) ([]*WebhookDelivery, error) {
+	return queryDeliveries(
+		"SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE status = ? AND next_attempt_at <= ? ORDER BY next_attempt_at LIMIT ?",
+		DeliveryPending, time.Now(), limit,
+	)
+}
+
+// GetWebhookDeliveries récupère le journal des livraisons d'un webhook, du plus récent au plus ancien
+func GetWebhookDeliveries(webhookID, limit, offset int) ([]*WebhookDelivery, error) {
+	return queryDeliveries(
+		"SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ? OFFSET ?",
+		webhookID, limit, offset,
+	)
+}
+
+// RecordWebhookAttempt enregistre le résultat d'une tentative de livraison
+func RecordWebhookAttempt(delivery *WebhookDelivery) error {
+	_, err := DB.Exec(`
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, next_attempt_at = ?, last_attempt_at = ?, response_status = ?, last_error = ?
+		WHERE id = ?
+	`, delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.LastAttemptAt,
+		delivery.ResponseStatus, delivery.LastError, delivery.ID)
+	return err
+}
+
+// RedeliverWebhookDelivery remet en file une copie d'une livraison existante
+func RedeliverWebhookDelivery(deliveryID int) (*WebhookDelivery, error) {
+	now := time.Now()
+	result, err := DB.Exec(`
+		INSERT INTO webhook_deliveries (webhook_id, event, payload, status, next_attempt_at, redelivery_of, created_at)
+		SELECT webhook_id, event, payload, ?, ?, id, ?
+		FROM webhook_deliveries
+		WHERE id = ?
+	`, DeliveryPending, now, now, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if err := expectOneRow(result, "livraison non trouvée"); err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	return scanDelivery(DB.QueryRow("SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id = ?", id))
+}
+
+// DeleteOldWebhookDeliveries supprime les livraisons terminées antérieures à la date donnée
+func DeleteOldWebhookDeliveries(before time.Time) (int64, error) {
+	result, err := DB.Exec("DELETE FROM webhook_deliveries WHERE status != ? AND created_at < ?", DeliveryPending, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,96 @@
+// fichier: handlers/admin.go
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"realtimeforum/database"
+	"realtimeforum/middleware"
+	"strconv"
+	"strings"
+)
+
+// GetLockoutsHandler récupère les verrouillages de connexion en cours
+func GetLockoutsHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	lockouts, err := database.GetActiveLockouts()
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des verrouillages", http.StatusInternalServerError)
+		return
+	}
+
+	// Retourner les verrouillages
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lockouts)
+}
+
+// GetLoginFailuresHandler récupère le journal des échecs de connexion
+func GetLoginFailuresHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit, offset := parsePagination(r, 50)
+	identifier := r.URL.Query().Get("identifier")
+	ip := r.URL.Query().Get("ip")
+
+	failures, err := database.GetLoginFailures(identifier, ip, limit, offset)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération du journal", http.StatusInternalServerError)
+		return
+	}
+
+	// Retourner le journal
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(failures)
+}
+
+// UnlockUserHandler lève le verrouillage de connexion d'un compte
+func UnlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	adminID, _ := middleware.GetUserID(r)
+
+	// Extraire l'ID de l'utilisateur de l'URL
+	// Format attendu: /api/admin/users/{id}/unlock
+	pathParts := strings.Split(r.URL.Path, "/")
+	if len(pathParts) < 6 {
+		http.Error(w, "URL invalide", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := strconv.Atoi(pathParts[4])
+	if err != nil {
+		http.Error(w, "ID utilisateur invalide", http.StatusBadRequest)
+		return
+	}
+
+	// Vérifier que l'utilisateur existe
+	_, err = database.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "Utilisateur non trouvé", http.StatusNotFound)
+		return
+	}
+
+	if err := database.UnlockUser(userID); err != nil {
+		http.Error(w, "Erreur lors du déverrouillage du compte", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Compte ID=%d déverrouillé par l'administrateur ID=%d", userID, adminID)
+	recordAudit(r, auditTarget(database.AuditUserUnlocked, database.AuditTargetUser, userID, nil))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"message": "Compte déverrouillé"}`))
+}
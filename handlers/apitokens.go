@@ -0,0 +1,157 @@
+// fichier: handlers/apitokens.go
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"realtimeforum/database"
+	"realtimeforum/middleware"
+	"strconv"
+	"strings"
+)
+
+// Limites appliquées à la création de jetons d'accès
+const (
+	maxAPITokenNameLength = 64
+	maxAPITokenLifetime   = 365 // jours
+)
+
+// isValidScope indique si la portée fait partie des portées connues
+func isValidScope(scope string) bool {
+	for _, valid := range database.ValidScopes {
+		if scope == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAPITokensHandler liste les jetons d'accès de l'utilisateur courant
+func GetAPITokensHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	tokens, err := database.GetAPITokensByUser(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des jetons", http.StatusInternalServerError)
+		return
+	}
+
+	// Retourner les jetons (sans leur valeur)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// CreateAPITokenHandler crée un jeton d'accès ; sa valeur n'est retournée qu'une seule fois
+func CreateAPITokenHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	// Décoder la requête
+	var req database.APITokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Requête invalide", http.StatusBadRequest)
+		return
+	}
+
+	// Valider les données
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > maxAPITokenNameLength {
+		http.Error(w, "Le nom du jeton est requis (64 caractères maximum)", http.StatusBadRequest)
+		return
+	}
+	if len(req.Scopes) == 0 {
+		http.Error(w, "Au moins une portée est requise", http.StatusBadRequest)
+		return
+	}
+	seen := make(map[string]bool)
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !isValidScope(scope) {
+			http.Error(w, "Portée inconnue: "+scope, http.StatusBadRequest)
+			return
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	req.Scopes = scopes
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > maxAPITokenLifetime {
+		http.Error(w, "Durée de validité invalide (1 à 365 jours, 0 pour aucune expiration)", http.StatusBadRequest)
+		return
+	}
+
+	token, value, err := database.CreateAPIToken(userID, req)
+	if err != nil {
+		log.Printf("Erreur lors de la création du jeton d'accès: %v", err)
+		http.Error(w, "Erreur lors de la création du jeton", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Jeton d'accès ID=%d créé pour l'utilisateur ID=%d", token.ID, userID)
+	recordAudit(r, auditTarget(database.AuditTokenCreated, database.AuditTargetToken, token.ID,
+		map[string]interface{}{"name": token.Name, "scopes": token.Scopes}))
+
+	// Retourner le jeton et sa valeur en clair
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token": token,
+		"value": value,
+	})
+}
+
+// RevokeAPITokenHandler révoque un jeton d'accès de l'utilisateur courant
+func RevokeAPITokenHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	// Extraire l'ID du jeton de l'URL
+	tokenID, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/tokens/"))
+	if err != nil {
+		http.Error(w, "ID de jeton invalide", http.StatusBadRequest)
+		return
+	}
+
+	if err := database.RevokeAPIToken(userID, tokenID); err != nil {
+		http.Error(w, "Jeton non trouvé", http.StatusNotFound)
+		return
+	}
+
+	log.Printf("Jeton d'accès ID=%d révoqué par l'utilisateur ID=%d", tokenID, userID)
+	recordAudit(r, auditTarget(database.AuditTokenRevoked, database.AuditTargetToken, tokenID, nil))
+	w.WriteHeader(http.StatusNoContent)
+}
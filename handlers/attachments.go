@@ -0,0 +1,302 @@
+// fichier: handlers/attachments.go
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"realtimeforum/config"
+	"realtimeforum/database"
+	"realtimeforum/media"
+	"realtimeforum/middleware"
+	"realtimeforum/storage"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Nom du champ de formulaire portant le fichier joint
+const attachmentFormField = "file"
+
+// Côté maximal (en pixels) des miniatures générées pour les images jointes
+const attachmentThumbnailSide = 320
+
+// Longueur maximale conservée pour le nom d'un fichier joint
+const maxFilenameLength = 255
+
+// attachmentTypes liste les types acceptés, tels que détectés d'après le contenu du fichier
+var attachmentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"application/zip": true,
+	"text/plain":      true,
+}
+
+// UploadAttachmentHandler enregistre un fichier joint (formulaire multipart, champ "file").
+// La pièce jointe est ensuite rattachée à une publication ou à un message via son ID.
+func UploadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	// Lire le fichier envoyé, sans jamais dépasser la taille autorisée
+	maxBytes := int64(config.App.AttachmentMaxBytes)
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+multipartOverhead)
+	filename, data, err := readMultipartFile(r, attachmentFormField, maxBytes)
+	if err != nil {
+		if isUploadTooLarge(err) {
+			http.Error(w, "Fichier trop volumineux", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Fichier \"file\" manquant ou formulaire invalide", http.StatusBadRequest)
+		return
+	}
+
+	// Déterminer le type réel du contenu, sans tenir compte de l'extension ni du type annoncé
+	contentType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	if !attachmentTypes[contentType] {
+		http.Error(w, "Type de fichier non autorisé", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// Vérifier le quota avant de stocker quoi que ce soit (il est vérifié à nouveau à l'insertion)
+	quota := int64(config.App.AttachmentQuotaBytes)
+	used, err := database.GetAttachmentUsage(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de l'enregistrement de la pièce jointe", http.StatusInternalServerError)
+		return
+	}
+	if used+int64(len(data)) > quota {
+		http.Error(w, database.ErrAttachmentQuotaExceeded.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	key, err := database.NewAttachmentKey(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de l'enregistrement de la pièce jointe", http.StatusInternalServerError)
+		return
+	}
+	attachment := &database.Attachment{
+		UserID:      userID,
+		StorageKey:  key,
+		Filename:    sanitizeFilename(filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+	}
+
+	if err := storage.Default.Put(key, bytes.NewReader(data)); err != nil {
+		log.Printf("Erreur lors de l'enregistrement de la pièce jointe: %v", err)
+		http.Error(w, "Erreur lors de l'enregistrement de la pièce jointe", http.StatusInternalServerError)
+		return
+	}
+
+	// Générer une miniature pour les images que l'on sait décoder ; les autres restent sans miniature
+	if img, err := media.DecodeImage(data); err == nil {
+		attachment.Width, attachment.Height = img.Bounds().Dx(), img.Bounds().Dy()
+		thumbnail, err := media.EncodePNG(media.Fit(img, attachmentThumbnailSide))
+		if err == nil {
+			err = storage.Default.Put(key+"-thumb.png", bytes.NewReader(thumbnail))
+		}
+		if err != nil {
+			log.Printf("Erreur lors de la création de la miniature: %v", err)
+		} else {
+			attachment.ThumbnailKey = key + "-thumb.png"
+		}
+	}
+
+	created, err := database.CreateAttachment(attachment, quota)
+	if err != nil {
+		database.DeleteAttachmentFiles(attachment)
+		if errors.Is(err, database.ErrAttachmentQuotaExceeded) {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		log.Printf("Erreur lors de l'enregistrement de la pièce jointe: %v", err)
+		http.Error(w, "Erreur lors de l'enregistrement de la pièce jointe", http.StatusInternalServerError)
+		return
+	}
+
+	// Retourner la pièce jointe créée
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// GetAttachmentUsageHandler retourne l'espace utilisé par les pièces jointes de l'utilisateur courant
+func GetAttachmentUsageHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	used, err := database.GetAttachmentUsage(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération de l'espace utilisé", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(database.AttachmentUsage{Used: used, Limit: int64(config.App.AttachmentQuotaBytes)})
+}
+
+// DownloadAttachmentHandler sert une pièce jointe (GET /api/attachments/{id})
+// ou sa miniature (GET /api/attachments/{id}/thumbnail), si l'utilisateur a le droit de la voir
+func DownloadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Format attendu: /api/attachments/{id} ou /api/attachments/{id}/thumbnail
+	pathParts := strings.Split(r.URL.Path, "/")
+	thumbnail := len(pathParts) == 5 && pathParts[4] == "thumbnail"
+	if len(pathParts) > 5 || (len(pathParts) == 5 && !thumbnail) {
+		http.NotFound(w, r)
+		return
+	}
+	attachmentID, err := pathID(r, 3)
+	if err != nil {
+		http.Error(w, "ID de pièce jointe invalide", http.StatusBadRequest)
+		return
+	}
+
+	// Les visiteurs anonymes ne voient que les pièces jointes des publications, tout comme
+	// les jetons d'accès (leur portée posts:read ne donne pas accès aux messages privés) ;
+	// une pièce jointe invisible est traitée comme inexistante
+	userID, _ := middleware.GetUserID(r)
+	if _, viaToken := middleware.GetAPIToken(r); viaToken {
+		userID = 0
+	}
+	attachment, err := database.GetAttachmentByID(attachmentID)
+	if err != nil {
+		http.Error(w, "Pièce jointe non trouvée", http.StatusNotFound)
+		return
+	}
+	allowed, err := database.CanViewAttachment(attachment, userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la vérification des droits", http.StatusInternalServerError)
+		return
+	}
+	if !allowed || (thumbnail && attachment.ThumbnailKey == "") {
+		http.Error(w, "Pièce jointe non trouvée", http.StatusNotFound)
+		return
+	}
+
+	key, contentType := attachment.StorageKey, attachment.ContentType
+	if thumbnail {
+		key, contentType = attachment.ThumbnailKey, "image/png"
+	}
+
+	object, err := storage.Default.Get(key)
+	if err != nil {
+		log.Printf("Erreur lors de la lecture de %s: %v", key, err)
+		http.Error(w, "Pièce jointe non trouvée", http.StatusNotFound)
+		return
+	}
+	defer object.Close()
+
+	// Seules les images sont affichées directement ; tout le reste est proposé au téléchargement
+	disposition := "attachment"
+	if strings.HasPrefix(contentType, "image/") {
+		disposition = "inline"
+	}
+	if contentType == "text/plain" {
+		contentType += "; charset=utf-8"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	http.ServeContent(w, r, "", attachment.CreatedAt, object)
+}
+
+// DeleteAttachmentHandler supprime une pièce jointe de l'utilisateur courant qui n'a pas encore été utilisée
+func DeleteAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	attachmentID, err := pathID(r, 3)
+	if err != nil {
+		http.Error(w, "ID de pièce jointe invalide", http.StatusBadRequest)
+		return
+	}
+
+	attachment, err := database.DeleteUnattachedAttachment(userID, attachmentID)
+	if errors.Is(err, database.ErrAttachmentUnavailable) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Erreur lors de la suppression de la pièce jointe", http.StatusInternalServerError)
+		return
+	}
+	database.DeleteAttachmentFiles(attachment)
+	recordAudit(r, auditTarget(database.AuditContentDeleted, database.AuditTargetAttachment, attachmentID,
+		map[string]interface{}{"filename": attachment.Filename}))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isAttachmentError indique si l'erreur de création d'une publication ou d'un message
+// vient des pièces jointes demandées (erreur de l'utilisateur et non du serveur)
+func isAttachmentError(err error) bool {
+	return errors.Is(err, database.ErrAttachmentUnavailable) || errors.Is(err, database.ErrTooManyAttachments)
+}
+
+// sanitizeFilename ne conserve que le nom de base d'un fichier envoyé, sans caractères de contrôle
+func sanitizeFilename(filename string) string {
+	filename = path.Base(strings.ReplaceAll(filename, "\\", "/"))
+	filename = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, filename)
+	filename = strings.TrimSpace(filename)
+
+	for utf8.RuneCountInString(filename) > maxFilenameLength {
+		_, size := utf8.DecodeLastRuneInString(filename)
+		filename = filename[:len(filename)-size]
+	}
+	if filename == "" || filename == "." || filename == "/" {
+		return "fichier"
+	}
+	return filename
+}
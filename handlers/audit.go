@@ -0,0 +1,197 @@
+// fichier: handlers/audit.go
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"realtimeforum/database"
+	"realtimeforum/middleware"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Nombre maximal d'entrées exportées en une fois
+const maxAuditExportRows = 10000
+
+// recordAudit ajoute une entrée au journal d'audit en complétant l'adresse IP de la requête et,
+// si elle n'est pas précisée, l'utilisateur authentifié comme auteur.
+// Un échec d'écriture est journalisé sans interrompre l'action auditée.
+func recordAudit(r *http.Request, entry database.AuditEntry) {
+	if entry.ActorID == nil {
+		if userID, ok := middleware.GetUserID(r); ok {
+			entry.ActorID = &userID
+		}
+	}
+	entry.IP = middleware.ClientIP(r)
+
+	if err := database.RecordAudit(&entry); err != nil {
+		log.Printf("Erreur lors de l'écriture du journal d'audit (%s): %v", entry.Action, err)
+	}
+}
+
+// auditTarget construit une entrée de journal visant un objet identifié
+func auditTarget(action, targetType string, targetID int, details map[string]interface{}) database.AuditEntry {
+	return database.AuditEntry{Action: action, TargetType: targetType, TargetID: &targetID, Details: details}
+}
+
+// GetAuditLogHandler recherche dans le journal d'audit (GET /api/admin/audit)
+func GetAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit, offset := parsePagination(r, 50)
+	entries, err := database.GetAuditLog(filter, limit, offset)
+	if err != nil {
+		log.Printf("Erreur lors de la lecture du journal d'audit: %v", err)
+		http.Error(w, "Erreur lors de la récupération du journal", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// ExportAuditLogHandler exporte les entrées du journal d'audit correspondant aux filtres
+// au format CSV ou JSON (GET /api/admin/audit/export?format=csv)
+func ExportAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		http.Error(w, "Format d'export invalide", http.StatusBadRequest)
+		return
+	}
+
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := database.GetAuditLog(filter, maxAuditExportRows, 0)
+	if err != nil {
+		log.Printf("Erreur lors de la lecture du journal d'audit: %v", err)
+		http.Error(w, "Erreur lors de l'export du journal", http.StatusInternalServerError)
+		return
+	}
+
+	// L'export lui-même est une action auditée
+	recordAudit(r, database.AuditEntry{
+		Action:  database.AuditLogExported,
+		Details: map[string]interface{}{"format": format, "entries": len(entries), "query": r.URL.RawQuery},
+	})
+
+	filename := "audit-" + time.Now().Format("20060102-150405") + "." + format
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"id", "created_at", "action", "actor_id", "actor", "target_type", "target_id", "ip", "details"})
+	for _, entry := range entries {
+		details, _ := json.Marshal(entry.Details)
+		writer.Write([]string{
+			strconv.Itoa(entry.ID),
+			entry.CreatedAt.UTC().Format(time.RFC3339),
+			entry.Action,
+			optionalID(entry.ActorID),
+			csvSafe(entry.Actor),
+			entry.TargetType,
+			optionalID(entry.TargetID),
+			csvSafe(entry.IP),
+			csvSafe(string(details)),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		log.Printf("Erreur lors de l'écriture de l'export CSV: %v", err)
+	}
+}
+
+// parseAuditFilter lit les filtres de recherche du journal d'audit :
+// action, actor, targetType, targetId, ip, since et until (RFC 3339 ou AAAA-MM-JJ)
+func parseAuditFilter(r *http.Request) (database.AuditFilter, error) {
+	query := r.URL.Query()
+	filter := database.AuditFilter{
+		Action:     query.Get("action"),
+		TargetType: query.Get("targetType"),
+		IP:         query.Get("ip"),
+	}
+
+	var err error
+	if filter.ActorID, err = optionalQueryInt(query.Get("actor")); err != nil {
+		return filter, errors.New("ID d'auteur invalide")
+	}
+	if filter.TargetID, err = optionalQueryInt(query.Get("targetId")); err != nil {
+		return filter, errors.New("ID de cible invalide")
+	}
+	if filter.Since, err = parseAuditTime(query.Get("since")); err != nil {
+		return filter, fmt.Errorf("date de début invalide: %q", query.Get("since"))
+	}
+	if filter.Until, err = parseAuditTime(query.Get("until")); err != nil {
+		return filter, fmt.Errorf("date de fin invalide: %q", query.Get("until"))
+	}
+
+	return filter, nil
+}
+
+// optionalQueryInt convertit un paramètre numérique facultatif (0 s'il est absent)
+func optionalQueryInt(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// parseAuditTime accepte une date RFC 3339 ou un jour AAAA-MM-JJ (minuit UTC)
+func parseAuditTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// optionalID formate un identifiant facultatif pour l'export CSV
+func optionalID(id *int) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.Itoa(*id)
+}
+
+// csvSafe neutralise les valeurs qu'un tableur interpréterait comme une formule
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -3,10 +3,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"realtimeforum/database"
 	"realtimeforum/middleware"
+	"realtimeforum/webhooks"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -19,12 +23,6 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Activer CORS pour cette réponse
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-	w.Header().Set("Access-Control-Allow-Credentials", "true")
-
 	// Décoder le corps de la requête
 	var userDTO database.UserDTO
 	err := json.NewDecoder(r.Body).Decode(&userDTO)
@@ -42,6 +40,19 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuser les inscriptions depuis une adresse IP bannie
+	ip := middleware.ClientIP(r)
+	if ban, err := database.GetIPBan(ip); err != nil || ban != nil {
+		if err != nil {
+			log.Printf("Erreur lors de la vérification des bannissements: %v", err)
+			http.Error(w, "Erreur lors de la création de l'utilisateur", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Inscription refusée depuis l'adresse IP bannie %s", ip)
+		http.Error(w, "Adresse IP bannie", http.StatusForbidden)
+		return
+	}
+
 	// Créer l'utilisateur
 	log.Printf("Tentative de création d'utilisateur: %s, %s", userDTO.Username, userDTO.Email)
 	userID, err := database.CreateUser(userDTO)
@@ -62,6 +73,15 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	log.Printf("Utilisateur créé avec l'ID: %d", userID)
+	if err := database.RecordUserIP(userID, ip); err != nil {
+		log.Printf("Erreur lors de l'enregistrement de l'adresse IP: %v", err)
+	}
+
+	// Notifier les webhooks abonnés (sans données personnelles)
+	webhooks.Emit(database.EventUserRegistered, map[string]interface{}{
+		"id":       userID,
+		"username": userDTO.Username,
+	})
 
 	// Créer une session pour l'utilisateur
 	log.Printf("Tentative de création de session pour l'utilisateur: %d", userID)
@@ -73,19 +93,8 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Printf("Session créée avec succès: %s", session.ID)
 
-	// Définir le cookie de session
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    session.ID,
-		Expires:  session.ExpiresAt,
-		HttpOnly: false, // Permettre l'accès via JavaScript
-		Path:     "/",
-		SameSite: http.SameSiteNoneMode,
-		Secure:   false, // Ne pas exiger HTTPS en développement
-	})
-
-	// Ajouter l'ID de session dans l'en-tête pour le client JavaScript
-	w.Header().Set("X-Session-ID", session.ID)
+	// Définir le cookie de session (HttpOnly : l'ID de session n'est jamais exposé au JavaScript)
+	middleware.SetSessionCookie(w, session)
 
 	// Récupérer l'utilisateur créé
 	log.Printf("Récupération de l'utilisateur avec l'ID: %d", userID)
@@ -97,16 +106,13 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Printf("Utilisateur récupéré avec succès: %s", user.Username)
 
-	// Nettoyer le mot de passe avant de retourner l'utilisateur
-	user.Password = ""
-
-	// Créer une réponse personnalisée avec l'utilisateur et l'ID de session
+	// Créer une réponse personnalisée avec la vue privée de l'utilisateur et le jeton anti-CSRF
 	response := struct {
-		*database.User
-		SessionID string `json:"sessionId"`
+		*database.PrivateUser
+		CSRFToken string `json:"csrfToken"`
 	}{
-		User:      user,
-		SessionID: session.ID,
+		PrivateUser: user.Private(),
+		CSRFToken:   session.CSRFToken,
 	}
 
 	// Définir le type de contenu avant d'écrire quoi que ce soit
@@ -137,12 +143,6 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Activer CORS pour cette réponse
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-	w.Header().Set("Access-Control-Allow-Credentials", "true")
-
 	// Décoder le corps de la requête
 	var loginReq database.LoginRequest
 	err := json.NewDecoder(r.Body).Decode(&loginReq)
@@ -152,16 +152,104 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuser la tentative si le compte ou l'adresse IP est temporairement verrouillé
+	ip := middleware.ClientIP(r)
+	accountKey, ipKey, knownUserID := database.LoginThrottleKeys(loginReq.Identifier, ip)
+	lockedUntil, err := database.GetLoginLockout(accountKey, ipKey)
+	if err != nil {
+		log.Printf("Erreur lors de la vérification du verrouillage: %v", err)
+		http.Error(w, "Erreur lors de la connexion", http.StatusInternalServerError)
+		return
+	}
+	if !lockedUntil.IsZero() {
+		log.Printf("Tentative de connexion refusée (verrouillage) pour %s depuis %s", loginReq.Identifier, ip)
+		writeLockout(w, lockedUntil)
+		return
+	}
+
 	// Authentifier l'utilisateur
 	log.Printf("Tentative d'authentification pour: %s", loginReq.Identifier)
 	user, err := database.AuthenticateUser(loginReq.Identifier, loginReq.Password)
 	if err != nil {
 		log.Printf("Échec d'authentification pour %s: %v", loginReq.Identifier, err)
+		lockedUntil, recordErr := database.RecordLoginFailure(accountKey, ipKey, loginReq.Identifier, knownUserID, ip, err.Error())
+		if recordErr != nil {
+			log.Printf("Erreur lors de l'enregistrement de l'échec de connexion: %v", recordErr)
+		}
+		recordAudit(r, database.AuditEntry{
+			Action:     database.AuditLoginFailed,
+			TargetType: database.AuditTargetUser,
+			TargetID:   knownUserID,
+			Details:    map[string]interface{}{"identifier": loginReq.Identifier, "reason": err.Error()},
+		})
+		if !lockedUntil.IsZero() {
+			writeLockout(w, lockedUntil)
+			return
+		}
 		http.Error(w, "Identifiants invalides", http.StatusUnauthorized)
 		return
 	}
 	log.Printf("Authentification réussie pour: %s", user.Username)
 
+	// Si la 2FA est activée, émettre une session partielle en attendant le second facteur
+	if user.TwoFactorEnabled {
+		partial, err := database.CreatePartialSession(user.ID)
+		if err != nil {
+			log.Printf("Erreur lors de la création de la session partielle: %v", err)
+			http.Error(w, "Erreur lors de la création de la session", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("Second facteur requis pour: %s", user.Username)
+
+		response := struct {
+			TwoFactorRequired bool      `json:"twoFactorRequired"`
+			ChallengeToken    string    `json:"challengeToken"`
+			ExpiresAt         time.Time `json:"expiresAt"`
+		}{
+			TwoFactorRequired: true,
+			ChallengeToken:    partial.ID,
+			ExpiresAt:         partial.ExpiresAt,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	completeLogin(w, r, user)
+}
+
+// writeLockout répond 429 avec l'en-tête Retry-After lorsque les tentatives de connexion sont bloquées
+func writeLockout(w http.ResponseWriter, lockedUntil time.Time) {
+	retryAfter := int(math.Ceil(time.Until(lockedUntil).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	http.Error(w, fmt.Sprintf("Trop de tentatives de connexion, réessayez dans %d secondes", retryAfter), http.StatusTooManyRequests)
+}
+
+// completeLogin crée la session d'un utilisateur entièrement authentifié (mot de passe et, si activé,
+// second facteur), remet à zéro ses échecs de connexion, définit le cookie et retourne l'utilisateur avec l'ID de session
+func completeLogin(w http.ResponseWriter, r *http.Request, user *database.User) {
+	// Refuser la connexion d'un compte suspendu ou banni, ou depuis une adresse IP bannie
+	_, message, err := middleware.CheckAccess(r, user.ID)
+	if err != nil {
+		log.Printf("Erreur lors de la vérification des sanctions: %v", err)
+		http.Error(w, "Erreur lors de la connexion", http.StatusInternalServerError)
+		return
+	}
+	if message != "" {
+		recordAudit(r, database.AuditEntry{
+			Action:     database.AuditLoginFailed,
+			TargetType: database.AuditTargetUser,
+			TargetID:   &user.ID,
+			Details:    map[string]interface{}{"identifier": user.Username, "reason": message},
+		})
+		http.Error(w, message, http.StatusForbidden)
+		return
+	}
+
 	// Créer une session pour l'utilisateur
 	log.Printf("Création d'une session pour l'utilisateur: %d", user.ID)
 	session, err := database.CreateSession(user.ID)
@@ -172,34 +260,51 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Printf("Session créée avec succès: %s", session.ID)
 
+	// Remettre à zéro le compteur d'échecs du compte, une fois le second facteur éventuel vérifié :
+	// le mot de passe seul ne doit pas lever un verrouillage dû à des codes 2FA invalides
+	if err := database.ResetLoginThrottle(database.AccountThrottleKey(user.ID)); err != nil {
+		log.Printf("Erreur lors de la remise à zéro du compteur d'échecs: %v", err)
+	}
+
 	// Définir le cookie de session
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    session.ID,
-		Expires:  session.ExpiresAt,
-		HttpOnly: false, // Permettre l'accès via JavaScript pour diagnostic
-		Path:     "/",
-		SameSite: http.SameSiteNoneMode, // Permettre l'accès depuis n'importe quelle origine
-		Secure:   false,                 // Ne pas exiger HTTPS en développement
-	})
+	middleware.SetSessionCookie(w, session)
 
-	// Mettre à jour le statut en ligne
-	err = database.UpdateUserOnlineStatus(user.ID, true)
+	// Enregistrer la connexion et mettre à jour le statut en ligne
+	err = database.RecordUserLogin(user.ID)
 	if err != nil {
 		// Log l'erreur mais continuer
 		log.Printf("Erreur lors de la mise à jour du statut en ligne: %v", err)
 	}
 
-	// Nettoyer le mot de passe avant de retourner l'utilisateur
-	user.Password = ""
+	// Journaliser la connexion en signalant une adresse IP différente de la précédente
+	ip := middleware.ClientIP(r)
+	previousIP, err := database.GetLastLoginIP(user.ID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération de l'adresse IP précédente: %v", err)
+	}
+	if err := database.RecordUserIP(user.ID, ip); err != nil {
+		log.Printf("Erreur lors de l'enregistrement de l'adresse IP: %v", err)
+	}
+	recordAudit(r, database.AuditEntry{
+		Action:     database.AuditLogin,
+		ActorID:    &user.ID,
+		TargetType: database.AuditTargetUser,
+		TargetID:   &user.ID,
+		Details: map[string]interface{}{
+			"newIp":      previousIP != ip,
+			"previousIp": previousIP,
+			"twoFactor":  user.TwoFactorEnabled,
+		},
+	})
 
-	// Retourner l'utilisateur connecté et l'ID de session
+	// Retourner la vue privée de l'utilisateur connecté et le jeton anti-CSRF
+	// (l'ID de session reste dans le cookie HttpOnly)
 	response := struct {
-		User      *database.User `json:"user"`
-		SessionID string         `json:"sessionId"`
+		User      *database.PrivateUser `json:"user"`
+		CSRFToken string                `json:"csrfToken"`
 	}{
-		User:      user,
-		SessionID: session.ID,
+		User:      user.Private(),
+		CSRFToken: session.CSRFToken,
 	}
 
 	// Définir le type de contenu avant d'écrire quoi que ce soit
@@ -228,98 +333,74 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Récupérer le cookie de session
-	cookie, err := r.Cookie("session_id")
-	if err != nil {
+	// Récupérer la session authentifiée (cookie) depuis le contexte
+	session, ok := middleware.GetSession(r)
+	if !ok {
 		http.Error(w, "Non authentifié", http.StatusUnauthorized)
 		return
 	}
 
-	// Récupérer l'ID utilisateur depuis le contexte
-	userID, ok := middleware.GetUserID(r)
-	if ok {
-		// Mettre à jour le statut en ligne
-		err = database.UpdateUserOnlineStatus(userID, false)
-		if err != nil {
-			// Log l'erreur mais continuer
-			println("Erreur lors de la mise à jour du statut en ligne:", err.Error())
-		}
+	// Mettre à jour le statut en ligne
+	err := database.UpdateUserOnlineStatus(session.UserID, false)
+	if err != nil {
+		// Log l'erreur mais continuer
+		println("Erreur lors de la mise à jour du statut en ligne:", err.Error())
 	}
 
 	// Supprimer la session
-	err = database.DeleteSession(cookie.Value)
+	err = database.DeleteSession(session.ID)
 	if err != nil {
 		http.Error(w, "Erreur lors de la suppression de la session", http.StatusInternalServerError)
 		return
 	}
+	recordAudit(r, auditTarget(database.AuditLogout, database.AuditTargetUser, session.UserID, nil))
 
 	// Supprimer le cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_id",
-		Value:    "",
-		Expires:  time.Unix(0, 0),
-		HttpOnly: true,
-		Path:     "/",
-		SameSite: http.SameSiteStrictMode,
-	})
+	middleware.ClearSessionCookie(w)
 
 	// Retourner un succès
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(`{"message": "Déconnecté avec succès"}`))
 }
 
-// GetCurrentUserHandler récupère l'utilisateur actuellement connecté
-func GetCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+// GetCSRFTokenHandler retourne le jeton anti-CSRF de la session courante
+// (utilisé par le client après un rechargement de page)
+func GetCSRFTokenHandler(w http.ResponseWriter, r *http.Request) {
 	// Vérifier la méthode
 	if r.Method != http.MethodGet {
 		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Activer CORS pour cette réponse
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-	w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-	w.Header().Set("Access-Control-Allow-Credentials", "true")
+	session, ok := middleware.GetSession(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
 
-	var userID int
+	// Ne pas mettre en cache une réponse contenant le jeton
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"csrfToken": session.CSRFToken})
+}
 
-	// Vérifier d'abord le cookie
-	cookie, err := r.Cookie("session_id")
-	if err != nil {
-		// Vérifier l'en-tête Authorization
-		authHeader := r.Header.Get("Authorization")
-		if strings.HasPrefix(authHeader, "Bearer ") {
-			token := strings.TrimPrefix(authHeader, "Bearer ")
-			session, err := database.GetSessionByID(token)
-			if err == nil {
-				// Utiliser l'ID utilisateur de la session
-				userID = session.UserID
-				log.Printf("Authentification par token Bearer réussie pour l'utilisateur ID=%d", userID)
-			} else {
-				log.Printf("Token Bearer invalide: %v", err)
-				http.Error(w, "Non authentifié", http.StatusUnauthorized)
-				return
-			}
-		} else {
-			log.Printf("Aucun cookie ou token Bearer trouvé")
-			http.Error(w, "Non authentifié", http.StatusUnauthorized)
-			return
-		}
-	} else {
-		// Utiliser le cookie comme avant
-		session, err := database.GetSessionByID(cookie.Value)
-		if err != nil {
-			log.Printf("Cookie de session invalide: %v", err)
-			http.Error(w, "Session invalide", http.StatusUnauthorized)
-			return
-		}
-		userID = session.UserID
-		log.Printf("Authentification par cookie réussie pour l'utilisateur ID=%d", userID)
+// GetCurrentUserHandler récupère l'utilisateur actuellement connecté
+func GetCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer la session authentifiée par le cookie depuis le contexte
+	session, ok := middleware.GetSession(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
 	}
 
 	// Récupérer l'utilisateur
-	user, err := database.GetUserByID(userID)
+	user, err := database.GetUserByID(session.UserID)
 	if err != nil {
 		log.Printf("Erreur lors de la récupération de l'utilisateur: %v", err)
 		http.Error(w, "Erreur lors de la récupération de l'utilisateur", http.StatusInternalServerError)
@@ -327,14 +408,11 @@ func GetCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Printf("Utilisateur récupéré avec succès: %s", user.Username)
 
-	// Nettoyer le mot de passe avant de retourner l'utilisateur
-	user.Password = ""
-
 	// Définir le type de contenu
 	w.Header().Set("Content-Type", "application/json")
 
-	// Sérialiser manuellement pour éviter les problèmes
-	responseBytes, err := json.Marshal(user)
+	// Sérialiser manuellement la vue privée de l'utilisateur
+	responseBytes, err := json.Marshal(user.Private())
 	if err != nil {
 		log.Printf("Erreur lors de la sérialisation de la réponse: %v", err)
 		http.Error(w, "Erreur lors de la sérialisation de la réponse", http.StatusInternalServerError)
@@ -357,19 +435,27 @@ func GetOnlineUsersHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Récupérer l'ID utilisateur depuis le contexte
-	_, ok := middleware.GetUserID(r)
+	userID, ok := middleware.GetUserID(r)
 	if !ok {
 		http.Error(w, "Non authentifié", http.StatusUnauthorized)
 		return
 	}
 
-	// Récupérer les utilisateurs en ligne
+	// Récupérer les utilisateurs en ligne (vue publique uniquement)
 	users, err := database.GetOnlineUsers()
 	if err != nil {
 		http.Error(w, "Erreur lors de la récupération des utilisateurs en ligne", http.StatusInternalServerError)
 		return
 	}
 
+	// La présence n'est pas visible entre deux utilisateurs dont l'un a bloqué l'autre
+	peers, err := database.GetBlockedPeers(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des utilisateurs en ligne", http.StatusInternalServerError)
+		return
+	}
+	users = filterBlockedUsers(users, peers)
+
 	// Retourner la liste des utilisateurs
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(users)
@@ -0,0 +1,182 @@
+// fichier: handlers/avatars.go
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"realtimeforum/config"
+	"realtimeforum/database"
+	"realtimeforum/media"
+	"realtimeforum/middleware"
+	"realtimeforum/storage"
+	"strings"
+	"time"
+)
+
+// Nom du champ de formulaire portant le fichier de l'avatar
+const avatarFormField = "avatar"
+
+// UploadAvatarHandler remplace l'avatar de l'utilisateur courant (formulaire multipart, champ "avatar").
+// L'image est décodée, recadrée en carré puis réencodée en PNG dans chaque taille : les métadonnées
+// du fichier d'origine ne sont jamais conservées.
+func UploadAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	// Lire le fichier envoyé, sans jamais dépasser la taille autorisée
+	maxBytes := int64(config.App.AvatarMaxBytes)
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+multipartOverhead)
+	_, data, err := readMultipartFile(r, avatarFormField, maxBytes)
+	if err != nil {
+		if isUploadTooLarge(err) {
+			http.Error(w, "Fichier trop volumineux", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Fichier \"avatar\" manquant ou formulaire invalide", http.StatusBadRequest)
+		return
+	}
+
+	// Décoder l'image d'après son contenu réel
+	img, err := media.DecodeImage(data)
+	if errors.Is(err, media.ErrUnsupportedFormat) {
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	version, err := database.NewAvatarVersion()
+	if err != nil {
+		http.Error(w, "Erreur lors de l'enregistrement de l'avatar", http.StatusInternalServerError)
+		return
+	}
+
+	// Générer et enregistrer chaque taille, de la plus grande à la plus petite
+	// (chaque réduction part de la précédente, bien moins coûteuse que l'original)
+	resized := img
+	for _, size := range database.AvatarSizes {
+		resized = media.SquareThumbnail(resized, size)
+		encoded, err := media.EncodePNG(resized)
+		if err == nil {
+			err = storage.Default.Put(database.AvatarKey(userID, version, size), bytes.NewReader(encoded))
+		}
+		if err != nil {
+			log.Printf("Erreur lors de l'enregistrement de l'avatar: %v", err)
+			deleteAvatarFiles(userID, version)
+			http.Error(w, "Erreur lors de l'enregistrement de l'avatar", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	previous, err := database.SetAvatarVersion(userID, version)
+	if err != nil {
+		log.Printf("Erreur lors de la mise à jour de l'avatar: %v", err)
+		deleteAvatarFiles(userID, version)
+		http.Error(w, "Erreur lors de l'enregistrement de l'avatar", http.StatusInternalServerError)
+		return
+	}
+	deleteAvatarFiles(userID, previous)
+
+	respondWithAvatarChange(w, userID)
+}
+
+// DeleteAvatarHandler supprime l'avatar de l'utilisateur courant
+func DeleteAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	previous, err := database.SetAvatarVersion(userID, "")
+	if err != nil {
+		http.Error(w, "Erreur lors de la suppression de l'avatar", http.StatusInternalServerError)
+		return
+	}
+	deleteAvatarFiles(userID, previous)
+
+	respondWithAvatarChange(w, userID)
+}
+
+// respondWithAvatarChange diffuse le changement d'avatar et retourne la vue privée de l'utilisateur
+func respondWithAvatarChange(w http.ResponseWriter, userID int) {
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération de l'utilisateur", http.StatusInternalServerError)
+		return
+	}
+	// L'avatar figure dans la liste des utilisateurs en ligne
+	broadcastOnlineUsers()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user.Private())
+}
+
+// deleteAvatarFiles supprime toutes les tailles d'une version d'avatar
+func deleteAvatarFiles(userID int, version string) {
+	if version == "" {
+		return
+	}
+	for _, size := range database.AvatarSizes {
+		if err := storage.Default.Delete(database.AvatarKey(userID, version, size)); err != nil {
+			log.Printf("Erreur lors de la suppression d'un ancien avatar: %v", err)
+		}
+	}
+}
+
+// ServeMediaHandler sert les objets du stockage (GET /media/{clé}).
+// Les clés étant versionnées, les réponses peuvent être mises en cache indéfiniment.
+func ServeMediaHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Seuls les avatars sont publics
+	key := strings.TrimPrefix(r.URL.Path, database.MediaURLPrefix)
+	if !strings.HasPrefix(key, "avatars/") || !storage.ValidKey(key) {
+		http.NotFound(w, r)
+		return
+	}
+
+	object, err := storage.Default.Get(key)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors de la lecture de %s: %v", key, err)
+		http.Error(w, "Erreur lors de la lecture du fichier", http.StatusInternalServerError)
+		return
+	}
+	defer object.Close()
+
+	// Les avatars sont toujours réencodés en PNG : le type ne dépend jamais du contenu envoyé
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	http.ServeContent(w, r, "", time.Time{}, object)
+}
@@ -0,0 +1,277 @@
+// fichier: handlers/bookmarks.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"realtimeforum/database"
+	"realtimeforum/middleware"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limites des favoris
+const (
+	maxBookmarkNoteLength   = 500
+	maxBookmarkFolderLength = 50
+	defaultBookmarksPerPage = 20
+)
+
+// bookmarkRequest décrit la création ou la modification d'un favori
+type bookmarkRequest struct {
+	TargetType string `json:"targetType"`
+	TargetID   int    `json:"targetId"`
+	Note       string `json:"note"`
+	Folder     string `json:"folder"`
+}
+
+// ListBookmarksHandler liste les favoris de l'utilisateur avec un aperçu du contenu
+// (GET /api/me/bookmarks?type=post&folder=lectures&limit=20&offset=0 ; folder= vide pour les favoris sans dossier)
+func ListBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := database.BookmarkFilter{TargetType: query.Get("type")}
+	if filter.TargetType != "" && !isBookmarkTarget(filter.TargetType) {
+		http.Error(w, "Type de contenu invalide", http.StatusBadRequest)
+		return
+	}
+	if query.Has("folder") {
+		folder := strings.TrimSpace(query.Get("folder"))
+		filter.Folder = &folder
+	}
+
+	limit, offset := parsePagination(r, defaultBookmarksPerPage)
+	bookmarks, err := database.GetBookmarks(userID, filter, limit, offset)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des favoris: %v", err)
+		http.Error(w, "Erreur lors de la récupération des favoris", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bookmarks)
+}
+
+// BookmarkFoldersHandler liste les dossiers de favoris de l'utilisateur (GET /api/me/bookmarks/folders)
+func BookmarkFoldersHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	folders, err := database.GetBookmarkFolders(userID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des dossiers de favoris: %v", err)
+		http.Error(w, "Erreur lors de la récupération des favoris", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(folders)
+}
+
+// CreateBookmarkHandler ajoute une publication, un commentaire ou un message privé aux favoris
+// (POST /api/me/bookmarks)
+func CreateBookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	var request bookmarkRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+	if !isBookmarkTarget(request.TargetType) {
+		http.Error(w, "Type de contenu invalide", http.StatusBadRequest)
+		return
+	}
+	if !normalizeBookmarkRequest(&request) {
+		http.Error(w, "Note ou dossier trop long", http.StatusBadRequest)
+		return
+	}
+
+	// Le contenu doit être visible ; un message privé ne peut être enregistré que par ses participants
+	target, err := database.GetReportTarget(request.TargetType, request.TargetID)
+	if err == database.ErrReportTargetNotFound {
+		http.Error(w, "Contenu non trouvé", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors de la récupération du contenu: %v", err)
+		http.Error(w, "Erreur lors de l'ajout aux favoris", http.StatusInternalServerError)
+		return
+	}
+	if request.TargetType == database.ReportTargetMessage && target.AuthorID != userID && target.RecipientID != userID {
+		http.Error(w, "Contenu non trouvé", http.StatusNotFound)
+		return
+	}
+
+	bookmarkID, err := database.CreateBookmark(&database.Bookmark{
+		UserID:     userID,
+		TargetType: request.TargetType,
+		TargetID:   request.TargetID,
+		Note:       request.Note,
+		Folder:     request.Folder,
+	})
+	if errors.Is(err, database.ErrBookmarkExists) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors de l'ajout aux favoris: %v", err)
+		http.Error(w, "Erreur lors de l'ajout aux favoris", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithBookmark(w, bookmarkID, userID, http.StatusCreated)
+}
+
+// BookmarkHandler modifie la note et le dossier d'un favori ou le retire
+// (PUT et DELETE /api/me/bookmarks/{id})
+func BookmarkHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	bookmarkID, err := pathID(r, 4)
+	if err != nil {
+		http.Error(w, "ID de favori invalide", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var request bookmarkRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Données invalides", http.StatusBadRequest)
+			return
+		}
+		if !normalizeBookmarkRequest(&request) {
+			http.Error(w, "Note ou dossier trop long", http.StatusBadRequest)
+			return
+		}
+
+		err := database.UpdateBookmark(bookmarkID, userID, request.Note, request.Folder)
+		if errors.Is(err, database.ErrBookmarkNotFound) {
+			http.Error(w, "Favori non trouvé", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Erreur lors de la modification du favori: %v", err)
+			http.Error(w, "Erreur lors de la modification du favori", http.StatusInternalServerError)
+			return
+		}
+		respondWithBookmark(w, bookmarkID, userID, http.StatusOK)
+
+	case http.MethodDelete:
+		if err := database.DeleteBookmark(bookmarkID, userID); err != nil {
+			http.Error(w, "Favori non trouvé", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+	}
+}
+
+// isBookmarkTarget indique si un type de contenu peut être ajouté aux favoris
+func isBookmarkTarget(targetType string) bool {
+	switch targetType {
+	case database.ReportTargetPost, database.ReportTargetComment, database.ReportTargetMessage:
+		return true
+	}
+	return false
+}
+
+// normalizeBookmarkRequest retire les espaces superflus de la note et du dossier et vérifie leur longueur
+func normalizeBookmarkRequest(request *bookmarkRequest) bool {
+	request.Note = strings.TrimSpace(request.Note)
+	request.Folder = strings.TrimSpace(request.Folder)
+	return utf8.RuneCountInString(request.Note) <= maxBookmarkNoteLength &&
+		utf8.RuneCountInString(request.Folder) <= maxBookmarkFolderLength
+}
+
+// respondWithBookmark relit un favori de l'utilisateur et le retourne
+func respondWithBookmark(w http.ResponseWriter, bookmarkID, userID int, status int) {
+	bookmark, err := database.GetBookmarkByID(bookmarkID, userID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération du favori: %v", err)
+		http.Error(w, "Erreur lors de la récupération du favori", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(bookmark)
+}
+
+// bookmarkStatesFor retourne les favoris de l'utilisateur authentifié parmi les contenus donnés
+// (aucun pour un visiteur ; une erreur est journalisée sans interrompre la requête)
+func bookmarkStatesFor(r *http.Request, targetType string, targetIDs []int) map[int]*database.Bookmark {
+	userID, ok := middleware.GetUserID(r)
+	if !ok || len(targetIDs) == 0 {
+		return nil
+	}
+	states, err := database.GetBookmarkStates(userID, targetType, targetIDs)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des favoris: %v", err)
+		return nil
+	}
+	return states
+}
+
+// withPostBookmarks renseigne le favori de l'utilisateur authentifié sur chaque publication
+func withPostBookmarks(r *http.Request, posts []*database.Post) {
+	ids := make([]int, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+	states := bookmarkStatesFor(r, database.ReportTargetPost, ids)
+	for _, post := range posts {
+		post.Bookmark = states[post.ID]
+	}
+}
+
+// withCommentBookmarks renseigne le favori de l'utilisateur authentifié sur chaque commentaire
+func withCommentBookmarks(r *http.Request, comments []*database.Comment) {
+	ids := make([]int, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+	states := bookmarkStatesFor(r, database.ReportTargetComment, ids)
+	for _, comment := range comments {
+		comment.Bookmark = states[comment.ID]
+	}
+}
@@ -0,0 +1,477 @@
+// fichier: handlers/bots.go
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"realtimeforum/database"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Événements WebSocket auxquels un bot peut s'abonner
+var botEvents = map[string]bool{
+	"private_message":    true,
+	"typing_indicator":   true,
+	"post_created":       true,
+	"comment_created":    true,
+	"online_users":       true,
+	"post_state_changed": true,
+	"poll_updated":       true,
+	"post_score_updated": true,
+}
+
+// Format des noms de commandes slash (sans le "/")
+var commandNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// Durée pendant laquelle un bot peut répondre à une invocation
+const commandInvocationTTL = 15 * time.Minute
+
+// Longueur maximale d'une réponse de bot
+const maxCommandResponseLength = 2000
+
+// BotCommand représente une commande slash enregistrée par un bot connecté
+type BotCommand struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	BotID       int    `json:"botId"`
+}
+
+// commandInvocation mémorise une invocation en attente de réponse
+type commandInvocation struct {
+	Command   string
+	BotID     int
+	UserID    int
+	ExpiresAt time.Time
+}
+
+var (
+	// Registre des commandes slash, indexé par nom. Une commande n'existe
+	// que tant que le bot qui l'a enregistrée est connecté.
+	commandRegistry = make(map[string]BotCommand)
+
+	// Invocations en attente de réponse, indexées par ID
+	commandInvocations = make(map[string]commandInvocation)
+
+	// Mutex protégeant le registre et les invocations
+	commandsMutex = sync.Mutex{}
+)
+
+// decodePayload convertit le payload générique d'un message WebSocket dans la structure donnée
+func decodePayload(payload interface{}, target interface{}) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(payloadJSON, target)
+}
+
+// sendEvent sérialise et envoie un événement à un client précis, sans filtrage d'abonnement
+func sendEvent(c *Client, eventType string, payload interface{}) {
+	messageJSON, err := json.Marshal(Message{Type: eventType, Payload: payload})
+	if err != nil {
+		log.Printf("Erreur lors de la sérialisation du message: %v", err)
+		return
+	}
+	c.SafeSend(messageJSON)
+}
+
+// handleBotSubscribe remplace les abonnements d'un bot aux types d'événements
+func handleBotSubscribe(c *Client, payload interface{}) {
+	if !c.IsBot {
+		log.Printf("Abonnement refusé: l'utilisateur ID=%d n'est pas un bot", c.UserID)
+		return
+	}
+
+	var data struct {
+		Events []string `json:"events"`
+	}
+	if err := decodePayload(payload, &data); err != nil {
+		log.Printf("Erreur lors du décodage de l'abonnement: %v", err)
+		return
+	}
+
+	subscribed := make([]string, 0, len(data.Events))
+	subscriptions := make(map[string]bool)
+	for _, event := range data.Events {
+		if botEvents[event] && !subscriptions[event] {
+			subscriptions[event] = true
+			subscribed = append(subscribed, event)
+		}
+	}
+
+	c.subsMux.Lock()
+	c.subscriptions = subscriptions
+	c.subsMux.Unlock()
+
+	log.Printf("Bot ID=%d abonné aux événements %v", c.UserID, subscribed)
+	sendEvent(c, "subscribed", map[string]interface{}{"events": subscribed})
+}
+
+// handleRegisterCommands remplace les commandes slash d'un bot.
+// Les commandes déjà enregistrées par un autre bot sont refusées.
+func handleRegisterCommands(c *Client, payload interface{}) {
+	if !c.IsBot {
+		log.Printf("Enregistrement de commandes refusé: l'utilisateur ID=%d n'est pas un bot", c.UserID)
+		return
+	}
+
+	var data struct {
+		Commands []struct {
+			Name        string `json:"name"`
+			Description string `json:"description"`
+		} `json:"commands"`
+	}
+	if err := decodePayload(payload, &data); err != nil {
+		log.Printf("Erreur lors du décodage des commandes: %v", err)
+		return
+	}
+
+	registered := make([]string, 0, len(data.Commands))
+	rejected := make([]string, 0)
+
+	commandsMutex.Lock()
+	for name, command := range commandRegistry {
+		if command.BotID == c.UserID {
+			delete(commandRegistry, name)
+		}
+	}
+	for _, command := range data.Commands {
+		name := strings.ToLower(strings.TrimPrefix(command.Name, "/"))
+		existing, taken := commandRegistry[name]
+		if !commandNamePattern.MatchString(name) || (taken && existing.BotID != c.UserID) {
+			rejected = append(rejected, command.Name)
+			continue
+		}
+		commandRegistry[name] = BotCommand{Name: name, Description: command.Description, BotID: c.UserID}
+		registered = append(registered, name)
+	}
+	commandsMutex.Unlock()
+
+	log.Printf("Bot ID=%d: commandes enregistrées %v, refusées %v", c.UserID, registered, rejected)
+	sendEvent(c, "commands_registered", map[string]interface{}{
+		"registered": registered,
+		"rejected":   rejected,
+	})
+}
+
+// unregisterBotCommands retire les commandes d'un bot qui se déconnecte
+func unregisterBotCommands(botID int) {
+	commandsMutex.Lock()
+	defer commandsMutex.Unlock()
+
+	for name, command := range commandRegistry {
+		if command.BotID == botID {
+			delete(commandRegistry, name)
+		}
+	}
+}
+
+// parseCommand extrait le nom et les arguments d'un message commençant par "/"
+func parseCommand(content string) (string, string, bool) {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "/") {
+		return "", "", false
+	}
+	fields := strings.SplitN(content[1:], " ", 2)
+	name := strings.ToLower(fields[0])
+	args := ""
+	if len(fields) > 1 {
+		args = strings.TrimSpace(fields[1])
+	}
+	return name, args, true
+}
+
+// dispatchCommand transmet une commande slash au bot qui l'a enregistrée.
+// Retourne false si le message n'est pas une commande connue, qui est alors envoyé normalement.
+func dispatchCommand(senderID int, payload interface{}) bool {
+	var data struct {
+		ReceiverID int    `json:"receiverId"`
+		Content    string `json:"content"`
+	}
+	if err := decodePayload(payload, &data); err != nil {
+		return false
+	}
+
+	name, args, ok := parseCommand(data.Content)
+	if !ok {
+		return false
+	}
+
+	commandsMutex.Lock()
+	command, exists := commandRegistry[name]
+	if !exists {
+		commandsMutex.Unlock()
+		return false
+	}
+
+	id, err := generateInvocationID()
+	if err != nil {
+		commandsMutex.Unlock()
+		log.Printf("Erreur lors de la génération de l'ID d'invocation: %v", err)
+		return true
+	}
+
+	// Purger les invocations expirées avant d'en ajouter une
+	now := time.Now()
+	for key, invocation := range commandInvocations {
+		if now.After(invocation.ExpiresAt) {
+			delete(commandInvocations, key)
+		}
+	}
+	commandInvocations[id] = commandInvocation{
+		Command:   name,
+		BotID:     command.BotID,
+		UserID:    senderID,
+		ExpiresAt: now.Add(commandInvocationTTL),
+	}
+	commandsMutex.Unlock()
+
+	username := ""
+	if user, err := database.GetUserByID(senderID); err == nil {
+		username = user.Username
+	}
+
+	messageJSON, err := json.Marshal(Message{
+		Type: "command_invocation",
+		Payload: map[string]interface{}{
+			"invocationId": id,
+			"command":      name,
+			"args":         args,
+			"userId":       senderID,
+			"username":     username,
+			"receiverId":   data.ReceiverID,
+			"createdAt":    now,
+		},
+	})
+	if err != nil {
+		log.Printf("Erreur lors de la sérialisation de l'invocation: %v", err)
+		return true
+	}
+
+	log.Printf("Commande /%s de l'utilisateur ID=%d transmise au bot ID=%d", name, senderID, command.BotID)
+	sendToUserUnfiltered(command.BotID, messageJSON)
+	return true
+}
+
+// generateInvocationID génère un identifiant aléatoire d'invocation
+func generateInvocationID() (string, error) {
+	bytes := make([]byte, 12)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// handleCommandResponse relaie la réponse d'un bot à l'utilisateur ayant invoqué la commande.
+// Un bot ne peut répondre qu'à ses propres invocations, tant qu'elles n'ont pas expiré.
+func handleCommandResponse(c *Client, payload interface{}) {
+	if !c.IsBot {
+		log.Printf("Réponse de commande refusée: l'utilisateur ID=%d n'est pas un bot", c.UserID)
+		return
+	}
+
+	var data struct {
+		InvocationID string `json:"invocationId"`
+		Content      string `json:"content"`
+	}
+	if err := decodePayload(payload, &data); err != nil {
+		log.Printf("Erreur lors du décodage de la réponse de commande: %v", err)
+		return
+	}
+	if data.Content == "" || len(data.Content) > maxCommandResponseLength {
+		log.Printf("Réponse de commande invalide du bot ID=%d", c.UserID)
+		return
+	}
+
+	commandsMutex.Lock()
+	invocation, ok := commandInvocations[data.InvocationID]
+	commandsMutex.Unlock()
+	if !ok || invocation.BotID != c.UserID || time.Now().After(invocation.ExpiresAt) {
+		log.Printf("Invocation inconnue ou expirée pour le bot ID=%d", c.UserID)
+		return
+	}
+
+	username := ""
+	if bot, err := database.GetUserByID(c.UserID); err == nil {
+		username = bot.Username
+	}
+
+	messageJSON, err := json.Marshal(Message{
+		Type: "command_response",
+		Payload: map[string]interface{}{
+			"invocationId": data.InvocationID,
+			"command":      invocation.Command,
+			"botId":        c.UserID,
+			"botUsername":  username,
+			"content":      data.Content,
+			"createdAt":    time.Now(),
+		},
+	})
+	if err != nil {
+		log.Printf("Erreur lors de la sérialisation de la réponse: %v", err)
+		return
+	}
+
+	sendToUser(invocation.UserID, "command_response", messageJSON)
+}
+
+// sendToUserUnfiltered envoie un message à un utilisateur sans tenir compte de ses abonnements
+func sendToUserUnfiltered(userID int, message []byte) {
+	clientsMutex.RLock()
+	client, ok := clients[userID]
+	clientsMutex.RUnlock()
+
+	if ok {
+		client.SafeSend(message)
+	}
+}
+
+// GetCommandsHandler liste les commandes slash disponibles
+func GetCommandsHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	commandsMutex.Lock()
+	commands := make([]BotCommand, 0, len(commandRegistry))
+	for _, command := range commandRegistry {
+		commands = append(commands, command)
+	}
+	commandsMutex.Unlock()
+
+	sort.Slice(commands, func(i, j int) bool { return commands[i].Name < commands[j].Name })
+
+	// Retourner les commandes
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(commands)
+}
+
+// GetBotsHandler liste les comptes bots (administration)
+func GetBotsHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bots, err := database.GetBots()
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des bots", http.StatusInternalServerError)
+		return
+	}
+
+	views := make([]*database.PrivateUser, 0, len(bots))
+	for _, bot := range bots {
+		views = append(views, bot.Private())
+	}
+
+	// Retourner les bots
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(views)
+}
+
+// CreateBotHandler crée un compte bot et retourne son jeton (administration)
+func CreateBotHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Décoder la requête
+	var req database.BotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Requête invalide", http.StatusBadRequest)
+		return
+	}
+
+	// Valider les données
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" || strings.Contains(req.Username, "@") {
+		http.Error(w, "Nom d'utilisateur invalide", http.StatusBadRequest)
+		return
+	}
+	if req.FirstName == "" {
+		req.FirstName = req.Username
+	}
+	if req.LastName == "" {
+		req.LastName = "Bot"
+	}
+
+	// Vérifier que le nom d'utilisateur est disponible
+	if _, err := database.GetUserByUsername(req.Username); err == nil {
+		http.Error(w, "Nom d'utilisateur déjà utilisé", http.StatusConflict)
+		return
+	}
+
+	botID, err := database.CreateBot(req)
+	if err != nil {
+		log.Printf("Erreur lors de la création du bot: %v", err)
+		http.Error(w, "Erreur lors de la création du bot", http.StatusInternalServerError)
+		return
+	}
+
+	writeBotToken(w, r, botID, http.StatusCreated)
+}
+
+// IssueBotTokenHandler délivre un nouveau jeton à un bot en révoquant les précédents (administration)
+func IssueBotTokenHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extraire l'ID du bot de l'URL (/api/admin/bots/{id}/token)
+	pathParts := strings.Split(r.URL.Path, "/")
+	if len(pathParts) < 5 {
+		http.Error(w, "URL invalide", http.StatusBadRequest)
+		return
+	}
+	botID, err := strconv.Atoi(pathParts[4])
+	if err != nil {
+		http.Error(w, "ID de bot invalide", http.StatusBadRequest)
+		return
+	}
+
+	writeBotToken(w, r, botID, http.StatusOK)
+}
+
+// writeBotToken délivre un jeton au bot et retourne le bot et la valeur du jeton
+func writeBotToken(w http.ResponseWriter, r *http.Request, botID int, status int) {
+	token, value, err := database.IssueBotToken(botID)
+	if err != nil {
+		log.Printf("Erreur lors de la délivrance du jeton du bot: %v", err)
+		http.Error(w, "Bot non trouvé", http.StatusNotFound)
+		return
+	}
+
+	bot, err := database.GetUserByID(botID)
+	if err != nil {
+		http.Error(w, "Bot non trouvé", http.StatusNotFound)
+		return
+	}
+
+	log.Printf("Jeton délivré au bot ID=%d", botID)
+	recordAudit(r, auditTarget(database.AuditTokenCreated, database.AuditTargetToken, token.ID,
+		map[string]interface{}{"bot": botID}))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"bot":   bot.Private(),
+		"token": token,
+		"value": value,
+	})
+}
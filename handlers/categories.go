@@ -0,0 +1,317 @@
+// fichier: handlers/categories.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"realtimeforum/database"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limites des champs d'une catégorie
+const (
+	maxCategoryNameLength        = 50
+	maxCategoryDescriptionLength = 500
+	maxCategoryIconLength        = 32
+)
+
+// CreateCategoryHandler crée une catégorie ou une sous-catégorie (POST /api/admin/categories)
+func CreateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request database.CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+	if request.Name == nil {
+		http.Error(w, "Nom de catégorie manquant", http.StatusBadRequest)
+		return
+	}
+	if request.Archived != nil {
+		http.Error(w, "Une nouvelle catégorie ne peut pas être archivée", http.StatusBadRequest)
+		return
+	}
+
+	category := &database.Category{}
+	if err := applyCategoryRequest(category, request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	categoryID, err := database.CreateCategory(category)
+	if err == database.ErrCategoryNameTaken {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors de la création de la catégorie: %v", err)
+		http.Error(w, "Erreur lors de la création de la catégorie", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Catégorie ID=%d créée (%s)", categoryID, category.Name)
+	recordAudit(r, auditTarget(database.AuditCategoryCreated, database.AuditTargetCategory, categoryID,
+		map[string]interface{}{"name": category.Name, "parentId": category.ParentID}))
+
+	respondWithCategory(w, categoryID, http.StatusCreated)
+}
+
+// UpdateCategoryHandler renomme, décrit, déplace, archive ou désarchive une catégorie
+// (PUT /api/admin/categories/{id}) ; seuls les champs fournis sont modifiés
+func UpdateCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPut {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	categoryID, err := pathID(r, 4)
+	if err != nil {
+		http.Error(w, "ID de catégorie invalide", http.StatusBadRequest)
+		return
+	}
+
+	var request database.CategoryRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	category, err := database.GetCategoryByID(categoryID)
+	if err != nil {
+		http.Error(w, "Catégorie non trouvée", http.StatusNotFound)
+		return
+	}
+	previousName := category.Name
+
+	if err := applyCategoryRequest(category, request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = database.UpdateCategory(category)
+	if err == database.ErrCategoryNameTaken {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err == database.ErrCategoryNotFound {
+		http.Error(w, "Catégorie non trouvée", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors de la modification de la catégorie: %v", err)
+		http.Error(w, "Erreur lors de la modification de la catégorie", http.StatusInternalServerError)
+		return
+	}
+
+	details := map[string]interface{}{"name": category.Name, "parentId": category.ParentID, "archived": category.Archived}
+	if previousName != category.Name {
+		details["previousName"] = previousName
+	}
+	recordAudit(r, auditTarget(database.AuditCategoryUpdated, database.AuditTargetCategory, categoryID, details))
+
+	respondWithCategory(w, categoryID, http.StatusOK)
+}
+
+// ReorderCategoriesHandler fixe l'ordre d'affichage des catégories (PUT /api/admin/categories/order).
+// Les catégories sont triées par position parmi celles de même niveau : il suffit d'envoyer
+// les catégories principales, ou les sous-catégories d'une même catégorie, dans l'ordre voulu.
+func ReorderCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPut {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Order []int `json:"order"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+	if len(request.Order) == 0 {
+		http.Error(w, "Ordre des catégories manquant", http.StatusBadRequest)
+		return
+	}
+	seen := make(map[int]bool, len(request.Order))
+	for _, categoryID := range request.Order {
+		if seen[categoryID] {
+			http.Error(w, "Catégorie en double dans l'ordre demandé", http.StatusBadRequest)
+			return
+		}
+		seen[categoryID] = true
+	}
+
+	err := database.ReorderCategories(request.Order)
+	if err == database.ErrCategoryNotFound {
+		http.Error(w, "Catégorie non trouvée", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors du réordonnancement des catégories: %v", err)
+		http.Error(w, "Erreur lors du réordonnancement des catégories", http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(r, database.AuditEntry{
+		Action:     database.AuditCategoryUpdated,
+		TargetType: database.AuditTargetCategory,
+		Details:    map[string]interface{}{"order": request.Order},
+	})
+
+	categories, err := database.GetAllCategories()
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des catégories", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(categories)
+}
+
+// DeleteCategoryHandler supprime une catégorie (DELETE /api/admin/categories/{id}?moveTo={id}).
+// Les publications de la catégorie sont déplacées vers moveTo, obligatoire si elle n'est pas vide.
+func DeleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	categoryID, err := pathID(r, 4)
+	if err != nil {
+		http.Error(w, "ID de catégorie invalide", http.StatusBadRequest)
+		return
+	}
+
+	moveTo := 0
+	if value := r.URL.Query().Get("moveTo"); value != "" {
+		moveTo, err = strconv.Atoi(value)
+		if err != nil || moveTo == categoryID {
+			http.Error(w, "Catégorie de destination invalide", http.StatusBadRequest)
+			return
+		}
+		if _, err := database.GetCategoryByID(moveTo); err != nil {
+			http.Error(w, "Catégorie de destination non trouvée", http.StatusBadRequest)
+			return
+		}
+	}
+
+	category, err := database.GetCategoryByID(categoryID)
+	if err != nil {
+		http.Error(w, "Catégorie non trouvée", http.StatusNotFound)
+		return
+	}
+
+	moved, err := database.DeleteCategory(categoryID, moveTo)
+	if err == database.ErrCategoryNotEmpty {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err == database.ErrCategoryNotFound {
+		http.Error(w, "Catégorie non trouvée", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors de la suppression de la catégorie: %v", err)
+		http.Error(w, "Erreur lors de la suppression de la catégorie", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Catégorie ID=%d supprimée, %d publication(s) déplacée(s) vers la catégorie ID=%d", categoryID, moved, moveTo)
+	details := map[string]interface{}{"name": category.Name, "movedPosts": moved}
+	if moveTo != 0 {
+		details["moveTo"] = moveTo
+	}
+	recordAudit(r, auditTarget(database.AuditCategoryDeleted, database.AuditTargetCategory, categoryID, details))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyCategoryRequest valide les champs fournis et les applique à la catégorie
+func applyCategoryRequest(category *database.Category, request database.CategoryRequest) error {
+	if request.Name != nil {
+		name := strings.TrimSpace(*request.Name)
+		if name == "" || utf8.RuneCountInString(name) > maxCategoryNameLength {
+			return errors.New("Nom de catégorie manquant ou trop long")
+		}
+		category.Name = name
+	}
+	if request.Description != nil {
+		description := strings.TrimSpace(*request.Description)
+		if utf8.RuneCountInString(description) > maxCategoryDescriptionLength {
+			return errors.New("Description trop longue")
+		}
+		category.Description = description
+	}
+	if request.Icon != nil {
+		icon := strings.TrimSpace(*request.Icon)
+		if len(icon) > maxCategoryIconLength {
+			return errors.New("Icône trop longue")
+		}
+		category.Icon = icon
+	}
+	if request.ParentID != nil {
+		if err := checkCategoryParent(category.ID, *request.ParentID); err != nil {
+			return err
+		}
+		category.ParentID = nil
+		if *request.ParentID != 0 {
+			category.ParentID = request.ParentID
+		}
+	}
+	if request.Archived != nil {
+		category.Archived = *request.Archived
+	}
+	return nil
+}
+
+// checkCategoryParent vérifie qu'une catégorie (0 si elle n'existe pas encore) peut être rattachée
+// au parent demandé : un seul niveau de sous-catégories est autorisé
+func checkCategoryParent(categoryID, parentID int) error {
+	if parentID == 0 {
+		return nil
+	}
+	if parentID == categoryID {
+		return errors.New("Une catégorie ne peut pas être sa propre catégorie parente")
+	}
+
+	parent, err := database.GetCategoryByID(parentID)
+	if err != nil {
+		return errors.New("Catégorie parente non trouvée")
+	}
+	if parent.ParentID != nil {
+		return errors.New("Une sous-catégorie ne peut pas avoir de sous-catégories")
+	}
+
+	if categoryID != 0 {
+		hasChildren, err := database.HasSubcategories(categoryID)
+		if err != nil || hasChildren {
+			return errors.New("Une catégorie qui a des sous-catégories ne peut pas devenir une sous-catégorie")
+		}
+	}
+	return nil
+}
+
+// respondWithCategory relit une catégorie après modification et la retourne
+func respondWithCategory(w http.ResponseWriter, categoryID int, status int) {
+	category, err := database.GetCategoryByID(categoryID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération de la catégorie", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(category)
+}
@@ -0,0 +1,356 @@
+// fichier: handlers/drafts.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"realtimeforum/database"
+	"realtimeforum/middleware"
+	"realtimeforum/webhooks"
+	"time"
+)
+
+// Nombre maximal de publications programmées traitées par passe
+const draftPublishBatch = 50
+
+// ListDraftsHandler liste les brouillons et publications programmées de l'utilisateur (GET /api/drafts)
+func ListDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	drafts, err := database.GetUserDrafts(userID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des brouillons: %v", err)
+		http.Error(w, "Erreur lors de la récupération des brouillons", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(drafts)
+}
+
+// CreateDraftHandler enregistre un nouveau brouillon (POST /api/drafts)
+func CreateDraftHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	var draft database.PostDraft
+	if err := json.NewDecoder(r.Body).Decode(&draft); err != nil {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+	if err := prepareDraft(&draft); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	draft.UserID = userID
+
+	draftID, err := database.CreateDraft(&draft)
+	if err != nil {
+		log.Printf("Erreur lors de l'enregistrement du brouillon: %v", err)
+		http.Error(w, "Erreur lors de l'enregistrement du brouillon", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithDraft(w, draftID, userID, http.StatusCreated)
+}
+
+// DraftHandler lit, enregistre (sauvegarde automatique) ou abandonne un brouillon de l'utilisateur
+// (GET, PUT et DELETE /api/drafts/{id})
+func DraftHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	draftID, err := pathID(r, 3)
+	if err != nil {
+		http.Error(w, "ID de brouillon invalide", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		respondWithDraft(w, draftID, userID, http.StatusOK)
+
+	case http.MethodPut:
+		// Le brouillon est remplacé en entier : un champ absent est vidé
+		var draft database.PostDraft
+		if err := json.NewDecoder(r.Body).Decode(&draft); err != nil {
+			http.Error(w, "Données invalides", http.StatusBadRequest)
+			return
+		}
+		if err := prepareDraft(&draft); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		draft.ID = draftID
+		draft.UserID = userID
+
+		err := database.UpdateDraft(&draft)
+		if errors.Is(err, database.ErrDraftNotFound) {
+			http.Error(w, "Brouillon non trouvé", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Erreur lors de l'enregistrement du brouillon: %v", err)
+			http.Error(w, "Erreur lors de l'enregistrement du brouillon", http.StatusInternalServerError)
+			return
+		}
+		respondWithDraft(w, draftID, userID, http.StatusOK)
+
+	case http.MethodDelete:
+		if err := database.DeleteDraft(draftID, userID); err != nil {
+			http.Error(w, "Brouillon non trouvé", http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+	}
+}
+
+// PublishDraftHandler publie immédiatement un brouillon de l'utilisateur (POST /api/drafts/{id}/publish)
+func PublishDraftHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	draftID, err := pathID(r, 3)
+	if err != nil {
+		http.Error(w, "ID de brouillon invalide", http.StatusBadRequest)
+		return
+	}
+
+	draft, err := database.GetDraftByID(draftID, userID)
+	if err != nil {
+		http.Error(w, "Brouillon non trouvé", http.StatusNotFound)
+		return
+	}
+
+	post := draftPost(draft)
+	if err := preparePost(post); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	postID, err := database.PublishDraft(draftID, userID, post)
+	if errors.Is(err, database.ErrDraftNotFound) {
+		http.Error(w, "Brouillon non trouvé", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors de la publication du brouillon: %v", err)
+		http.Error(w, "Erreur lors de la création de la publication", http.StatusInternalServerError)
+		return
+	}
+
+	createdPost, err := database.GetPostByID(postID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération de la publication", http.StatusInternalServerError)
+		return
+	}
+
+	// Notifier les webhooks abonnés ; comme pour une création directe, le client diffuse la publication
+	webhooks.Emit(database.EventPostCreated, createdPost)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createdPost)
+}
+
+// prepareDraft valide un brouillon avant son enregistrement. Un brouillon peut être incomplet,
+// mais ses étiquettes sont normalisées ; un brouillon programmé doit être publiable en l'état
+// et sa date de publication située dans le futur.
+func prepareDraft(draft *database.PostDraft) error {
+	tags, err := normalizeTags(draft.Tags)
+	if err != nil {
+		return err
+	}
+	if len(tags) > maxPostTags {
+		return fmt.Errorf("Trop d'étiquettes (%d au plus)", maxPostTags)
+	}
+	draft.Tags = tags
+
+	if draft.PublishAt == nil {
+		return nil
+	}
+	if !draft.PublishAt.After(time.Now()) {
+		return errors.New("La date de publication doit être dans le futur")
+	}
+	if draft.Poll != nil && draft.Poll.ClosesAt != nil && !draft.Poll.ClosesAt.After(*draft.PublishAt) {
+		return errors.New("La date de clôture du sondage doit suivre la date de publication")
+	}
+	return preparePost(draftPost(draft))
+}
+
+// draftPost construit la publication correspondant à un brouillon
+func draftPost(draft *database.PostDraft) *database.Post {
+	post := &database.Post{
+		UserID:     draft.UserID,
+		Title:      draft.Title,
+		Content:    draft.Content,
+		CategoryID: draft.CategoryID,
+		Tags:       append([]string(nil), draft.Tags...),
+	}
+	if draft.Poll != nil {
+		poll := *draft.Poll
+		poll.Options = append([]string(nil), draft.Poll.Options...)
+		post.NewPoll = &poll
+	}
+	return post
+}
+
+// respondWithDraft relit un brouillon de l'utilisateur et le retourne
+func respondWithDraft(w http.ResponseWriter, draftID, userID int, status int) {
+	draft, err := database.GetDraftByID(draftID, userID)
+	if errors.Is(err, database.ErrDraftNotFound) {
+		http.Error(w, "Brouillon non trouvé", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors de la récupération du brouillon: %v", err)
+		http.Error(w, "Erreur lors de la récupération du brouillon", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(draft)
+}
+
+// StartDraftPublisher lance en arrière-plan la publication des brouillons programmés.
+// Les échéances sont lues en base : celles passées pendant un arrêt du serveur sont publiées au démarrage.
+func StartDraftPublisher(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			publishDueDrafts()
+			<-ticker.C
+		}
+	}()
+}
+
+// publishDueDrafts publie les brouillons programmés arrivés à échéance
+func publishDueDrafts() {
+	drafts, err := database.GetDueDrafts(draftPublishBatch)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des publications programmées: %v", err)
+		return
+	}
+
+	for _, draft := range drafts {
+		publishScheduledDraft(draft)
+	}
+}
+
+// publishScheduledDraft publie un brouillon programmé et diffuse la publication comme une création
+// directe. Si l'auteur ne peut plus publier ou si le brouillon n'est plus valide (catégorie archivée,
+// sondage clos...), le brouillon est déprogrammé avec le motif de l'échec et l'auteur est prévenu.
+func publishScheduledDraft(draft *database.PostDraft) {
+	post := draftPost(draft)
+
+	reason, err := scheduledPostBlocked(draft.UserID)
+	if err != nil {
+		log.Printf("Erreur lors de la vérification des sanctions: %v", err)
+		return
+	}
+	if reason == "" {
+		if err := preparePost(post); err != nil {
+			reason = err.Error()
+		}
+	}
+	if reason != "" {
+		log.Printf("Publication programmée du brouillon ID=%d impossible: %s", draft.ID, reason)
+		if err := database.FailScheduledDraft(draft.ID, reason); err != nil {
+			log.Printf("Erreur lors de la déprogrammation du brouillon ID=%d: %v", draft.ID, err)
+			return
+		}
+		sendEventToUser(draft.UserID, "draft_failed", map[string]interface{}{
+			"draftId": draft.ID,
+			"title":   draft.Title,
+			"error":   reason,
+		})
+		return
+	}
+
+	postID, err := database.PublishDueDraft(draft.ID, post)
+	if errors.Is(err, database.ErrDraftNotFound) {
+		// Brouillon modifié, déprogrammé ou abandonné entre-temps
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors de la publication du brouillon ID=%d: %v", draft.ID, err)
+		return
+	}
+
+	createdPost, err := database.GetPostByID(postID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération de la publication ID=%d: %v", postID, err)
+		return
+	}
+	log.Printf("Brouillon ID=%d publié (publication ID=%d)", draft.ID, postID)
+
+	webhooks.Emit(database.EventPostCreated, createdPost)
+
+	messageJSON, err := json.Marshal(Message{Type: "post_created", Payload: createdPost})
+	if err != nil {
+		log.Printf("Erreur lors de la sérialisation du message: %v", err)
+		return
+	}
+	broadcastFromAuthor(draft.UserID, "post_created", messageJSON)
+
+	sendEventToUser(draft.UserID, "draft_published", map[string]interface{}{
+		"draftId": draft.ID,
+		"post":    createdPost,
+	})
+}
+
+// scheduledPostBlocked retourne le motif pour lequel un utilisateur ne peut plus publier
+// (suspension, bannissement ou lecture seule), ou une chaîne vide
+func scheduledPostBlocked(userID int) (string, error) {
+	status, err := database.GetActiveSanctions(userID)
+	if err != nil {
+		return "", err
+	}
+	if status.Suspension != nil {
+		return middleware.SanctionMessage(status.Suspension), nil
+	}
+	if status.Mute != nil {
+		return middleware.MuteMessage(status.Mute), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,83 @@
+// fichier: handlers/helpers.go
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Limite maximale d'éléments retournés par page
+const maxPageSize = 100
+
+// parsePagination lit les paramètres limit et offset de la requête
+func parsePagination(r *http.Request, defaultLimit int) (int, int) {
+	limit := defaultLimit
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = l
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	offset := 0
+	if o, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && o >= 0 {
+		offset = o
+	}
+
+	return limit, offset
+}
+
+// pathID extrait l'identifiant numérique situé à la position donnée du chemin
+// (par exemple 4 pour /api/admin/webhooks/{id})
+func pathID(r *http.Request, index int) (int, error) {
+	pathParts := strings.Split(r.URL.Path, "/")
+	if len(pathParts) <= index {
+		return 0, strconv.ErrSyntax
+	}
+	return strconv.Atoi(pathParts[index])
+}
+
+// Marge accordée à l'enveloppe multipart (en-têtes, délimiteurs) au-delà de la taille du fichier
+const multipartOverhead = 64 << 10
+
+// errFileTooLarge est retournée lorsque le fichier envoyé dépasse la taille autorisée
+var errFileTooLarge = errors.New("fichier trop volumineux")
+
+// readMultipartFile lit le nom et le contenu du fichier du champ donné d'un formulaire multipart,
+// sans passer par des fichiers temporaires et en s'arrêtant au-delà de maxBytes
+func readMultipartFile(r *http.Request, field string, maxBytes int64) (string, []byte, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return "", nil, err
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return "", nil, err
+		}
+		if part.FormName() != field || part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, maxBytes+1))
+		part.Close()
+		if err != nil {
+			return "", nil, err
+		}
+		if int64(len(data)) > maxBytes {
+			return "", nil, errFileTooLarge
+		}
+		return part.FileName(), data, nil
+	}
+}
+
+// isUploadTooLarge indique si l'erreur de lecture d'un envoi vient d'un dépassement de taille
+func isUploadTooLarge(err error) bool {
+	var tooLarge *http.MaxBytesError
+	return errors.Is(err, errFileTooLarge) || errors.As(err, &tooLarge)
+}
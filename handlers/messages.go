@@ -10,6 +10,9 @@ import (
 	"strings"
 )
 
+// Message d'erreur retourné pour toute interaction refusée par un blocage
+const errBlockedRecipient = "Vous ne pouvez pas interagir avec cet utilisateur"
+
 // SendPrivateMessageHandler gère l'envoi d'un message privé
 func SendPrivateMessageHandler(w http.ResponseWriter, r *http.Request) {
 	// Vérifier la méthode
@@ -33,8 +36,8 @@ func SendPrivateMessageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validation basique
-	if message.Content == "" || message.ReceiverID <= 0 {
+	// Validation basique (un message peut se limiter à des pièces jointes)
+	if (message.Content == "" && len(message.AttachmentIDs) == 0) || message.ReceiverID <= 0 {
 		http.Error(w, "Données incomplètes", http.StatusBadRequest)
 		return
 	}
@@ -52,11 +55,26 @@ func SendPrivateMessageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Refuser les messages entre deux utilisateurs dont l'un a bloqué l'autre
+	blocked, err := database.IsBlockedBetween(senderID, message.ReceiverID)
+	if err != nil {
+		http.Error(w, "Erreur lors de l'envoi du message", http.StatusInternalServerError)
+		return
+	}
+	if blocked {
+		http.Error(w, errBlockedRecipient, http.StatusForbidden)
+		return
+	}
+
 	// Définir l'ID de l'expéditeur
 	message.SenderID = senderID
 
 	// Créer le message
 	messageID, err := database.CreatePrivateMessage(&message)
+	if isAttachmentError(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Erreur lors de l'envoi du message", http.StatusInternalServerError)
 		return
@@ -198,6 +216,12 @@ func UpdateTypingStatusHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Aucun indicateur de frappe entre deux utilisateurs dont l'un a bloqué l'autre
+	if blocked, err := database.IsBlockedBetween(userID, typingData.TargetUserID); err != nil || blocked {
+		http.Error(w, errBlockedRecipient, http.StatusForbidden)
+		return
+	}
+
 	// Mettre à jour le statut de frappe
 	err = database.UpdateTypingStatus(userID, typingData.TargetUserID, typingData.IsTyping)
 	if err != nil {
@@ -247,6 +271,13 @@ func GetTypingStatusHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Entre deux utilisateurs dont l'un a bloqué l'autre, l'autre n'est jamais montré en train d'écrire
+	if blocked, err := database.IsBlockedBetween(userID, otherUserID); err != nil || blocked {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&database.TypingIndicator{UserID: otherUserID, TargetUserID: userID})
+		return
+	}
+
 	// Récupérer le statut de frappe de l'autre utilisateur vers l'utilisateur courant
 	indicator, err := database.GetTypingStatus(otherUserID, userID)
 	if err != nil {
@@ -0,0 +1,678 @@
+// fichier: handlers/moderation.go
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"realtimeforum/database"
+	"realtimeforum/middleware"
+	"strings"
+	"time"
+)
+
+// Longueur maximale du commentaire accompagnant un signalement
+const maxReportDetailsLength = 1000
+
+// Durée de suspension appliquée lorsque le modérateur n'en précise pas
+const defaultSuspensionHours = 24
+
+// Durée maximale d'une suspension ou d'une mise en lecture seule (un an ; au-delà, bannir)
+const maxSuspensionHours = 365 * 24
+
+// Longueur maximale du motif d'une sanction
+const maxSanctionReasonLength = 500
+
+// Nombre de signalements retournés par défaut dans la file de modération
+const defaultReportsPageSize = 50
+
+// CreateReportHandler permet à un utilisateur de signaler une publication, un commentaire ou un message privé
+func CreateReportHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	var request database.ReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	request.Details = strings.TrimSpace(request.Details)
+	if !isValidReportReason(request.Reason) {
+		http.Error(w, "Motif de signalement invalide", http.StatusBadRequest)
+		return
+	}
+	if len(request.Details) > maxReportDetailsLength {
+		http.Error(w, "Commentaire trop long", http.StatusBadRequest)
+		return
+	}
+
+	target, err := database.GetReportTarget(request.TargetType, request.TargetID)
+	if err == database.ErrReportTargetNotFound {
+		http.Error(w, "Contenu non trouvé", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors de la récupération du contenu signalé: %v", err)
+		http.Error(w, "Erreur lors du signalement", http.StatusInternalServerError)
+		return
+	}
+
+	// Un message privé ne peut être signalé que par son destinataire
+	if request.TargetType == database.ReportTargetMessage && target.RecipientID != userID {
+		http.Error(w, "Contenu non trouvé", http.StatusNotFound)
+		return
+	}
+	if target.AuthorID == userID {
+		http.Error(w, "Vous ne pouvez pas signaler votre propre contenu", http.StatusBadRequest)
+		return
+	}
+
+	report, err := database.CreateReport(userID, request, target)
+	if err == database.ErrDuplicateReport {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors de la création du signalement: %v", err)
+		http.Error(w, "Erreur lors du signalement", http.StatusInternalServerError)
+		return
+	}
+
+	// Prévenir les modérateurs connectés
+	notifyModerators("report_created", report)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetReportsHandler liste la file de modération (GET /api/moderation/reports?status=open)
+func GetReportsHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", database.ReportOpen, database.ReportClaimed, database.ReportResolved, database.ReportDismissed:
+	default:
+		http.Error(w, "Statut invalide", http.StatusBadRequest)
+		return
+	}
+
+	limit, offset := parsePagination(r, defaultReportsPageSize)
+	reports, err := database.GetReports(status, limit, offset)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des signalements: %v", err)
+		http.Error(w, "Erreur lors de la récupération des signalements", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reports)
+}
+
+// ClaimReportHandler attribue un signalement ouvert au modérateur courant
+// (POST /api/moderation/reports/{id}/claim)
+func ClaimReportHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	moderatorID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	reportID, err := pathID(r, 4)
+	if err != nil {
+		http.Error(w, "ID de signalement invalide", http.StatusBadRequest)
+		return
+	}
+	if _, err := database.GetReportByID(reportID); err != nil {
+		http.Error(w, "Signalement non trouvé", http.StatusNotFound)
+		return
+	}
+
+	if err := database.ClaimReport(reportID, moderatorID); err != nil {
+		http.Error(w, database.ErrReportNotPending.Error(), http.StatusConflict)
+		return
+	}
+	recordAudit(r, auditTarget(database.AuditReportClaimed, database.AuditTargetReport, reportID, nil))
+
+	respondWithReport(w, reportID)
+}
+
+// ResolveReportHandler clôt un signalement en appliquant les actions demandées
+// (POST /api/moderation/reports/{id}/resolve)
+func ResolveReportHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	moderatorID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	reportID, err := pathID(r, 4)
+	if err != nil {
+		http.Error(w, "ID de signalement invalide", http.StatusBadRequest)
+		return
+	}
+
+	var request database.ResolveReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+	actions, err := normalizeModerationActions(request.Actions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.SuspensionHours == 0 {
+		request.SuspensionHours = defaultSuspensionHours
+	}
+	if request.SuspensionHours < 0 || request.SuspensionHours > maxSuspensionHours {
+		http.Error(w, "Durée de suspension invalide", http.StatusBadRequest)
+		return
+	}
+
+	report, err := database.GetReportByID(reportID)
+	if err != nil {
+		http.Error(w, "Signalement non trouvé", http.StatusNotFound)
+		return
+	}
+
+	// Clore le signalement avant d'agir : deux modérateurs ne peuvent pas sanctionner deux fois
+	if err := database.CloseReport(reportID, moderatorID, database.ReportResolved, actions, request.Note); err != nil {
+		http.Error(w, database.ErrReportNotPending.Error(), http.StatusConflict)
+		return
+	}
+	recordAudit(r, auditTarget(database.AuditReportResolved, database.AuditTargetReport, reportID,
+		map[string]interface{}{"actions": actions, "note": request.Note}))
+
+	for _, action := range actions {
+		if err := applyModerationAction(r, action, report, moderatorID, request); err != nil {
+			log.Printf("Erreur lors de l'action de modération %s sur le signalement ID=%d: %v", action, reportID, err)
+			http.Error(w, "Erreur lors de l'application des actions de modération", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	respondWithReport(w, reportID)
+}
+
+// DismissReportHandler rejette un signalement sans action (POST /api/moderation/reports/{id}/dismiss)
+func DismissReportHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	moderatorID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	reportID, err := pathID(r, 4)
+	if err != nil {
+		http.Error(w, "ID de signalement invalide", http.StatusBadRequest)
+		return
+	}
+
+	// La note est facultative
+	var request struct {
+		Note string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Données invalides", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, err := database.GetReportByID(reportID); err != nil {
+		http.Error(w, "Signalement non trouvé", http.StatusNotFound)
+		return
+	}
+	if err := database.CloseReport(reportID, moderatorID, database.ReportDismissed, nil, request.Note); err != nil {
+		http.Error(w, database.ErrReportNotPending.Error(), http.StatusConflict)
+		return
+	}
+	recordAudit(r, auditTarget(database.AuditReportDismissed, database.AuditTargetReport, reportID,
+		map[string]interface{}{"note": request.Note}))
+
+	respondWithReport(w, reportID)
+}
+
+// GetUserSanctionsHandler retourne l'historique des sanctions d'un utilisateur
+// (GET /api/moderation/users/{id}/sanctions)
+func GetUserSanctionsHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := pathID(r, 4)
+	if err != nil {
+		http.Error(w, "ID utilisateur invalide", http.StatusBadRequest)
+		return
+	}
+	if _, err := database.GetUserByID(userID); err != nil {
+		http.Error(w, "Utilisateur non trouvé", http.StatusNotFound)
+		return
+	}
+
+	sanctions, err := database.GetUserSanctions(userID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des sanctions: %v", err)
+		http.Error(w, "Erreur lors de la récupération des sanctions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sanctions)
+}
+
+// CreateSanctionHandler prononce une sanction contre un utilisateur
+// (POST /api/moderation/users/{id}/sanctions)
+func CreateSanctionHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	moderatorID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := pathID(r, 4)
+	if err != nil {
+		http.Error(w, "ID utilisateur invalide", http.StatusBadRequest)
+		return
+	}
+	if userID == moderatorID {
+		http.Error(w, "Action impossible sur soi-même", http.StatusBadRequest)
+		return
+	}
+
+	var request database.SanctionRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+	request.Reason = strings.TrimSpace(request.Reason)
+	if request.Reason == "" || len(request.Reason) > maxSanctionReasonLength {
+		http.Error(w, "Motif de sanction manquant ou trop long", http.StatusBadRequest)
+		return
+	}
+
+	sanction := &database.Sanction{
+		UserID:      userID,
+		Kind:        request.Kind,
+		Reason:      request.Reason,
+		ModeratorID: &moderatorID,
+	}
+	switch request.Kind {
+	case database.SanctionWarning, database.SanctionBan:
+		if request.DurationHours != 0 {
+			http.Error(w, "Durée sans objet pour cette sanction", http.StatusBadRequest)
+			return
+		}
+	case database.SanctionSuspension, database.SanctionMute:
+		// Une mise en lecture seule sans durée est levée manuellement
+		if request.DurationHours < 0 || request.DurationHours > maxSuspensionHours ||
+			(request.Kind == database.SanctionSuspension && request.DurationHours == 0) {
+			http.Error(w, "Durée de sanction invalide", http.StatusBadRequest)
+			return
+		}
+		if request.DurationHours > 0 {
+			expiresAt := time.Now().UTC().Add(time.Duration(request.DurationHours) * time.Hour)
+			sanction.ExpiresAt = &expiresAt
+		}
+	default:
+		http.Error(w, "Type de sanction invalide", http.StatusBadRequest)
+		return
+	}
+	if request.BanIP && request.Kind != database.SanctionBan {
+		http.Error(w, "Seul un bannissement peut viser l'adresse IP", http.StatusBadRequest)
+		return
+	}
+
+	// Seul un administrateur peut sanctionner un membre de l'équipe de modération
+	target, err := database.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "Utilisateur non trouvé", http.StatusNotFound)
+		return
+	}
+	if target.Role != database.RoleUser {
+		role, err := database.GetUserRole(moderatorID)
+		if err != nil || role != database.RoleAdmin {
+			http.Error(w, "Accès refusé", http.StatusForbidden)
+			return
+		}
+	}
+
+	if request.BanIP {
+		ip, err := database.GetLastLoginIP(userID)
+		if err != nil {
+			log.Printf("Erreur lors de la récupération de l'adresse IP: %v", err)
+			http.Error(w, "Erreur lors de l'application de la sanction", http.StatusInternalServerError)
+			return
+		}
+		if ip == "" {
+			http.Error(w, "Aucune adresse IP connue pour cet utilisateur", http.StatusBadRequest)
+			return
+		}
+		sanction.IP = ip
+	}
+
+	if err := applySanction(r, sanction, request.Reason); err != nil {
+		log.Printf("Erreur lors de l'application de la sanction: %v", err)
+		http.Error(w, "Erreur lors de l'application de la sanction", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(sanction)
+}
+
+// RevokeSanctionHandler lève une sanction avant son terme (DELETE /api/moderation/sanctions/{id})
+func RevokeSanctionHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	moderatorID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	sanctionID, err := pathID(r, 4)
+	if err != nil {
+		http.Error(w, "ID de sanction invalide", http.StatusBadRequest)
+		return
+	}
+
+	sanction, err := database.RevokeSanction(sanctionID, moderatorID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	log.Printf("Sanction ID=%d levée par le modérateur ID=%d", sanctionID, moderatorID)
+	recordAudit(r, auditTarget(database.AuditSanctionRevoked, database.AuditTargetSanction, sanctionID,
+		map[string]interface{}{"user": sanction.UserID, "kind": sanction.Kind}))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sanction)
+}
+
+// UpdatePostStateHandler épingle, verrouille ou fait d'une publication une annonce
+// (PUT /api/moderation/posts/{id}) ; le nouvel état est diffusé à tous les clients
+func UpdatePostStateHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPut {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	postID, err := pathID(r, 4)
+	if err != nil {
+		http.Error(w, "ID de publication invalide", http.StatusBadRequest)
+		return
+	}
+
+	var request database.PostStateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	post, err := database.GetPostByID(postID)
+	if err != nil {
+		http.Error(w, "Publication non trouvée", http.StatusNotFound)
+		return
+	}
+
+	previous := database.PostState{Pinned: post.Pinned, Locked: post.Locked, Announcement: post.Announcement}
+	if request.Pinned != nil {
+		switch *request.Pinned {
+		case "", database.PinCategory, database.PinGlobal:
+			post.Pinned = *request.Pinned
+		default:
+			http.Error(w, "Portée d'épinglage invalide", http.StatusBadRequest)
+			return
+		}
+	}
+	if request.Locked != nil {
+		post.Locked = *request.Locked
+	}
+	if request.Announcement != nil {
+		post.Announcement = *request.Announcement
+	}
+
+	if err := database.SetPostState(postID, post.Pinned, post.Locked, post.Announcement); err != nil {
+		log.Printf("Erreur lors de la modification de l'état de la publication: %v", err)
+		http.Error(w, "Erreur lors de la modification de la publication", http.StatusInternalServerError)
+		return
+	}
+
+	state := database.PostState{
+		PostID:       postID,
+		CategoryID:   post.CategoryID,
+		Pinned:       post.Pinned,
+		Locked:       post.Locked,
+		Announcement: post.Announcement,
+	}
+	log.Printf("État de la publication ID=%d modifié (épinglée: %q, verrouillée: %t, annonce: %t)",
+		postID, state.Pinned, state.Locked, state.Announcement)
+	recordAudit(r, auditTarget(database.AuditPostStateChanged, database.ReportTargetPost, postID,
+		map[string]interface{}{"from": previous, "to": state}))
+
+	broadcastEvent("post_state_changed", state)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(post)
+}
+
+// SetUserRoleHandler change le rôle d'un utilisateur (PUT /api/admin/users/{id}/role)
+func SetUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPut {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	adminID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	userID, err := pathID(r, 4)
+	if err != nil {
+		http.Error(w, "ID utilisateur invalide", http.StatusBadRequest)
+		return
+	}
+	if userID == adminID {
+		http.Error(w, "Vous ne pouvez pas modifier votre propre rôle", http.StatusBadRequest)
+		return
+	}
+
+	var request struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+	switch request.Role {
+	case database.RoleUser, database.RoleModerator, database.RoleAdmin:
+	default:
+		http.Error(w, "Rôle invalide", http.StatusBadRequest)
+		return
+	}
+
+	previousRole, err := database.GetUserRole(userID)
+	if err != nil {
+		http.Error(w, "Utilisateur non trouvé", http.StatusNotFound)
+		return
+	}
+	if err := database.SetUserRole(userID, request.Role); err != nil {
+		http.Error(w, "Utilisateur non trouvé", http.StatusNotFound)
+		return
+	}
+	log.Printf("Rôle de l'utilisateur ID=%d changé en %s par l'administrateur ID=%d", userID, request.Role, adminID)
+	recordAudit(r, auditTarget(database.AuditRoleChanged, database.AuditTargetUser, userID,
+		map[string]interface{}{"from": previousRole, "to": request.Role}))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// isValidReportReason vérifie que le motif fait partie des catégories acceptées
+func isValidReportReason(reason string) bool {
+	for _, allowed := range database.ReportReasons {
+		if reason == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeModerationActions valide les actions demandées et retire les doublons
+func normalizeModerationActions(actions []string) ([]string, error) {
+	seen := make(map[string]bool, len(actions))
+	normalized := make([]string, 0, len(actions))
+	for _, action := range actions {
+		switch action {
+		case database.ModerationHide, database.ModerationWarn, database.ModerationSuspend:
+		default:
+			return nil, fmt.Errorf("action de modération inconnue: %q", action)
+		}
+		if !seen[action] {
+			seen[action] = true
+			normalized = append(normalized, action)
+		}
+	}
+	return normalized, nil
+}
+
+// applyModerationAction applique une action de modération au contenu ou à l'auteur d'un signalement
+func applyModerationAction(r *http.Request, action string, report *database.Report, moderatorID int, request database.ResolveReportRequest) error {
+	switch action {
+	case database.ModerationHide:
+		if err := database.HideContent(report.TargetType, report.TargetID); err != nil {
+			return err
+		}
+		recordAudit(r, auditTarget(database.AuditContentHidden, report.TargetType, report.TargetID,
+			map[string]interface{}{"report": report.ID}))
+		return nil
+
+	case database.ModerationWarn:
+		return applySanction(r, &database.Sanction{
+			UserID:      report.TargetUserID,
+			Kind:        database.SanctionWarning,
+			Reason:      report.Reason,
+			ModeratorID: &moderatorID,
+			ReportID:    &report.ID,
+		}, request.Note)
+
+	case database.ModerationSuspend:
+		expiresAt := time.Now().UTC().Add(time.Duration(request.SuspensionHours) * time.Hour)
+		return applySanction(r, &database.Sanction{
+			UserID:      report.TargetUserID,
+			Kind:        database.SanctionSuspension,
+			Reason:      report.Reason,
+			ModeratorID: &moderatorID,
+			ReportID:    &report.ID,
+			ExpiresAt:   &expiresAt,
+		}, request.Note)
+	}
+
+	return nil
+}
+
+// applySanction enregistre une sanction et l'applique immédiatement : l'utilisateur est prévenu
+// d'un avertissement ou d'une mise en lecture seule, et perd ses sessions et sa connexion temps réel
+// en cas de suspension ou de bannissement
+func applySanction(r *http.Request, sanction *database.Sanction, message string) error {
+	if err := database.CreateSanction(sanction); err != nil {
+		return err
+	}
+	recordAudit(r, auditTarget(database.AuditSanctionCreated, database.AuditTargetSanction, sanction.ID,
+		map[string]interface{}{
+			"user":      sanction.UserID,
+			"kind":      sanction.Kind,
+			"reason":    sanction.Reason,
+			"expiresAt": sanction.ExpiresAt,
+			"ip":        sanction.IP,
+		}))
+
+	switch sanction.Kind {
+	case database.SanctionWarning:
+		sendEventToUser(sanction.UserID, "moderation_warning", map[string]interface{}{
+			"reason":  sanction.Reason,
+			"message": message,
+		})
+	case database.SanctionMute:
+		sendEventToUser(sanction.UserID, "moderation_mute", map[string]interface{}{
+			"reason":    sanction.Reason,
+			"message":   message,
+			"expiresAt": sanction.ExpiresAt,
+		})
+	case database.SanctionSuspension, database.SanctionBan:
+		if err := database.DeleteUserSessions(sanction.UserID); err != nil {
+			return err
+		}
+		disconnectUser(sanction.UserID, closeAccountSuspended, sanction.Kind)
+	}
+
+	log.Printf("Sanction %s appliquée à l'utilisateur ID=%d", sanction.Kind, sanction.UserID)
+	return nil
+}
+
+// respondWithReport relit un signalement après modification, prévient les modérateurs et le retourne
+func respondWithReport(w http.ResponseWriter, reportID int) {
+	report, err := database.GetReportByID(reportID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération du signalement", http.StatusInternalServerError)
+		return
+	}
+
+	notifyModerators("report_updated", report)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,157 @@
+// fichier: handlers/polls.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"realtimeforum/database"
+	"realtimeforum/middleware"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Limites des sondages
+const (
+	maxPollQuestionLength = 300
+	maxPollOptionLength   = 100
+	minPollOptions        = 2
+	maxPollOptions        = 10
+)
+
+// normalizePollRequest valide le sondage soumis avec une publication et retire les espaces superflus
+func normalizePollRequest(request *database.PollRequest) error {
+	request.Question = strings.TrimSpace(request.Question)
+	if request.Question == "" || utf8.RuneCountInString(request.Question) > maxPollQuestionLength {
+		return fmt.Errorf("la question du sondage est requise (%d caractères au plus)", maxPollQuestionLength)
+	}
+
+	if len(request.Options) < minPollOptions || len(request.Options) > maxPollOptions {
+		return fmt.Errorf("un sondage comporte de %d à %d choix", minPollOptions, maxPollOptions)
+	}
+	seen := make(map[string]bool, len(request.Options))
+	for i, option := range request.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || utf8.RuneCountInString(option) > maxPollOptionLength {
+			return fmt.Errorf("choix de sondage invalide (%d caractères au plus)", maxPollOptionLength)
+		}
+		if seen[strings.ToLower(option)] {
+			return fmt.Errorf("choix de sondage en double: %q", option)
+		}
+		seen[strings.ToLower(option)] = true
+		request.Options[i] = option
+	}
+
+	if request.ClosesAt != nil && !request.ClosesAt.After(time.Now()) {
+		return errors.New("la date de clôture du sondage doit être dans le futur")
+	}
+	return nil
+}
+
+// GetPollHandler retourne les résultats du sondage d'une publication, avec les votants d'un
+// sondage public et les choix de l'utilisateur connecté (GET /api/posts/{id}/poll)
+func GetPollHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	postID, err := pathID(r, 3)
+	if err != nil {
+		http.Error(w, "ID de publication invalide", http.StatusBadRequest)
+		return
+	}
+
+	// L'authentification est facultative : un visiteur voit les résultats sans ses choix
+	viewerID, _ := middleware.GetUserID(r)
+	poll, err := database.GetPollByPostID(postID, viewerID)
+	if errors.Is(err, database.ErrPollNotFound) {
+		http.Error(w, "Sondage non trouvé", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors de la récupération du sondage: %v", err)
+		http.Error(w, "Erreur lors de la récupération du sondage", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(poll)
+}
+
+// VotePollHandler enregistre le vote de l'utilisateur au sondage d'une publication et diffuse
+// les nouveaux résultats (POST /api/posts/{id}/poll/votes, corps {"options": [ids]})
+func VotePollHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	postID, err := pathID(r, 3)
+	if err != nil {
+		http.Error(w, "ID de publication invalide", http.StatusBadRequest)
+		return
+	}
+
+	var request struct {
+		Options []int `json:"options"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || len(request.Options) == 0 {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	poll, err := database.GetPollByPostID(postID, userID)
+	if errors.Is(err, database.ErrPollNotFound) {
+		http.Error(w, "Sondage non trouvé", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors de la récupération du sondage: %v", err)
+		http.Error(w, "Erreur lors de la récupération du sondage", http.StatusInternalServerError)
+		return
+	}
+	if !poll.Multiple && len(request.Options) != 1 {
+		http.Error(w, "Ce sondage n'accepte qu'un seul choix", http.StatusBadRequest)
+		return
+	}
+
+	// La base garantit un seul bulletin par utilisateur et refuse les sondages clos
+	err = database.CastVote(poll.ID, userID, request.Options)
+	switch {
+	case errors.Is(err, database.ErrAlreadyVoted), errors.Is(err, database.ErrPollClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, database.ErrInvalidOptions):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Printf("Erreur lors de l'enregistrement du vote: %v", err)
+		http.Error(w, "Erreur lors de l'enregistrement du vote", http.StatusInternalServerError)
+		return
+	}
+
+	// Diffuser les résultats sans les choix propres à l'utilisateur
+	results, err := database.GetPollByPostID(postID, 0)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération du sondage: %v", err)
+		http.Error(w, "Erreur lors de la récupération du sondage", http.StatusInternalServerError)
+		return
+	}
+	broadcastEvent("poll_updated", results)
+
+	results.MyVotes = request.Options
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
@@ -3,13 +3,20 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"realtimeforum/database"
 	"realtimeforum/middleware"
+	"realtimeforum/webhooks"
 	"strconv"
 	"strings"
 )
 
+// Nombre de publications non épinglées retournées par page de GET /api/posts
+const defaultPostsPerPage = 20
+
 // CreatePostHandler gère la création d'une nouvelle publication
 func CreatePostHandler(w http.ResponseWriter, r *http.Request) {
 	// Vérifier la méthode
@@ -33,9 +40,9 @@ func CreatePostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Validation basique
-	if post.Title == "" || post.Content == "" || post.CategoryID <= 0 {
-		http.Error(w, "Données incomplètes", http.StatusBadRequest)
+	// Valider la publication
+	if err := preparePost(&post); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
@@ -44,6 +51,10 @@ func CreatePostHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Créer la publication
 	postID, err := database.CreatePost(&post)
+	if isAttachmentError(err) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Erreur lors de la création de la publication", http.StatusInternalServerError)
 		return
@@ -56,13 +67,51 @@ func CreatePostHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Notifier les webhooks abonnés
+	webhooks.Emit(database.EventPostCreated, createdPost)
+
 	// Retourner la publication créée
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(createdPost)
 }
 
-// GetPostsHandler récupère toutes les publications ou filtre par catégorie
+// preparePost valide une publication avant sa création : champs requis, étiquettes normalisées,
+// sondage éventuel et catégorie ouverte. Les erreurs retournées peuvent être présentées à l'auteur.
+func preparePost(post *database.Post) error {
+	// Validation basique
+	if post.Title == "" || post.Content == "" || post.CategoryID <= 0 {
+		return errors.New("Données incomplètes")
+	}
+
+	// Normaliser les étiquettes
+	tags, err := normalizeTags(post.Tags)
+	if err != nil {
+		return err
+	}
+	if len(tags) > maxPostTags {
+		return fmt.Errorf("Trop d'étiquettes (%d au plus)", maxPostTags)
+	}
+	post.Tags = tags
+
+	// Valider le sondage éventuel
+	if post.NewPoll != nil {
+		if err := normalizePollRequest(post.NewPoll); err != nil {
+			return err
+		}
+	}
+
+	// Une catégorie archivée n'accepte plus de publications
+	category, err := database.GetCategoryByID(post.CategoryID)
+	if err != nil || category.Archived {
+		return errors.New("Catégorie inexistante ou archivée")
+	}
+	return nil
+}
+
+// GetPostsHandler récupère une page des publications (?limit=&offset=, épinglées en tête de la première page),
+// filtrées par catégorie et par étiquettes (?tags=go,sql&match=all|any), triées selon ?sort=new|active|top|hot
+// (avec ?period=day|week|month|year|all pour le tri par score)
 func GetPostsHandler(w http.ResponseWriter, r *http.Request) {
 	// Vérifier la méthode
 	if r.Method != http.MethodGet {
@@ -70,31 +119,61 @@ func GetPostsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Vérifier s'il y a un filtre par catégorie
-	categoryIDStr := r.URL.Query().Get("category")
-	var posts []*database.Post
-	var err error
+	query := r.URL.Query()
+	var filter database.PostFilter
 
-	if categoryIDStr != "" {
-		// Convertir l'ID de catégorie en entier
+	// Vérifier s'il y a un filtre par catégorie
+	if categoryIDStr := query.Get("category"); categoryIDStr != "" {
 		categoryID, err := strconv.Atoi(categoryIDStr)
 		if err != nil {
 			http.Error(w, "ID de catégorie invalide", http.StatusBadRequest)
 			return
 		}
+		filter.CategoryID = categoryID
+	}
 
-		// Récupérer les publications par catégorie
-		posts, err = database.GetPostsByCategory(categoryID)
-	} else {
-		// Récupérer toutes les publications
-		posts, err = database.GetAllPosts()
+	// Vérifier s'il y a un filtre par étiquettes : toutes par défaut, au moins une avec match=any
+	if tagsParam := query.Get("tags"); tagsParam != "" {
+		tags, err := normalizeTags(strings.Split(tagsParam, ","))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter.Tags = tags
+	}
+	switch query.Get("match") {
+	case "", "all":
+		filter.MatchAllTags = true
+	case "any":
+	default:
+		http.Error(w, "Mode de correspondance invalide", http.StatusBadRequest)
+		return
 	}
 
+	if err := parsePostSort(r, &filter); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter.Limit, filter.Offset = parsePagination(r, defaultPostsPerPage)
+
+	// Masquer les auteurs mis en sourdine ou bloqués par l'utilisateur
+	hiddenAuthors := hiddenAuthorsFor(r)
+	for authorID := range hiddenAuthors {
+		filter.ExcludedAuthors = append(filter.ExcludedAuthors, authorID)
+	}
+
+	posts, err := database.GetPosts(filter)
 	if err != nil {
+		log.Printf("Erreur lors de la récupération des publications: %v", err)
 		http.Error(w, "Erreur lors de la récupération des publications", http.StatusInternalServerError)
 		return
 	}
 
+	withPostBookmarks(r, posts)
+	withPostReadStates(r, posts, hiddenAuthors)
+	withPostVotes(r, posts)
+
 	// Retourner les publications
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(posts)
@@ -129,6 +208,10 @@ func GetPostHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Publication non trouvée", http.StatusNotFound)
 		return
 	}
+	recordPostView(w, r, post)
+	withPostBookmarks(r, []*database.Post{post})
+	withPostReadStates(r, []*database.Post{post}, hiddenAuthorsFor(r))
+	withPostVotes(r, []*database.Post{post})
 
 	// Retourner la publication
 	w.Header().Set("Content-Type", "application/json")
@@ -166,12 +249,21 @@ func CreateCommentHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Vérifier que la publication existe
-	_, err = database.GetPostByID(postID)
+	post, err := database.GetPostByID(postID)
 	if err != nil {
 		http.Error(w, "Publication non trouvée", http.StatusNotFound)
 		return
 	}
 
+	// Une publication verrouillée n'accepte plus que les commentaires de l'équipe de modération
+	if post.Locked {
+		role, err := database.GetUserRole(userID)
+		if err != nil || role == database.RoleUser {
+			http.Error(w, "Publication verrouillée : les commentaires sont fermés", http.StatusForbidden)
+			return
+		}
+	}
+
 	// Décoder le corps de la requête
 	var comment database.Comment
 	err = json.NewDecoder(r.Body).Decode(&comment)
@@ -185,6 +277,15 @@ func CreateCommentHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Contenu vide", http.StatusBadRequest)
 		return
 	}
+	comment.Links = nil
+
+	// Citation facultative d'un autre commentaire
+	if comment.Quote != nil {
+		if err := prepareQuote(comment.Quote); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
 	// Définir l'ID utilisateur et l'ID de la publication
 	comment.UserID = userID
@@ -218,6 +319,9 @@ func CreateCommentHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Notifier les webhooks abonnés
+	webhooks.Emit(database.EventCommentCreated, createdComment)
+
 	// Retourner le commentaire créé
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -247,6 +351,12 @@ func GetCommentsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Vérifier que la publication existe (une publication masquée n'est plus accessible)
+	if _, err := database.GetPostByID(postID); err != nil {
+		http.Error(w, "Publication non trouvée", http.StatusNotFound)
+		return
+	}
+
 	// Récupérer les commentaires
 	comments, err := database.GetCommentsByPostID(postID)
 	if err != nil {
@@ -254,6 +364,13 @@ func GetCommentsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Les commentaires renvoyés sont considérés comme lus
+	markCommentsRead(r, postID, comments)
+
+	// Masquer les auteurs mis en sourdine ou bloqués par l'utilisateur
+	comments = filterCommentsByAuthor(comments, hiddenAuthorsFor(r))
+	withCommentBookmarks(r, comments)
+
 	// Retourner les commentaires
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(comments)
@@ -0,0 +1,327 @@
+// fichier: handlers/profile.go
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	netmail "net/mail"
+	"realtimeforum/config"
+	"realtimeforum/database"
+	"realtimeforum/mail"
+	"realtimeforum/middleware"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Limites appliquées aux champs du profil
+const (
+	maxNameLength     = 50
+	maxBioLength      = 500
+	minPasswordLength = 8
+)
+
+// isValidGender indique si le genre fait partie des valeurs acceptées à l'inscription
+func isValidGender(gender string) bool {
+	return gender == "M" || gender == "F" || gender == "Autre"
+}
+
+// UpdateProfileHandler modifie le profil de l'utilisateur courant (modification partielle)
+func UpdateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPut && r.Method != http.MethodPatch {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	// Décoder la requête
+	var update database.ProfileUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	// Valider les champs fournis
+	for _, name := range []*string{update.FirstName, update.LastName} {
+		if name != nil {
+			*name = strings.TrimSpace(*name)
+			if *name == "" || utf8.RuneCountInString(*name) > maxNameLength {
+				http.Error(w, "Le prénom et le nom sont requis (50 caractères maximum)", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if update.Age != nil && *update.Age < 13 {
+		http.Error(w, "Âge invalide", http.StatusBadRequest)
+		return
+	}
+	if update.Gender != nil && !isValidGender(*update.Gender) {
+		http.Error(w, "Genre invalide", http.StatusBadRequest)
+		return
+	}
+	if update.Bio != nil {
+		*update.Bio = strings.TrimSpace(*update.Bio)
+		if utf8.RuneCountInString(*update.Bio) > maxBioLength {
+			http.Error(w, "La bio ne doit pas dépasser 500 caractères", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := database.UpdateUserProfile(userID, update); err != nil {
+		log.Printf("Erreur lors de la mise à jour du profil: %v", err)
+		http.Error(w, "Erreur lors de la mise à jour du profil", http.StatusInternalServerError)
+		return
+	}
+
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération de l'utilisateur", http.StatusInternalServerError)
+		return
+	}
+	// Le nom d'utilisateur et la bio figurent dans la liste des utilisateurs en ligne
+	broadcastOnlineUsers()
+
+	// Retourner la vue privée de l'utilisateur mis à jour
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user.Private())
+}
+
+// GetPrivacySettingsHandler retourne les paramètres de confidentialité de l'utilisateur courant
+func GetPrivacySettingsHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "Utilisateur non trouvé", http.StatusNotFound)
+		return
+	}
+
+	// Retourner les paramètres
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user.Privacy)
+}
+
+// UpdatePrivacySettingsHandler remplace les paramètres de confidentialité de l'utilisateur courant
+func UpdatePrivacySettingsHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPut {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	// Décoder la requête
+	var settings database.PrivacySettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	if err := database.UpdatePrivacySettings(userID, settings); err != nil {
+		log.Printf("Erreur lors de la mise à jour des paramètres de confidentialité: %v", err)
+		http.Error(w, "Erreur lors de la mise à jour des paramètres de confidentialité", http.StatusInternalServerError)
+		return
+	}
+
+	// La liste des utilisateurs en ligne reflète immédiatement les nouveaux paramètres
+	broadcastOnlineUsers()
+
+	// Retourner les paramètres enregistrés
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// ChangePasswordHandler change le mot de passe après vérification du mot de passe actuel.
+// Les autres sessions de l'utilisateur sont fermées.
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPut {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer la session depuis le contexte
+	session, ok := middleware.GetSession(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	// Décoder la requête
+	var req database.PasswordChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.NewPassword) < minPasswordLength {
+		http.Error(w, "Le nouveau mot de passe doit contenir au moins 8 caractères", http.StatusBadRequest)
+		return
+	}
+
+	user, err := database.GetUserByID(session.UserID)
+	if err != nil {
+		http.Error(w, "Utilisateur non trouvé", http.StatusNotFound)
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)) != nil {
+		http.Error(w, "Mot de passe actuel incorrect", http.StatusForbidden)
+		return
+	}
+
+	if err := database.ChangePassword(session.UserID, req.NewPassword, session.ID); err != nil {
+		log.Printf("Erreur lors du changement de mot de passe: %v", err)
+		http.Error(w, "Erreur lors du changement de mot de passe", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Mot de passe changé pour l'utilisateur ID=%d", session.UserID)
+	disconnectRevokedSessions(session.UserID, session.ID)
+	recordAudit(r, auditTarget(database.AuditSessionsRevoked, database.AuditTargetUser, session.UserID,
+		map[string]interface{}{"reason": "password_change", "keptSession": true}))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RequestEmailChangeHandler demande un changement d'adresse email. La nouvelle adresse
+// n'est appliquée qu'après un clic sur le lien de vérification qui lui est envoyé.
+func RequestEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPut {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	// Décoder la requête
+	var req database.EmailChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	address, err := netmail.ParseAddress(strings.TrimSpace(req.Email))
+	if err != nil || address.Name != "" {
+		http.Error(w, "Adresse email invalide", http.StatusBadRequest)
+		return
+	}
+
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "Utilisateur non trouvé", http.StatusNotFound)
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)) != nil {
+		http.Error(w, "Mot de passe incorrect", http.StatusForbidden)
+		return
+	}
+	if strings.EqualFold(address.Address, user.Email) {
+		http.Error(w, "La nouvelle adresse est identique à l'actuelle", http.StatusBadRequest)
+		return
+	}
+
+	token, err := database.CreateEmailChange(userID, address.Address)
+	if err == database.ErrEmailTaken {
+		http.Error(w, "Email déjà utilisé", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors de la demande de changement d'email: %v", err)
+		http.Error(w, "Erreur lors de la demande de changement d'email", http.StatusInternalServerError)
+		return
+	}
+
+	link := config.App.BaseURL + "/api/email/verify?token=" + token
+	body := "Bonjour " + user.Username + ",\n\n" +
+		"Pour confirmer votre nouvelle adresse email, ouvrez le lien suivant :\n" + link + "\n\n" +
+		"Si vous n'êtes pas à l'origine de cette demande, ignorez ce message."
+	if err := mail.Send(address.Address, "Confirmez votre nouvelle adresse email", body); err != nil {
+		log.Printf("Erreur lors de l'envoi de l'email de vérification: %v", err)
+		http.Error(w, "Erreur lors de l'envoi de l'email de vérification", http.StatusInternalServerError)
+		return
+	}
+
+	// Retourner l'adresse en attente de vérification
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"pendingEmail": address.Address,
+	})
+}
+
+// VerifyEmailChangeHandler applique un changement d'email depuis le lien de vérification
+// puis redirige vers l'application
+func VerifyEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := database.ConfirmEmailChange(r.URL.Query().Get("token"))
+	if err != nil {
+		log.Printf("Échec de la vérification d'email: %v", err)
+		http.Redirect(w, r, "/?emailVerified=0", http.StatusSeeOther)
+		return
+	}
+
+	log.Printf("Adresse email vérifiée pour l'utilisateur ID=%d", userID)
+	http.Redirect(w, r, "/?emailVerified=1", http.StatusSeeOther)
+}
+
+// GetPublicProfileHandler retourne le profil public d'un utilisateur
+func GetPublicProfileHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extraire l'ID de l'utilisateur de l'URL (/api/users/{id})
+	userID, err := pathID(r, 3)
+	if err != nil {
+		http.Error(w, "ID d'utilisateur invalide", http.StatusBadRequest)
+		return
+	}
+
+	profile, err := database.GetPublicProfile(userID)
+	if err != nil {
+		http.Error(w, "Utilisateur non trouvé", http.StatusNotFound)
+		return
+	}
+
+	// Retourner le profil
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
@@ -0,0 +1,47 @@
+// fichier: handlers/quotes.go
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"realtimeforum/database"
+	"strings"
+	"unicode/utf8"
+)
+
+// Longueur maximale d'un extrait cité ; une citation sans extrait reprend le début du commentaire
+const maxQuoteExcerptLength = 500
+
+// prepareQuote vérifie la citation d'un nouveau commentaire : le commentaire source doit être visible
+// et l'extrait en être tiré. Seuls l'ID du commentaire et l'extrait fournis par le client sont conservés.
+func prepareQuote(quote *database.CommentQuote) error {
+	source, err := database.GetQuoteSource(quote.CommentID)
+	if err != nil {
+		if !errors.Is(err, database.ErrQuoteSourceNotFound) {
+			log.Printf("Erreur lors de la récupération du commentaire cité: %v", err)
+		}
+		return errors.New("Commentaire cité introuvable")
+	}
+
+	excerpt := strings.TrimSpace(quote.Excerpt)
+	switch {
+	case excerpt == "":
+		excerpt = truncateRunes(strings.TrimSpace(source.Content), maxQuoteExcerptLength)
+	case utf8.RuneCountInString(excerpt) > maxQuoteExcerptLength:
+		return fmt.Errorf("Extrait trop long (%d caractères au plus)", maxQuoteExcerptLength)
+	case !strings.Contains(source.Content, excerpt):
+		return errors.New("L'extrait doit provenir du commentaire cité")
+	}
+
+	*quote = database.CommentQuote{CommentID: source.ID, Excerpt: excerpt}
+	return nil
+}
+
+// truncateRunes tronque un texte à n caractères en signalant la coupure
+func truncateRunes(text string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	return string([]rune(text)[:n-1]) + "…"
+}
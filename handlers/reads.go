@@ -0,0 +1,208 @@
+// fichier: handlers/reads.go
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"realtimeforum/config"
+	"realtimeforum/database"
+	"realtimeforum/middleware"
+	"strconv"
+	"time"
+)
+
+// Cookie identifiant un visiteur non connecté pour dédupliquer ses vues
+const (
+	visitorCookieName   = "visitor_id"
+	visitorCookieLength = 16
+	visitorCookieMaxAge = 365 * 24 * time.Hour
+)
+
+// markReadRequest désigne le dernier commentaire lu (absent : tous les commentaires actuels)
+type markReadRequest struct {
+	LastCommentID *int `json:"lastCommentId"`
+}
+
+// MarkPostReadHandler marque une publication comme lue (POST /api/posts/{id}/read)
+func MarkPostReadHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	postID, err := pathID(r, 3)
+	if err != nil {
+		http.Error(w, "ID de publication invalide", http.StatusBadRequest)
+		return
+	}
+
+	// Le corps est facultatif
+	var request markReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+	if request.LastCommentID != nil && *request.LastCommentID < 0 {
+		http.Error(w, "ID de commentaire invalide", http.StatusBadRequest)
+		return
+	}
+
+	err = database.MarkPostRead(userID, postID, request.LastCommentID)
+	if errors.Is(err, database.ErrReadPostNotFound) {
+		http.Error(w, "Publication non trouvée", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors du marquage de la publication comme lue: %v", err)
+		http.Error(w, "Erreur lors du marquage comme lu", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkCategoryReadHandler marque comme lues les publications d'une catégorie et de ses sous-catégories
+// (POST /api/categories/{id}/read)
+func MarkCategoryReadHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	categoryID, err := pathID(r, 3)
+	if err != nil {
+		http.Error(w, "ID de catégorie invalide", http.StatusBadRequest)
+		return
+	}
+	if _, err := database.GetCategoryByID(categoryID); err != nil {
+		http.Error(w, "Catégorie non trouvée", http.StatusNotFound)
+		return
+	}
+
+	marked, err := database.MarkCategoryRead(userID, categoryID)
+	if err != nil {
+		log.Printf("Erreur lors du marquage de la catégorie comme lue: %v", err)
+		http.Error(w, "Erreur lors du marquage comme lu", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"marked": marked})
+}
+
+// recordPostView compte la vue d'une publication, une seule fois par utilisateur
+// ou, pour un visiteur, par cookie visitor_id (créé au besoin)
+func recordPostView(w http.ResponseWriter, r *http.Request, post *database.Post) {
+	viewer, err := viewerKey(w, r)
+	if err != nil {
+		log.Printf("Erreur lors de l'identification du lecteur: %v", err)
+		return
+	}
+
+	counted, err := database.RecordPostView(post.ID, viewer)
+	if err != nil {
+		log.Printf("Erreur lors de l'enregistrement de la vue: %v", err)
+		return
+	}
+	if counted {
+		post.ViewCount++
+	}
+}
+
+// viewerKey retourne l'identifiant de lecteur de la requête : l'utilisateur authentifié,
+// sinon le cookie visiteur, émis s'il est absent ou invalide
+func viewerKey(w http.ResponseWriter, r *http.Request) (string, error) {
+	if userID, ok := middleware.GetUserID(r); ok {
+		return "user:" + strconv.Itoa(userID), nil
+	}
+
+	if cookie, err := r.Cookie(visitorCookieName); err == nil {
+		if raw, err := hex.DecodeString(cookie.Value); err == nil && len(raw) == visitorCookieLength {
+			return "visitor:" + cookie.Value, nil
+		}
+	}
+
+	raw := make([]byte, visitorCookieLength)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	visitorID := hex.EncodeToString(raw)
+	http.SetCookie(w, &http.Cookie{
+		Name:     visitorCookieName,
+		Value:    visitorID,
+		Expires:  time.Now().Add(visitorCookieMaxAge),
+		HttpOnly: true,
+		Path:     "/",
+		SameSite: config.App.CookieSameSite,
+		Secure:   config.App.CookieSecure,
+	})
+	return "visitor:" + visitorID, nil
+}
+
+// withPostReadStates renseigne le suivi de lecture de l'utilisateur authentifié sur chaque publication :
+// nouvelle si jamais ouverte (hors ses propres publications) et nombre de commentaires non lus
+func withPostReadStates(r *http.Request, posts []*database.Post, hiddenAuthors map[int]bool) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok || len(posts) == 0 {
+		return
+	}
+
+	ids := make([]int, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+	states, err := database.GetPostReadStates(userID, ids, hiddenAuthors)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération du suivi de lecture: %v", err)
+		return
+	}
+
+	for _, post := range posts {
+		state, ok := states[post.ID]
+		if !ok {
+			continue
+		}
+		unread := state.UnreadComments
+		post.UnreadComments = &unread
+		post.New = !state.Read && post.UserID != userID
+	}
+}
+
+// markCommentsRead marque la publication comme lue par l'utilisateur authentifié
+// jusqu'au dernier des commentaires qui lui ont été renvoyés
+func markCommentsRead(r *http.Request, postID int, comments []*database.Comment) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		return
+	}
+
+	lastCommentID := 0
+	for _, comment := range comments {
+		if comment.ID > lastCommentID {
+			lastCommentID = comment.ID
+		}
+	}
+	err := database.MarkPostRead(userID, postID, &lastCommentID)
+	if err != nil && !errors.Is(err, database.ErrReadPostNotFound) {
+		log.Printf("Erreur lors du marquage de la publication comme lue: %v", err)
+	}
+}
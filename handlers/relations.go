@@ -0,0 +1,141 @@
+// fichier: handlers/relations.go
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"realtimeforum/database"
+	"realtimeforum/middleware"
+)
+
+// GetBlocksHandler liste les utilisateurs bloqués par l'utilisateur courant
+func GetBlocksHandler(w http.ResponseWriter, r *http.Request) {
+	listRelations(w, r, database.RelationBlock)
+}
+
+// BlockUserHandler bloque un utilisateur (PUT /api/me/blocks/{id})
+func BlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	updateRelation(w, r, database.RelationBlock, true)
+}
+
+// UnblockUserHandler débloque un utilisateur (DELETE /api/me/blocks/{id})
+func UnblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	updateRelation(w, r, database.RelationBlock, false)
+}
+
+// GetMutesHandler liste les utilisateurs mis en sourdine par l'utilisateur courant
+func GetMutesHandler(w http.ResponseWriter, r *http.Request) {
+	listRelations(w, r, database.RelationMute)
+}
+
+// MuteUserHandler met un utilisateur en sourdine (PUT /api/me/mutes/{id})
+func MuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	updateRelation(w, r, database.RelationMute, true)
+}
+
+// UnmuteUserHandler retire un utilisateur de la sourdine (DELETE /api/me/mutes/{id})
+func UnmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	updateRelation(w, r, database.RelationMute, false)
+}
+
+// listRelations retourne la vue publique des utilisateurs visés par une relation de l'utilisateur courant
+func listRelations(w http.ResponseWriter, r *http.Request, kind string) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	users, err := database.GetRelatedUsers(userID, kind)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération de la liste", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
+// updateRelation établit (add) ou retire une relation envers l'utilisateur désigné par /api/me/{liste}/{id}
+func updateRelation(w http.ResponseWriter, r *http.Request, kind string, add bool) {
+	// Vérifier la méthode
+	if (add && r.Method != http.MethodPut) || (!add && r.Method != http.MethodDelete) {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	targetID, err := pathID(r, 4)
+	if err != nil {
+		http.Error(w, "ID utilisateur invalide", http.StatusBadRequest)
+		return
+	}
+	if targetID == userID {
+		http.Error(w, "Action impossible sur soi-même", http.StatusBadRequest)
+		return
+	}
+	if _, err := database.GetUserByID(targetID); err != nil {
+		http.Error(w, "Utilisateur non trouvé", http.StatusNotFound)
+		return
+	}
+
+	if add {
+		err = database.AddUserRelation(userID, targetID, kind)
+	} else {
+		err = database.RemoveUserRelation(userID, targetID, kind)
+	}
+	if err != nil {
+		log.Printf("Erreur lors de la mise à jour de la relation %s: %v", kind, err)
+		http.Error(w, "Erreur lors de la mise à jour de la liste", http.StatusInternalServerError)
+		return
+	}
+
+	// Un blocage change la présence visible par les deux utilisateurs
+	if kind == database.RelationBlock {
+		broadcastOnlineUsers()
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// filterCommentsByAuthor retire les commentaires dont l'auteur fait partie des auteurs masqués
+func filterCommentsByAuthor(comments []*database.Comment, hidden map[int]bool) []*database.Comment {
+	if len(hidden) == 0 {
+		return comments
+	}
+	visible := make([]*database.Comment, 0, len(comments))
+	for _, comment := range comments {
+		if !hidden[comment.UserID] {
+			visible = append(visible, comment)
+		}
+	}
+	return visible
+}
+
+// hiddenAuthorsFor retourne les auteurs masqués pour l'utilisateur de la requête (aucun pour un visiteur)
+func hiddenAuthorsFor(r *http.Request) map[int]bool {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		return nil
+	}
+	hidden, err := database.GetHiddenAuthors(userID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des auteurs masqués: %v", err)
+		return nil
+	}
+	return hidden
+}
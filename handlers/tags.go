@@ -0,0 +1,232 @@
+// fichier: handlers/tags.go
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"realtimeforum/database"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limites des étiquettes
+const (
+	maxPostTags        = 5
+	maxTagLength       = 30
+	defaultTagsPerPage = 20
+	defaultTagSuggests = 10
+)
+
+// GetTagsHandler retourne les étiquettes les plus utilisées avec leur nombre de publications
+// (GET /api/tags?limit=20)
+func GetTagsHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	limit, _ := parsePagination(r, defaultTagsPerPage)
+	tags, err := database.GetPopularTags(limit)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des étiquettes: %v", err)
+		http.Error(w, "Erreur lors de la récupération des étiquettes", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+// SuggestTagsHandler complète le début d'une étiquette (GET /api/tags/suggest?q=go)
+func SuggestTagsHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Un préfixe vide ou invalide ne propose rien plutôt que d'échouer pendant la saisie
+	tags := make([]*database.Tag, 0)
+	if prefix, err := normalizeTag(r.URL.Query().Get("q")); err == nil {
+		limit, _ := parsePagination(r, defaultTagSuggests)
+		tags, err = database.SuggestTags(prefix, limit)
+		if err != nil {
+			log.Printf("Erreur lors de la recherche d'étiquettes: %v", err)
+			http.Error(w, "Erreur lors de la recherche d'étiquettes", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tags)
+}
+
+// CreateTagHandler crée une étiquette officielle ou rend officielle une étiquette existante
+// (POST /api/admin/tags)
+func CreateTagHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+	name, err := normalizeTag(request.Name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tagID, err := database.CurateTag(name)
+	if err != nil {
+		log.Printf("Erreur lors de la création de l'étiquette: %v", err)
+		http.Error(w, "Erreur lors de la création de l'étiquette", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithTag(w, tagID, http.StatusCreated)
+}
+
+// UpdateTagHandler accorde ou retire le statut officiel d'une étiquette (PUT /api/admin/tags/{id})
+func UpdateTagHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPut {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tagID, err := pathID(r, 4)
+	if err != nil {
+		http.Error(w, "ID d'étiquette invalide", http.StatusBadRequest)
+		return
+	}
+
+	var request struct {
+		Curated *bool `json:"curated"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Curated == nil {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	tag, err := database.GetTagByID(tagID)
+	if err != nil {
+		http.Error(w, "Étiquette non trouvée", http.StatusNotFound)
+		return
+	}
+
+	if *request.Curated {
+		_, err = database.CurateTag(tag.Name)
+	} else {
+		err = database.UncurateTag(tagID)
+	}
+	if err != nil {
+		log.Printf("Erreur lors de la modification de l'étiquette: %v", err)
+		http.Error(w, "Erreur lors de la modification de l'étiquette", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithTag(w, tagID, http.StatusOK)
+}
+
+// DeleteTagHandler supprime une étiquette et la retire de toutes les publications
+// (DELETE /api/admin/tags/{id})
+func DeleteTagHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tagID, err := pathID(r, 4)
+	if err != nil {
+		http.Error(w, "ID d'étiquette invalide", http.StatusBadRequest)
+		return
+	}
+
+	tag, err := database.GetTagByID(tagID)
+	if err != nil {
+		http.Error(w, "Étiquette non trouvée", http.StatusNotFound)
+		return
+	}
+	if err := database.DeleteTag(tagID); err != nil {
+		http.Error(w, "Étiquette non trouvée", http.StatusNotFound)
+		return
+	}
+
+	log.Printf("Étiquette ID=%d (%s) supprimée", tagID, tag.Name)
+	recordAudit(r, auditTarget(database.AuditTagDeleted, database.AuditTargetTag, tagID,
+		map[string]interface{}{"name": tag.Name, "posts": tag.PostCount}))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// normalizeTag met une étiquette sous sa forme canonique : minuscules, sans « # » initial,
+// espaces, tirets et soulignés réduits à un seul tiret. Seuls les lettres, chiffres,
+// « . », « + » et « # » sont conservés (pour « node.js », « c++ » ou « c# »).
+func normalizeTag(raw string) (string, error) {
+	tag := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(raw)), "#")
+
+	var builder strings.Builder
+	separator := false
+	for _, r := range tag {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '+' || r == '#':
+			if separator && builder.Len() > 0 {
+				builder.WriteByte('-')
+			}
+			separator = false
+			builder.WriteRune(r)
+		case unicode.IsSpace(r) || r == '-' || r == '_':
+			separator = true
+		default:
+			return "", fmt.Errorf("étiquette invalide: %q", raw)
+		}
+	}
+
+	normalized := builder.String()
+	if normalized == "" || utf8.RuneCountInString(normalized) > maxTagLength {
+		return "", fmt.Errorf("étiquette invalide: %q", raw)
+	}
+	return normalized, nil
+}
+
+// normalizeTags normalise une liste d'étiquettes et retire les doublons en conservant l'ordre
+func normalizeTags(raw []string) ([]string, error) {
+	seen := make(map[string]bool, len(raw))
+	tags := make([]string, 0, len(raw))
+	for _, value := range raw {
+		tag, err := normalizeTag(value)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+// respondWithTag relit une étiquette après modification et la retourne
+func respondWithTag(w http.ResponseWriter, tagID int, status int) {
+	tag, err := database.GetTagByID(tagID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération de l'étiquette", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(tag)
+}
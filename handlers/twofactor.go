@@ -0,0 +1,376 @@
+// fichier: handlers/twofactor.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"realtimeforum/config"
+	"realtimeforum/database"
+	"realtimeforum/middleware"
+	"realtimeforum/totp"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// verifySecondFactor vérifie un code TOTP ou, à défaut, un code de récupération
+func verifySecondFactor(userID int, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		return database.VerifyTOTPCode(userID, code)
+	}
+	if recoveryCode != "" {
+		return database.UseRecoveryCode(userID, recoveryCode)
+	}
+	return false, nil
+}
+
+// TwoFactorStatusHandler retourne l'état de la 2FA de l'utilisateur courant
+func TwoFactorStatusHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	_, enabled, err := database.GetTOTPSecret(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération de l'état de la 2FA", http.StatusInternalServerError)
+		return
+	}
+
+	remaining := 0
+	if enabled {
+		remaining, err = database.CountRemainingRecoveryCodes(userID)
+		if err != nil {
+			http.Error(w, "Erreur lors de la récupération des codes de récupération", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Retourner l'état
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":                enabled,
+		"remainingRecoveryCodes": remaining,
+	})
+}
+
+// TwoFactorSetupHandler génère un nouveau secret TOTP en attente de confirmation
+func TwoFactorSetupHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "Utilisateur non trouvé", http.StatusNotFound)
+		return
+	}
+	if user.TwoFactorEnabled {
+		http.Error(w, "Authentification à deux facteurs déjà activée", http.StatusConflict)
+		return
+	}
+
+	// Générer et enregistrer le secret
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.Printf("Erreur lors de la génération du secret TOTP: %v", err)
+		http.Error(w, "Erreur lors de la génération du secret", http.StatusInternalServerError)
+		return
+	}
+	err = database.SetPendingTOTPSecret(userID, secret)
+	if errors.Is(err, database.ErrTOTPAlreadyEnabled) {
+		http.Error(w, "Authentification à deux facteurs déjà activée", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors de l'enregistrement du secret TOTP: %v", err)
+		http.Error(w, "Erreur lors de l'enregistrement du secret", http.StatusInternalServerError)
+		return
+	}
+
+	// Retourner le secret et l'URI d'enrôlement
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":     secret,
+		"otpauthUri": totp.URI(config.App.TwoFactorIssuer, user.Username, secret),
+	})
+}
+
+// TwoFactorConfirmHandler active la 2FA après vérification d'un premier code
+// et retourne les codes de récupération (affichés une seule fois)
+func TwoFactorConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	// Décoder le corps de la requête
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	secret, enabled, err := database.GetTOTPSecret(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération de l'état de la 2FA", http.StatusInternalServerError)
+		return
+	}
+	if enabled {
+		http.Error(w, "Authentification à deux facteurs déjà activée", http.StatusConflict)
+		return
+	}
+	if secret == "" {
+		http.Error(w, "Aucun enrôlement en cours", http.StatusBadRequest)
+		return
+	}
+
+	// Vérifier le code
+	valid, err := database.VerifyTOTPCode(userID, req.Code)
+	if err != nil {
+		http.Error(w, "Erreur lors de la vérification du code", http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		http.Error(w, "Code invalide", http.StatusUnauthorized)
+		return
+	}
+
+	// Activer la 2FA et générer les codes de récupération
+	codes, err := database.EnableTOTP(userID)
+	if err != nil {
+		log.Printf("Erreur lors de l'activation de la 2FA: %v", err)
+		http.Error(w, "Erreur lors de l'activation de la 2FA", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("2FA activée pour l'utilisateur ID=%d", userID)
+	recordAudit(r, auditTarget(database.AuditTwoFactorEnabled, database.AuditTargetUser, userID, nil))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":       true,
+		"recoveryCodes": codes,
+	})
+}
+
+// TwoFactorDisableHandler désactive la 2FA après vérification du mot de passe et d'un second facteur
+func TwoFactorDisableHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	// Décoder le corps de la requête
+	var req struct {
+		Password     string `json:"password"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	user, err := database.GetUserByID(userID)
+	if err != nil {
+		http.Error(w, "Utilisateur non trouvé", http.StatusNotFound)
+		return
+	}
+	if !user.TwoFactorEnabled {
+		http.Error(w, "Authentification à deux facteurs non activée", http.StatusBadRequest)
+		return
+	}
+
+	// Vérifier le mot de passe
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)) != nil {
+		http.Error(w, "Mot de passe incorrect", http.StatusUnauthorized)
+		return
+	}
+
+	// Vérifier le second facteur
+	valid, err := verifySecondFactor(userID, req.Code, req.RecoveryCode)
+	if err != nil {
+		http.Error(w, "Erreur lors de la vérification du code", http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		http.Error(w, "Code invalide", http.StatusUnauthorized)
+		return
+	}
+
+	if err := database.DisableTOTP(userID); err != nil {
+		http.Error(w, "Erreur lors de la désactivation de la 2FA", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("2FA désactivée pour l'utilisateur ID=%d", userID)
+	recordAudit(r, auditTarget(database.AuditTwoFactorDisabled, database.AuditTargetUser, userID, nil))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"enabled": false})
+}
+
+// RegenerateRecoveryCodesHandler remplace les codes de récupération après vérification d'un code TOTP
+func RegenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	// Décoder le corps de la requête
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	_, enabled, err := database.GetTOTPSecret(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération de l'état de la 2FA", http.StatusInternalServerError)
+		return
+	}
+	if !enabled {
+		http.Error(w, "Authentification à deux facteurs non activée", http.StatusBadRequest)
+		return
+	}
+
+	valid, err := database.VerifyTOTPCode(userID, req.Code)
+	if err != nil {
+		http.Error(w, "Erreur lors de la vérification du code", http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		http.Error(w, "Code invalide", http.StatusUnauthorized)
+		return
+	}
+
+	codes, err := database.RegenerateRecoveryCodes(userID)
+	if err != nil {
+		http.Error(w, "Erreur lors de la génération des codes de récupération", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"recoveryCodes": codes})
+}
+
+// LoginTwoFactorHandler gère la seconde étape de connexion : vérification du code
+// associé à une session partielle, puis création de la session complète
+func LoginTwoFactorHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Décoder le corps de la requête
+	var req database.TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ChallengeToken == "" {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	// Récupérer la session partielle en comptabilisant la tentative
+	partial, err := database.ConsumePartialSessionAttempt(req.ChallengeToken)
+	if err != nil {
+		log.Printf("Session partielle invalide: %v", err)
+		http.Error(w, "Session de connexion expirée, veuillez vous reconnecter", http.StatusUnauthorized)
+		return
+	}
+
+	// Les échecs du second facteur comptent dans la limitation des tentatives du compte
+	ip := middleware.ClientIP(r)
+	accountKey, ipKey := database.AccountThrottleKey(partial.UserID), database.IPThrottleKey(ip)
+	lockedUntil, err := database.GetLoginLockout(accountKey, ipKey)
+	if err != nil {
+		log.Printf("Erreur lors de la vérification du verrouillage: %v", err)
+		http.Error(w, "Erreur lors de la connexion", http.StatusInternalServerError)
+		return
+	}
+	if !lockedUntil.IsZero() {
+		writeLockout(w, lockedUntil)
+		return
+	}
+
+	valid, err := verifySecondFactor(partial.UserID, req.Code, req.RecoveryCode)
+	if err != nil {
+		log.Printf("Erreur lors de la vérification du second facteur: %v", err)
+		http.Error(w, "Erreur lors de la vérification du code", http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		log.Printf("Code de second facteur invalide pour l'utilisateur ID=%d", partial.UserID)
+		userID := partial.UserID
+		lockedUntil, recordErr := database.RecordLoginFailure(accountKey, ipKey, accountKey, &userID, ip, "second facteur invalide")
+		if recordErr != nil {
+			log.Printf("Erreur lors de l'enregistrement de l'échec de connexion: %v", recordErr)
+		}
+		recordAudit(r, auditTarget(database.AuditLoginFailed, database.AuditTargetUser, userID,
+			map[string]interface{}{"reason": "second facteur invalide"}))
+		if !lockedUntil.IsZero() {
+			writeLockout(w, lockedUntil)
+			return
+		}
+		http.Error(w, "Code invalide", http.StatusUnauthorized)
+		return
+	}
+
+	// La session partielle ne peut servir qu'une fois
+	if err := database.DeletePartialSession(partial.ID); err != nil {
+		log.Printf("Erreur lors de la suppression de la session partielle: %v", err)
+	}
+
+	user, err := database.GetUserByID(partial.UserID)
+	if err != nil {
+		http.Error(w, "Utilisateur non trouvé", http.StatusNotFound)
+		return
+	}
+	log.Printf("Second facteur vérifié pour: %s", user.Username)
+
+	completeLogin(w, r, user)
+}
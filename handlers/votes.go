@@ -0,0 +1,134 @@
+// fichier: handlers/votes.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"realtimeforum/database"
+	"realtimeforum/middleware"
+	"time"
+)
+
+// Fenêtres de temps du tri par score (?sort=top&period=...), la semaine par défaut
+var topPeriods = map[string]time.Duration{
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"year":  365 * 24 * time.Hour,
+	"all":   0,
+}
+
+const defaultTopPeriod = "week"
+
+// VotePostHandler enregistre le vote de l'utilisateur sur une publication
+// (PUT /api/posts/{id}/vote avec {"value": 1}, -1, ou 0 pour retirer le vote)
+func VotePostHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPut {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	postID, err := pathID(r, 3)
+	if err != nil {
+		http.Error(w, "ID de publication invalide", http.StatusBadRequest)
+		return
+	}
+
+	var request struct {
+		Value int `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Value < -1 || request.Value > 1 {
+		http.Error(w, "Données invalides", http.StatusBadRequest)
+		return
+	}
+
+	post, err := database.GetPostByID(postID)
+	if err != nil {
+		http.Error(w, "Publication non trouvée", http.StatusNotFound)
+		return
+	}
+	if post.UserID == userID {
+		http.Error(w, "Vous ne pouvez pas voter pour votre propre publication", http.StatusForbidden)
+		return
+	}
+
+	score, err := database.SetPostVote(postID, userID, request.Value)
+	if errors.Is(err, database.ErrVotePostNotFound) {
+		http.Error(w, "Publication non trouvée", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Erreur lors de l'enregistrement du vote: %v", err)
+		http.Error(w, "Erreur lors de l'enregistrement du vote", http.StatusInternalServerError)
+		return
+	}
+
+	// Diffuser le nouveau score sans le vote de l'utilisateur
+	broadcastEvent("post_score_updated", map[string]int{"postId": postID, "score": score})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"postId": postID, "score": score, "myVote": request.Value})
+}
+
+// parsePostSort lit le tri (?sort=new|active|top|hot) et, pour le tri par score,
+// la fenêtre de temps (?period=day|week|month|year|all) dans le filtre des publications
+func parsePostSort(r *http.Request, filter *database.PostFilter) error {
+	query := r.URL.Query()
+	switch sort := query.Get("sort"); sort {
+	case "", database.PostSortNew:
+		filter.Sort = database.PostSortNew
+	case database.PostSortActive, database.PostSortHot:
+		filter.Sort = sort
+	case database.PostSortTop:
+		filter.Sort = sort
+		period := query.Get("period")
+		if period == "" {
+			period = defaultTopPeriod
+		}
+		window, ok := topPeriods[period]
+		if !ok {
+			return errors.New("Période invalide")
+		}
+		if window > 0 {
+			filter.Since = time.Now().Add(-window)
+		}
+		return nil
+	default:
+		return errors.New("Tri invalide")
+	}
+
+	if query.Has("period") {
+		return errors.New("La période ne s'applique qu'au tri par score")
+	}
+	return nil
+}
+
+// withPostVotes renseigne le vote de l'utilisateur authentifié sur chaque publication
+func withPostVotes(r *http.Request, posts []*database.Post) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok || len(posts) == 0 {
+		return
+	}
+
+	ids := make([]int, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+	votes, err := database.GetPostVotes(userID, ids)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des votes: %v", err)
+		return
+	}
+	for _, post := range posts {
+		post.MyVote = votes[post.ID]
+	}
+}
@@ -0,0 +1,227 @@
+// fichier: handlers/webhooks.go
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"realtimeforum/database"
+	"realtimeforum/middleware"
+	"realtimeforum/webhooks"
+	"strings"
+)
+
+// isValidWebhookEvent indique si l'événement fait partie des événements publiés
+func isValidWebhookEvent(event string) bool {
+	for _, valid := range database.WebhookEvents {
+		if event == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// GetWebhooksHandler liste les webhooks configurés
+func GetWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	hooks, err := database.GetWebhooks()
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	// Retourner les webhooks (sans leur secret)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hooks)
+}
+
+// CreateWebhookHandler enregistre un webhook ; son secret n'est retourné qu'à la création
+func CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer l'ID utilisateur depuis le contexte
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	// Décoder la requête
+	var req database.WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Requête invalide", http.StatusBadRequest)
+		return
+	}
+
+	// Valider l'URL
+	req.URL = strings.TrimSpace(req.URL)
+	parsed, err := url.Parse(req.URL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		http.Error(w, "URL invalide (http ou https requis)", http.StatusBadRequest)
+		return
+	}
+
+	// Valider les événements
+	if len(req.Events) == 0 {
+		http.Error(w, "Au moins un événement est requis", http.StatusBadRequest)
+		return
+	}
+	seen := make(map[string]bool)
+	events := make([]string, 0, len(req.Events))
+	for _, event := range req.Events {
+		if !isValidWebhookEvent(event) {
+			http.Error(w, "Événement inconnu: "+event, http.StatusBadRequest)
+			return
+		}
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+	req.Events = events
+
+	webhook, err := database.CreateWebhook(userID, req)
+	if err != nil {
+		log.Printf("Erreur lors de la création du webhook: %v", err)
+		http.Error(w, "Erreur lors de la création du webhook", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("Webhook ID=%d créé par l'utilisateur ID=%d vers %s", webhook.ID, userID, webhook.URL)
+
+	// Retourner le webhook et son secret
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"webhook": webhook,
+		"secret":  webhook.Secret,
+	})
+}
+
+// UpdateWebhookHandler active ou désactive un webhook
+func UpdateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	webhookID, err := pathID(r, 4)
+	if err != nil {
+		http.Error(w, "ID de webhook invalide", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Active *bool `json:"active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Active == nil {
+		http.Error(w, "Requête invalide", http.StatusBadRequest)
+		return
+	}
+
+	if err := database.SetWebhookActive(webhookID, *req.Active); err != nil {
+		http.Error(w, "Webhook non trouvé", http.StatusNotFound)
+		return
+	}
+
+	webhook, err := database.GetWebhookByID(webhookID)
+	if err != nil {
+		http.Error(w, "Webhook non trouvé", http.StatusNotFound)
+		return
+	}
+
+	// Retourner le webhook mis à jour
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// DeleteWebhookHandler supprime un webhook et son journal de livraisons
+func DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	webhookID, err := pathID(r, 4)
+	if err != nil {
+		http.Error(w, "ID de webhook invalide", http.StatusBadRequest)
+		return
+	}
+
+	if err := database.DeleteWebhook(webhookID); err != nil {
+		http.Error(w, "Webhook non trouvé", http.StatusNotFound)
+		return
+	}
+
+	log.Printf("Webhook ID=%d supprimé", webhookID)
+	recordAudit(r, auditTarget(database.AuditWebhookDeleted, database.AuditTargetWebhook, webhookID, nil))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetWebhookDeliveriesHandler récupère le journal des livraisons d'un webhook
+func GetWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodGet {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	webhookID, err := pathID(r, 4)
+	if err != nil {
+		http.Error(w, "ID de webhook invalide", http.StatusBadRequest)
+		return
+	}
+
+	limit, offset := parsePagination(r, 50)
+	deliveries, err := database.GetWebhookDeliveries(webhookID, limit, offset)
+	if err != nil {
+		http.Error(w, "Erreur lors de la récupération des livraisons", http.StatusInternalServerError)
+		return
+	}
+
+	// Retourner le journal
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// RedeliverWebhookHandler remet en file une livraison existante
+func RedeliverWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	deliveryID, err := pathID(r, 4)
+	if err != nil {
+		http.Error(w, "ID de livraison invalide", http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := database.RedeliverWebhookDelivery(deliveryID)
+	if err != nil {
+		http.Error(w, "Livraison non trouvée", http.StatusNotFound)
+		return
+	}
+
+	log.Printf("Livraison ID=%d remise en file (nouvelle livraison ID=%d)", deliveryID, delivery.ID)
+	webhooks.Notify()
+
+	// Retourner la nouvelle livraison
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(delivery)
+}
@@ -7,6 +7,7 @@ import (
 	"realtimeforum/database"
 	"realtimeforum/middleware"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -16,10 +17,8 @@ var (
 	upgrader = websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
-		// Permettre toutes les origines (à adapter en production)
-		CheckOrigin: func(r *http.Request) bool {
-			return true
-		},
+		Subprotocols:    []string{wsSubprotocol},
+		CheckOrigin:     checkWSOrigin,
 	}
 
 	// Clients stocke toutes les connexions WebSocket actives
@@ -30,13 +29,103 @@ var (
 	clientsMutex = sync.RWMutex{}
 )
 
+// Code de fermeture WebSocket envoyé lorsque la session du client a expiré
+const closeSessionExpired = 4001
+
+// Code de fermeture WebSocket envoyé lorsque le compte de l'utilisateur est suspendu ou banni
+const closeAccountSuspended = 4003
+
+// Intervalle de vérification du jeton d'un bot connecté
+const botTokenCheckInterval = time.Minute
+
+// Sous-protocole WebSocket décrivant le format des messages échangés
+const wsSubprotocol = "realtimeforum.v1"
+
+// Taille maximale d'un message WebSocket reçu. Les fichiers joints passent par l'API HTTP :
+// un message ne porte que leurs IDs, mais son texte peut dépasser les 4 Ko d'origine.
+const wsReadLimit = 64 << 10
+
+// checkWSOrigin n'accepte que les origines autorisées. Les clients hors navigateur,
+// qui n'envoient pas d'en-tête Origin, sont acceptés.
+func checkWSOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	return middleware.IsOriginAllowed(r, origin)
+}
+
+// supportsSubprotocol vérifie que le client, s'il demande des sous-protocoles, accepte le nôtre
+func supportsSubprotocol(r *http.Request) bool {
+	requested := websocket.Subprotocols(r)
+	if len(requested) == 0 {
+		return true
+	}
+	for _, protocol := range requested {
+		if protocol == wsSubprotocol {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateWSTicketHandler délivre un ticket à usage unique permettant d'ouvrir une connexion WebSocket
+func CreateWSTicketHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier la méthode
+	if r.Method != http.MethodPost {
+		http.Error(w, "Méthode non autorisée", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Récupérer la session depuis le contexte
+	session, ok := middleware.GetSession(r)
+	if !ok {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return
+	}
+
+	ticket, err := database.CreateWSTicket(session.ID)
+	if err != nil {
+		log.Printf("Erreur lors de la création du ticket WebSocket: %v", err)
+		http.Error(w, "Erreur lors de la création du ticket", http.StatusInternalServerError)
+		return
+	}
+
+	// Retourner le ticket et le sous-protocole attendu
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ticket":      ticket.ID,
+		"expiresAt":   ticket.ExpiresAt,
+		"subprotocol": wsSubprotocol,
+	})
+}
+
 // Client représente un client WebSocket connecté
 type Client struct {
-	UserID   int
-	Conn     *websocket.Conn
-	Send     chan []byte
-	closed   bool       // Indique si le canal est fermé
-	closeMux sync.Mutex // Mutex pour protéger l'accès au champ closed
+	UserID    int
+	SessionID string // Vide pour les bots
+	TokenID   int    // Jeton d'accès des bots
+	IsBot     bool
+	Conn      *websocket.Conn
+	Send      chan []byte
+	closed    bool          // Indique si le canal est fermé
+	closeMux  sync.Mutex    // Mutex pour protéger l'accès au champ closed
+	done      chan struct{} // Fermé lorsque la boucle de lecture se termine
+
+	// Événements auxquels un bot est abonné (les utilisateurs reçoivent tout)
+	subscriptions map[string]bool
+	subsMux       sync.RWMutex
+}
+
+// wants indique si le client doit recevoir un événement du type donné
+func (c *Client) wants(eventType string) bool {
+	if !c.IsBot {
+		return true
+	}
+	c.subsMux.RLock()
+	defer c.subsMux.RUnlock()
+	return c.subscriptions[eventType]
 }
 
 // SafeClose ferme le canal de manière sécurisée
@@ -75,15 +164,32 @@ type Message struct {
 
 // WebSocketHandler gère les connexions WebSocket
 func WebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	// Vérifier l'origine et le sous-protocole avant de consommer le ticket
+	if !checkWSOrigin(r) {
+		log.Printf("Connexion WebSocket refusée depuis l'origine %s", r.Header.Get("Origin"))
+		http.Error(w, "Origine non autorisée", http.StatusForbidden)
+		return
+	}
+	if !supportsSubprotocol(r) {
+		http.Error(w, "Sous-protocole WebSocket non supporté", http.StatusBadRequest)
+		return
+	}
+
 	// Authentifier l'utilisateur
-	userID, ok := middleware.WSAuthMiddleware(w, r)
+	identity, ok := middleware.WSAuthMiddleware(w, r)
 	if !ok {
-		http.Error(w, "Non authentifié", http.StatusUnauthorized)
 		return
 	}
-
-	// Mettre à jour le statut en ligne
-	err := database.UpdateUserOnlineStatus(userID, true)
+	userID := identity.UserID
+	isBot := identity.Token != nil
+
+	// Mettre à jour le statut en ligne (les bots n'ont pas d'autre moment de connexion)
+	var err error
+	if isBot {
+		err = database.RecordUserLogin(userID)
+	} else {
+		err = database.UpdateUserOnlineStatus(userID, true)
+	}
 	if err != nil {
 		log.Printf("Erreur lors de la mise à jour du statut en ligne: %v", err)
 	}
@@ -97,10 +203,18 @@ func WebSocketHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Créer un nouveau client
 	client := &Client{
-		UserID: userID,
-		Conn:   conn,
-		Send:   make(chan []byte, 256),
-		closed: false,
+		UserID:        userID,
+		IsBot:         isBot,
+		Conn:          conn,
+		Send:          make(chan []byte, 256),
+		closed:        false,
+		done:          make(chan struct{}),
+		subscriptions: make(map[string]bool),
+	}
+	if isBot {
+		client.TokenID = identity.Token.ID
+	} else {
+		client.SessionID = identity.Session.ID
 	}
 
 	// Enregistrer le client
@@ -119,6 +233,72 @@ func WebSocketHandler(w http.ResponseWriter, r *http.Request) {
 	// Démarrer les goroutines pour la lecture et l'écriture
 	go client.readPump()
 	go client.writePump()
+	if isBot {
+		go client.watchBotToken()
+	} else {
+		go client.watchSession(identity.Session.ExpiresAt)
+	}
+}
+
+// watchBotToken ferme la connexion d'un bot lorsque son jeton est révoqué ou expire
+func (c *Client) watchBotToken() {
+	ticker := time.NewTicker(botTokenCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			active, err := database.IsAPITokenActive(c.TokenID)
+			if err != nil {
+				log.Printf("Erreur lors de la vérification du jeton du bot: %v", err)
+				continue
+			}
+			if !active {
+				log.Printf("Jeton révoqué, fermeture de la connexion WebSocket du bot ID=%d", c.UserID)
+				c.closeWithReason(closeSessionExpired, "jeton révoqué")
+				return
+			}
+		}
+	}
+}
+
+// watchSession ferme la connexion lorsque la session associée expire.
+// À chaque échéance, la session est relue car elle a pu être renouvelée entre-temps.
+func (c *Client) watchSession(expiresAt time.Time) {
+	timer := time.NewTimer(time.Until(expiresAt))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-timer.C:
+			session, err := database.GetSessionByID(c.SessionID)
+			if err == nil {
+				timer.Reset(time.Until(session.ExpiresAt))
+				continue
+			}
+			if err != database.ErrSessionNotFound && err != database.ErrSessionExpired {
+				// Erreur de base de données : réessayer plus tard plutôt que de déconnecter
+				log.Printf("Erreur lors de la vérification de la session WebSocket: %v", err)
+				timer.Reset(time.Minute)
+				continue
+			}
+
+			log.Printf("Session expirée, fermeture de la connexion WebSocket de l'utilisateur ID=%d", c.UserID)
+			c.closeWithReason(closeSessionExpired, "session expirée")
+			return
+		}
+	}
+}
+
+// closeWithReason envoie une trame de fermeture au client puis ferme la connexion
+func (c *Client) closeWithReason(code int, reason string) {
+	deadline := time.Now().Add(time.Second)
+	c.Conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+	c.Conn.Close()
 }
 
 // readPump pompe les messages du client WebSocket vers le hub
@@ -126,14 +306,22 @@ func (c *Client) readPump() {
 	defer func() {
 		// Fermer la connexion quand on sort de la fonction
 		c.Conn.Close()
+		close(c.done)
 
 		// Supprimer le client de la map des clients
 		clientsMutex.Lock()
+		replaced := true
 		if storedClient, exists := clients[c.UserID]; exists && storedClient == c {
 			delete(clients, c.UserID)
+			replaced = false
 		}
 		clientsMutex.Unlock()
 
+		// Retirer les commandes du bot, sauf s'il s'est reconnecté entre-temps
+		if c.IsBot && !replaced {
+			unregisterBotCommands(c.UserID)
+		}
+
 		// Mettre à jour le statut en ligne
 		err := database.UpdateUserOnlineStatus(c.UserID, false)
 		if err != nil {
@@ -148,7 +336,7 @@ func (c *Client) readPump() {
 	}()
 
 	// Configurer le WebSocket
-	c.Conn.SetReadLimit(4096) // 4KB max par message
+	c.Conn.SetReadLimit(wsReadLimit)
 
 	// Lire les messages
 	for {
@@ -160,8 +348,24 @@ func (c *Client) readPump() {
 			break
 		}
 
+		// Toute activité sur la connexion prolonge la session
+		if !c.IsBot {
+			c.renewSession()
+		}
+
 		// Traiter le message
-		processMessage(c.UserID, message)
+		processMessage(c, message)
+	}
+}
+
+// renewSession prolonge la session associée au client suite à une activité
+func (c *Client) renewSession() {
+	session, err := database.GetSessionByID(c.SessionID)
+	if err != nil {
+		return
+	}
+	if _, _, err := database.RenewSession(session); err != nil {
+		log.Printf("Erreur lors du renouvellement de la session WebSocket: %v", err)
 	}
 }
 
@@ -188,7 +392,9 @@ func (c *Client) writePump() {
 }
 
 // processMessage traite un message reçu d'un client
-func processMessage(senderID int, rawMessage []byte) {
+func processMessage(c *Client, rawMessage []byte) {
+	senderID := c.UserID
+
 	// Décoder le message
 	var message Message
 	err := json.Unmarshal(rawMessage, &message)
@@ -197,28 +403,70 @@ func processMessage(senderID int, rawMessage []byte) {
 		return
 	}
 
+	// Les sanctions sont vérifiées à chaque message : une suspension prononcée pendant la connexion
+	// la ferme, une mise en lecture seule bloque tout envoi de contenu
+	status, err := database.GetActiveSanctions(senderID)
+	if err != nil {
+		log.Printf("Erreur lors de la vérification des sanctions: %v", err)
+		return
+	}
+	if status.Suspension != nil {
+		c.closeWithReason(closeAccountSuspended, "compte suspendu")
+		return
+	}
+	if status.Mute != nil && publishesContent(message.Type) {
+		log.Printf("Message %s refusé: l'utilisateur ID=%d est en lecture seule", message.Type, senderID)
+		if message.Type == "private_message" {
+			sendMessageError(senderID, middleware.MuteMessage(status.Mute))
+		}
+		return
+	}
+
 	// Traiter en fonction du type de message
 	switch message.Type {
 	case "connection_test":
 		// Ignorer les messages de test de connexion
 		log.Printf("Message de test reçu de l'utilisateur ID=%d", senderID)
 	case "private_message":
+		// Les commandes slash sont transmises au bot qui les a enregistrées au lieu d'être envoyées
+		if !c.IsBot && dispatchCommand(senderID, message.Payload) {
+			return
+		}
 		// Traiter l'envoi d'un message privé
 		handlePrivateMessage(senderID, message.Payload)
 	case "typing_indicator":
 		// Traiter l'indicateur de frappe
 		handleTypingIndicator(senderID, message.Payload)
 	case "post_created":
-		// Diffuser une nouvelle publication à tous les clients
-		broadcastToAll(rawMessage)
+		// Diffuser une nouvelle publication à tous les clients (sauf ceux qui masquent son auteur)
+		broadcastFromAuthor(senderID, message.Type, rawMessage)
 	case "comment_created":
-		// Diffuser un nouveau commentaire à tous les clients
-		broadcastToAll(rawMessage)
+		// Diffuser un nouveau commentaire à tous les clients (sauf ceux qui masquent son auteur)
+		broadcastFromAuthor(senderID, message.Type, rawMessage)
+	case "subscribe":
+		// Abonnement d'un bot à des types d'événements
+		handleBotSubscribe(c, message.Payload)
+	case "register_commands":
+		// Enregistrement des commandes slash d'un bot
+		handleRegisterCommands(c, message.Payload)
+	case "command_response":
+		// Réponse d'un bot à une invocation de commande
+		handleCommandResponse(c, message.Payload)
 	default:
 		log.Printf("Type de message inconnu: %s", message.Type)
 	}
 }
 
+// publishesContent indique si un type de message WebSocket produit du contenu visible par d'autres,
+// interdit à un utilisateur en lecture seule
+func publishesContent(messageType string) bool {
+	switch messageType {
+	case "private_message", "typing_indicator", "post_created", "comment_created", "command_response":
+		return true
+	}
+	return false
+}
+
 // handlePrivateMessage traite l'envoi d'un message privé
 func handlePrivateMessage(senderID int, payload interface{}) {
 	// Convertir le payload en message privé
@@ -238,8 +486,24 @@ func handlePrivateMessage(senderID int, payload interface{}) {
 	// Définir l'ID de l'expéditeur
 	privateMessage.SenderID = senderID
 
+	// Refuser les messages entre deux utilisateurs dont l'un a bloqué l'autre
+	blocked, err := database.IsBlockedBetween(senderID, privateMessage.ReceiverID)
+	if err != nil {
+		log.Printf("Erreur lors de la vérification des blocages: %v", err)
+		return
+	}
+	if blocked {
+		sendMessageError(senderID, errBlockedRecipient)
+		return
+	}
+
 	// Sauvegarder le message dans la base de données
 	_, err = database.CreatePrivateMessage(&privateMessage)
+	if isAttachmentError(err) {
+		// Prévenir l'expéditeur que ses pièces jointes ont été refusées
+		sendMessageError(senderID, err.Error())
+		return
+	}
 	if err != nil {
 		log.Printf("Erreur lors de l'enregistrement du message: %v", err)
 		return
@@ -265,10 +529,15 @@ func handlePrivateMessage(senderID int, payload interface{}) {
 	}
 
 	// Envoyer le message au destinataire
-	sendToUser(privateMessage.ReceiverID, messageJSON)
+	sendToUser(privateMessage.ReceiverID, wsMessage.Type, messageJSON)
 
 	// Envoyer une confirmation à l'expéditeur (pour s'assurer que le message a bien été enregistré)
-	sendToUser(senderID, messageJSON)
+	sendToUser(senderID, wsMessage.Type, messageJSON)
+}
+
+// sendMessageError signale à l'expéditeur que son message privé a été refusé
+func sendMessageError(userID int, reason string) {
+	sendEventToUser(userID, "message_error", map[string]string{"error": reason})
 }
 
 // handleTypingIndicator traite un indicateur de frappe
@@ -290,6 +559,11 @@ func handleTypingIndicator(userID int, payload interface{}) {
 		return
 	}
 
+	// Aucun indicateur de frappe entre deux utilisateurs dont l'un a bloqué l'autre
+	if blocked, err := database.IsBlockedBetween(userID, typingData.TargetUserID); err != nil || blocked {
+		return
+	}
+
 	// Mettre à jour le statut de frappe
 	err = database.UpdateTypingStatus(userID, typingData.TargetUserID, typingData.IsTyping)
 	if err != nil {
@@ -317,16 +591,17 @@ func handleTypingIndicator(userID int, payload interface{}) {
 	}
 
 	// Envoyer le message au destinataire
-	sendToUser(typingData.TargetUserID, messageJSON)
+	sendToUser(typingData.TargetUserID, wsMessage.Type, messageJSON)
 }
 
-// sendToUser envoie un message à un utilisateur spécifique
-func sendToUser(userID int, message []byte) {
+// sendToUser envoie un message à un utilisateur spécifique.
+// Un bot ne le reçoit que s'il est abonné au type d'événement.
+func sendToUser(userID int, eventType string, message []byte) {
 	clientsMutex.RLock()
 	client, ok := clients[userID]
 	clientsMutex.RUnlock()
 
-	if ok {
+	if ok && client.wants(eventType) {
 		success := client.SafeSend(message)
 		if !success {
 			// Si l'envoi échoue, supprimer le client
@@ -337,17 +612,109 @@ func sendToUser(userID int, message []byte) {
 	}
 }
 
-// broadcastToAll envoie un message à tous les clients connectés
-func broadcastToAll(message []byte) {
+// sendEventToUser sérialise un événement et l'envoie à un utilisateur précis
+func sendEventToUser(userID int, eventType string, payload interface{}) {
+	messageJSON, err := json.Marshal(Message{Type: eventType, Payload: payload})
+	if err != nil {
+		log.Printf("Erreur lors de la sérialisation du message: %v", err)
+		return
+	}
+	sendToUser(userID, eventType, messageJSON)
+}
+
+// notifyModerators envoie un événement de modération aux modérateurs et administrateurs connectés
+func notifyModerators(eventType string, payload interface{}) {
+	staff, err := database.GetStaffIDs()
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des modérateurs: %v", err)
+		return
+	}
+
+	messageJSON, err := json.Marshal(Message{Type: eventType, Payload: payload})
+	if err != nil {
+		log.Printf("Erreur lors de la sérialisation du message: %v", err)
+		return
+	}
+
+	clientsMutex.RLock()
+	defer clientsMutex.RUnlock()
+
+	for userID, client := range clients {
+		if staff[userID] && client.wants(eventType) {
+			client.SafeSend(messageJSON)
+		}
+	}
+}
+
+// disconnectUser ferme la connexion WebSocket d'un utilisateur, s'il est connecté.
+// Le nettoyage (statut hors ligne, diffusion de la présence) est assuré par readPump.
+func disconnectUser(userID int, code int, reason string) {
+	clientsMutex.RLock()
+	client, ok := clients[userID]
+	clientsMutex.RUnlock()
+
+	if ok {
+		log.Printf("Fermeture de la connexion WebSocket de l'utilisateur ID=%d: %s", userID, reason)
+		client.closeWithReason(code, reason)
+	}
+}
+
+// disconnectRevokedSessions ferme la connexion WebSocket d'un utilisateur ouverte avec une autre session
+// que keepSessionID, après la révocation de ses autres sessions (changement de mot de passe)
+func disconnectRevokedSessions(userID int, keepSessionID string) {
+	clientsMutex.RLock()
+	client, ok := clients[userID]
+	clientsMutex.RUnlock()
+
+	if ok && !client.IsBot && client.SessionID != keepSessionID {
+		log.Printf("Session révoquée, fermeture de la connexion WebSocket de l'utilisateur ID=%d", userID)
+		client.closeWithReason(closeSessionExpired, "session révoquée")
+	}
+}
+
+// broadcastToAll envoie un message à tous les clients connectés (et aux bots abonnés)
+func broadcastToAll(eventType string, message []byte) {
 	clientsMutex.RLock()
 	defer clientsMutex.RUnlock()
 
 	for _, client := range clients {
-		client.SafeSend(message)
+		if client.wants(eventType) {
+			client.SafeSend(message)
+		}
 	}
 }
 
-// broadcastOnlineUsers diffuse la liste des utilisateurs en ligne à tous les clients
+// broadcastEvent sérialise un événement et l'envoie à tous les clients connectés
+func broadcastEvent(eventType string, payload interface{}) {
+	messageJSON, err := json.Marshal(Message{Type: eventType, Payload: payload})
+	if err != nil {
+		log.Printf("Erreur lors de la sérialisation du message: %v", err)
+		return
+	}
+	broadcastToAll(eventType, messageJSON)
+}
+
+// broadcastFromAuthor diffuse un événement produit par un utilisateur à tous les clients,
+// sauf à ceux qui ont mis cet utilisateur en sourdine ou l'ont bloqué
+func broadcastFromAuthor(authorID int, eventType string, message []byte) {
+	hiding, err := database.GetUsersHiding(authorID)
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des utilisateurs masquant l'auteur: %v", err)
+		hiding = nil
+	}
+
+	clientsMutex.RLock()
+	defer clientsMutex.RUnlock()
+
+	for userID, client := range clients {
+		if !hiding[userID] && client.wants(eventType) {
+			client.SafeSend(message)
+		}
+	}
+}
+
+// broadcastOnlineUsers diffuse la liste des utilisateurs en ligne à tous les clients.
+// Deux utilisateurs dont l'un a bloqué l'autre ne se voient pas dans leurs listes respectives.
 func broadcastOnlineUsers() {
 	// Récupérer la liste des utilisateurs en ligne
 	users, err := database.GetOnlineUsers()
@@ -356,18 +723,53 @@ func broadcastOnlineUsers() {
 		return
 	}
 
-	// Créer le message à envoyer
-	wsMessage := Message{
-		Type:    "online_users",
-		Payload: users,
+	blockedPeers, err := database.GetOnlineBlockedPeers()
+	if err != nil {
+		log.Printf("Erreur lors de la récupération des blocages: %v", err)
+		return
 	}
 
-	messageJSON, err := json.Marshal(wsMessage)
+	// Créer le message commun à tous les clients sans blocage
+	messageJSON, err := json.Marshal(Message{Type: "online_users", Payload: users})
 	if err != nil {
 		log.Printf("Erreur lors de la sérialisation du message: %v", err)
 		return
 	}
 
-	// Diffuser à tous les clients
-	broadcastToAll(messageJSON)
+	clientsMutex.RLock()
+	defer clientsMutex.RUnlock()
+
+	for userID, client := range clients {
+		if !client.wants("online_users") {
+			continue
+		}
+
+		peers := blockedPeers[userID]
+		if len(peers) == 0 {
+			client.SafeSend(messageJSON)
+			continue
+		}
+
+		// Liste propre à ce client, sans les utilisateurs avec qui il est en situation de blocage
+		filteredJSON, err := json.Marshal(Message{Type: "online_users", Payload: filterBlockedUsers(users, peers)})
+		if err != nil {
+			log.Printf("Erreur lors de la sérialisation du message: %v", err)
+			continue
+		}
+		client.SafeSend(filteredJSON)
+	}
+}
+
+// filterBlockedUsers retire de la liste les utilisateurs faisant partie de peers
+func filterBlockedUsers(users []*database.PublicUser, peers map[int]bool) []*database.PublicUser {
+	if len(peers) == 0 {
+		return users
+	}
+	visible := make([]*database.PublicUser, 0, len(users))
+	for _, user := range users {
+		if !peers[user.ID] {
+			visible = append(visible, user)
+		}
+	}
+	return visible
 }
@@ -0,0 +1,153 @@
+// fichier: handlers/websocket_test.go
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"realtimeforum/database"
+	"strings"
+	"testing"
+)
+
+// setupTestDB ouvre une base temporaire dotée du schéma complet
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	schema, err := os.ReadFile(filepath.Join("..", "schema.sql"))
+	if err != nil {
+		t.Fatalf("lecture du schéma: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "forum.db"))
+	if err != nil {
+		t.Fatalf("ouverture de la base: %v", err)
+	}
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		db.Close()
+		database.DB = previous
+	})
+
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("application du schéma: %v", err)
+	}
+}
+
+// connectTestClient enregistre un client WebSocket fictif dont les messages restent dans son canal d'envoi
+func connectTestClient(t *testing.T, userID int) *Client {
+	t.Helper()
+
+	client := &Client{UserID: userID, Send: make(chan []byte, 8), done: make(chan struct{})}
+	clientsMutex.Lock()
+	clients[userID] = client
+	clientsMutex.Unlock()
+	t.Cleanup(func() {
+		clientsMutex.Lock()
+		delete(clients, userID)
+		clientsMutex.Unlock()
+	})
+
+	if err := database.UpdateUserOnlineStatus(userID, true); err != nil {
+		t.Fatalf("statut en ligne: %v", err)
+	}
+	return client
+}
+
+func TestBroadcastOnlineUsersHidesPrivateFields(t *testing.T) {
+	setupTestDB(t)
+
+	// Données personnelles et secrets d'alice, qui ne doivent pas être diffusés aux autres
+	alice := database.UserDTO{
+		Username:  "alice",
+		Age:       42,
+		Gender:    "F",
+		FirstName: "Prénomsecret",
+		LastName:  "Nomsecret",
+		Email:     "adresse-secrete@example.com",
+		Password:  "motdepasse-secret",
+	}
+	aliceID, err := database.CreateUser(alice)
+	if err != nil {
+		t.Fatalf("création d'alice: %v", err)
+	}
+	const totpSecret = "JBSWY3DPEHPK3PXPSECRET"
+	if _, err := database.DB.Exec("UPDATE users SET totp_secret = ?, totp_enabled = TRUE WHERE id = ?", totpSecret, aliceID); err != nil {
+		t.Fatalf("activation de la 2FA: %v", err)
+	}
+	_, tokenValue, err := database.CreateAPIToken(aliceID, database.APITokenRequest{Name: "test", Scopes: []string{database.ScopePostsRead}})
+	if err != nil {
+		t.Fatalf("création du jeton: %v", err)
+	}
+	session, err := database.CreateSession(aliceID)
+	if err != nil {
+		t.Fatalf("création de la session: %v", err)
+	}
+	if err := database.RecordUserLogin(aliceID); err != nil {
+		t.Fatalf("connexion: %v", err)
+	}
+
+	bobID, err := database.CreateUser(database.UserDTO{
+		Username: "bob", Age: 30, Gender: "M", FirstName: "Bob", LastName: "Martin",
+		Email: "bob@example.com", Password: "motdepasse-bob",
+	})
+	if err != nil {
+		t.Fatalf("création de bob: %v", err)
+	}
+
+	connectTestClient(t, aliceID)
+	bob := connectTestClient(t, bobID)
+
+	broadcastOnlineUsers()
+
+	var data []byte
+	select {
+	case data = <-bob.Send:
+	default:
+		t.Fatal("aucune liste des utilisateurs en ligne diffusée")
+	}
+
+	var message struct {
+		Type    string                   `json:"type"`
+		Payload []map[string]interface{} `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &message); err != nil {
+		t.Fatalf("message invalide: %v", err)
+	}
+	if message.Type != "online_users" || len(message.Payload) != 2 {
+		t.Fatalf("message inattendu: %s", data)
+	}
+
+	tests := []struct {
+		name  string
+		field string
+	}{
+		{"email", "email"},
+		{"prénom", "firstName"},
+		{"nom", "lastName"},
+		{"âge", "age"},
+		{"genre", "gender"},
+		{"état de la 2FA", "twoFactorEnabled"},
+		{"paramètres de confidentialité", "privacy"},
+		{"mot de passe", "password"},
+		{"secret TOTP", "totpSecret"},
+		{"jetons", "tokens"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, user := range message.Payload {
+				if _, ok := user[tt.field]; ok {
+					t.Errorf("le champ %q de l'utilisateur %v ne doit pas être diffusé", tt.field, user["username"])
+				}
+			}
+		})
+	}
+
+	for _, secret := range []string{alice.FirstName, alice.LastName, alice.Email, totpSecret, tokenValue, session.ID, session.CSRFToken} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("la valeur %q ne doit pas être diffusée: %s", secret, data)
+		}
+	}
+}
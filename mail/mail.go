@@ -0,0 +1,44 @@
+// fichier: mail/mail.go
+// Package mail envoie les emails transactionnels du forum (vérification d'adresse...).
+package mail
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"realtimeforum/config"
+	"strings"
+)
+
+// Send envoie un email en texte brut. Sans serveur SMTP configuré, le message
+// est écrit dans le journal (mode développement).
+func Send(to, subject, body string) error {
+	if config.App.SMTPAddr == "" {
+		log.Printf("Email (non envoyé, SMTP non configuré) à %s: %s\n%s", to, subject, body)
+		return nil
+	}
+
+	// Refuser les retours à la ligne dans les en-têtes (injection d'en-têtes)
+	if strings.ContainsAny(to+subject, "\r\n") {
+		return fmt.Errorf("en-tête d'email invalide")
+	}
+
+	message := "From: " + config.App.MailFrom + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + body
+
+	var auth smtp.Auth
+	if config.App.SMTPUsername != "" {
+		host, _, err := net.SplitHostPort(config.App.SMTPAddr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", config.App.SMTPUsername, config.App.SMTPPassword, host)
+	}
+
+	return smtp.SendMail(config.App.SMTPAddr, auth, config.App.MailFrom, []string{to}, []byte(message))
+}
@@ -3,11 +3,18 @@ package main
 import (
 	"log"
 	"net/http"
+	"realtimeforum/config"
 	"realtimeforum/database"
+	"realtimeforum/handlers"
 	"realtimeforum/routes"
+	"realtimeforum/storage"
+	"realtimeforum/webhooks"
 )
 
 func main() {
+	// Charger la configuration depuis l'environnement
+	config.Load()
+
 	// Initialiser la base de données
 	err := database.Initialize()
 	if err != nil {
@@ -15,6 +22,27 @@ func main() {
 	}
 	defer database.Close()
 
+	// Promouvoir les administrateurs configurés
+	if err := database.PromoteAdmins(config.App.AdminUsernames); err != nil {
+		log.Printf("Erreur lors de la promotion des administrateurs: %v", err)
+	}
+
+	// Préparer le stockage des fichiers envoyés (avatars)
+	store, err := storage.NewLocalStore(config.App.StorageDir)
+	if err != nil {
+		log.Fatalf("Erreur lors de l'initialisation du stockage: %v", err)
+	}
+	storage.Default = store
+
+	// Nettoyer périodiquement les sessions expirées et les indicateurs de frappe obsolètes
+	database.StartJanitor(config.App.JanitorInterval)
+
+	// Livrer les webhooks en attente
+	webhooks.StartDispatcher(config.App.WebhookPollInterval)
+
+	// Publier les brouillons programmés arrivés à échéance, y compris ceux manqués pendant un arrêt
+	handlers.StartDraftPublisher(config.App.DraftPublishInterval)
+
 	// Configurer les routes
 	router := routes.SetupRoutes()
 
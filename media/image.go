@@ -0,0 +1,148 @@
+// fichier: media/image.go
+// Package media contient le traitement des images envoyées par les utilisateurs.
+// Les images sont toujours décodées puis réencodées : les métadonnées (EXIF, GPS, profils)
+// du fichier d'origine ne sont donc jamais conservées.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif" // Enregistrement des décodeurs acceptés
+	_ "image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// Dimensions maximales acceptées pour une image source, vérifiées avant le décodage complet
+const (
+	MaxImageSide   = 8192
+	MaxImagePixels = 40_000_000
+)
+
+// ErrUnsupportedFormat est retournée pour un fichier qui n'est pas une image acceptée
+var ErrUnsupportedFormat = errors.New("format d'image non supporté (PNG, JPEG ou GIF attendu)")
+
+// ErrImageTooLarge est retournée lorsque les dimensions de l'image dépassent les limites
+var ErrImageTooLarge = errors.New("dimensions de l'image trop grandes")
+
+// imageTypes associe les types MIME détectés aux formats de décodage acceptés
+var imageTypes = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpeg",
+	"image/gif":  "gif",
+}
+
+// SniffImage détermine le type réel du contenu, sans tenir compte de l'extension
+// ni du type annoncé par le client
+func SniffImage(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := imageTypes[contentType]; !ok {
+		return "", ErrUnsupportedFormat
+	}
+	return contentType, nil
+}
+
+// DecodeImage décode une image après avoir vérifié son type et ses dimensions
+func DecodeImage(data []byte) (image.Image, error) {
+	contentType, err := SniffImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lire uniquement l'en-tête pour refuser les images démesurées avant d'allouer leurs pixels
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || format != imageTypes[contentType] {
+		return nil, ErrUnsupportedFormat
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > MaxImageSide || cfg.Height > MaxImageSide ||
+		cfg.Width*cfg.Height > MaxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+	return img, nil
+}
+
+// SquareThumbnail recadre l'image au centre en carré puis la redimensionne en size×size
+func SquareThumbnail(src image.Image, size int) *image.RGBA {
+	bounds := src.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	originX := bounds.Min.X + (bounds.Dx()-side)/2
+	originY := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	return resample(src, image.Rect(originX, originY, originX+side, originY+side), size, size)
+}
+
+// Fit réduit l'image pour qu'elle tienne dans un carré maxSide×maxSide en conservant ses proportions.
+// Une image déjà assez petite est recopiée sans agrandissement.
+func Fit(src image.Image, maxSide int) *image.RGBA {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > maxSide || height > maxSide {
+		if width >= height {
+			width, height = maxSide, max(1, height*maxSide/width)
+		} else {
+			width, height = max(1, width*maxSide/height), maxSide
+		}
+	}
+
+	return resample(src, bounds, width, height)
+}
+
+// resample redimensionne la zone rect de l'image source en width×height.
+// Chaque pixel de destination est la moyenne des pixels source qu'il recouvre (filtre boîte),
+// ce qui donne un résultat correct en réduction comme en agrandissement.
+func resample(src image.Image, rect image.Rectangle, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := boxRange(y, rect.Dy(), height)
+		for x := 0; x < width; x++ {
+			x0, x1 := boxRange(x, rect.Dx(), width)
+
+			// Moyenne en couleurs prémultipliées pour ne pas assombrir les bords transparents
+			var r, g, b, a, n uint64
+			for sy := rect.Min.Y + y0; sy < rect.Min.Y+y1; sy++ {
+				for sx := rect.Min.X + x0; sx < rect.Min.X+x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset+0] = uint8(r / n >> 8)
+			dst.Pix[offset+1] = uint8(g / n >> 8)
+			dst.Pix[offset+2] = uint8(b / n >> 8)
+			dst.Pix[offset+3] = uint8(a / n >> 8)
+		}
+	}
+
+	return dst
+}
+
+// boxRange retourne l'intervalle source [start, end) couvert par le pixel de destination i,
+// en garantissant au moins un pixel source
+func boxRange(i, srcSize, dstSize int) (int, int) {
+	start := i * srcSize / dstSize
+	end := (i + 1) * srcSize / dstSize
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
+
+// EncodePNG encode l'image au format PNG
+func EncodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
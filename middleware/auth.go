@@ -2,33 +2,132 @@ package middleware
 
 import (
 	"context"
+	"crypto/subtle"
 	"log"
+	"net"
 	"net/http"
+	"realtimeforum/config"
 	"realtimeforum/database"
 	"strings"
+	"time"
 )
 
 // Définir un type de clé pour le contexte
 type contextKey string
 
-// Clé pour stocker l'ID utilisateur dans le contexte
-const UserIDKey contextKey = "userID"
+// Clés pour stocker l'ID utilisateur et la session dans le contexte
+const (
+	UserIDKey  contextKey = "userID"
+	SessionKey contextKey = "session"
+	// APITokenKey est présent lorsque la requête est authentifiée par un jeton d'accès personnel
+	APITokenKey contextKey = "apiToken"
+	// requiredScopeKey porte la portée exigée par la route (voir RequireScope)
+	requiredScopeKey contextKey = "requiredScope"
+)
 
-// AuthMiddleware vérifie l'authentification de l'utilisateur
-func AuthMiddleware(next http.Handler) http.Handler {
+// En-tête portant le jeton anti-CSRF sur les requêtes authentifiées par cookie
+const CSRFHeader = "X-CSRF-Token"
+
+// SetSessionCookie définit (ou rafraîchit) le cookie de session à partir de la session donnée.
+// Le cookie n'est pas accessible en JavaScript ; Secure et SameSite dépendent de l'environnement.
+func SetSessionCookie(w http.ResponseWriter, session *database.Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_id",
+		Value:    session.ID,
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Path:     "/",
+		SameSite: config.App.CookieSameSite,
+		Secure:   config.App.CookieSecure,
+	})
+}
+
+// ClearSessionCookie supprime le cookie de session
+func ClearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_id",
+		Value:    "",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Path:     "/",
+		SameSite: config.App.CookieSameSite,
+		Secure:   config.App.CookieSecure,
+	})
+}
+
+// isUnsafeMethod indique si la méthode HTTP modifie l'état et doit donc être protégée contre le CSRF
+func isUnsafeMethod(method string) bool {
+	return method != http.MethodGet && method != http.MethodHead && method != http.MethodOptions
+}
+
+// validCSRFToken compare le jeton fourni dans l'en-tête à celui de la session
+func validCSRFToken(r *http.Request, session *database.Session) bool {
+	token := r.Header.Get(CSRFHeader)
+	if token == "" || session.CSRFToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(session.CSRFToken)) == 1
+}
+
+// withSession ajoute l'ID utilisateur et la session au contexte de la requête
+func withSession(r *http.Request, session *database.Session) *http.Request {
+	ctx := context.WithValue(r.Context(), UserIDKey, session.UserID)
+	ctx = context.WithValue(ctx, SessionKey, session)
+	return r.WithContext(ctx)
+}
+
+// renewSession prolonge la session suite à l'activité de l'utilisateur et rafraîchit le cookie
+func renewSession(w http.ResponseWriter, session *database.Session) {
+	renewed, changed, err := database.RenewSession(session)
+	if err != nil {
+		log.Printf("Erreur lors du renouvellement de la session: %v", err)
+		return
+	}
+	if changed {
+		SetSessionCookie(w, renewed)
+	}
+}
+
+// RequireScope déclare la portée qu'un jeton d'accès personnel doit posséder pour accéder à la route.
+// Doit être placé avant AuthMiddleware. Les jetons d'accès sont refusés sur toute route sans portée
+// déclarée ; les sessions (cookie) ne sont pas concernées.
+func RequireScope(scope string, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Activer CORS pour les requêtes préliminaires OPTIONS
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-
-		// Répondre immédiatement aux requêtes OPTIONS
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
+		ctx := context.WithValue(r.Context(), requiredScopeKey, scope)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticateAPIToken vérifie un jeton d'accès personnel et sa portée pour la route demandée.
+// Retourne le code HTTP d'erreur à renvoyer, ou 0 en cas de succès.
+func authenticateAPIToken(r *http.Request, value string) (*http.Request, int) {
+	token, err := database.GetAPITokenByValue(value)
+	if err != nil {
+		log.Printf("Jeton d'accès invalide: %v", err)
+		return r, http.StatusUnauthorized
+	}
+
+	scope, _ := r.Context().Value(requiredScopeKey).(string)
+	if scope == "" || !token.HasScope(scope) {
+		log.Printf("Portée insuffisante pour le jeton ID=%d (requise: %q)", token.ID, scope)
+		return r, http.StatusForbidden
+	}
+
+	if err := database.TouchAPIToken(token); err != nil {
+		log.Printf("Erreur lors de la mise à jour du jeton d'accès: %v", err)
+	}
 
+	ctx := context.WithValue(r.Context(), UserIDKey, token.UserID)
+	ctx = context.WithValue(ctx, APITokenKey, token)
+	return r.WithContext(ctx), 0
+}
+
+// AuthMiddleware vérifie l'authentification de l'utilisateur.
+// Les utilisateurs suspendus ou bannis, et les adresses IP bannies, sont refusés.
+func AuthMiddleware(next http.Handler) http.Handler {
+	next = rejectSanctioned(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Vérifier d'abord le cookie de session
 		cookie, err := r.Cookie("session_id")
 		if err != nil {
@@ -36,16 +135,22 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			authHeader := r.Header.Get("Authorization")
 			if strings.HasPrefix(authHeader, "Bearer ") {
 				token := strings.TrimPrefix(authHeader, "Bearer ")
-				session, err := database.GetSessionByID(token)
-				if err == nil {
-					log.Printf("Authentification via token Bearer réussie pour l'utilisateur ID=%d", session.UserID)
-					// Ajouter l'ID utilisateur au contexte
-					ctx := context.WithValue(r.Context(), UserIDKey, session.UserID)
-					next.ServeHTTP(w, r.WithContext(ctx))
+
+				// Seuls les jetons d'accès personnels (scripts, intégrations) sont acceptés :
+				// un ID de session n'est valable que dans le cookie HttpOnly, soumis au jeton anti-CSRF
+				if database.IsAPIToken(token) {
+					authed, status := authenticateAPIToken(r, token)
+					switch status {
+					case 0:
+						next.ServeHTTP(w, authed)
+					case http.StatusForbidden:
+						http.Error(w, "Portée du jeton insuffisante", http.StatusForbidden)
+					default:
+						http.Error(w, "Non autorisé", http.StatusUnauthorized)
+					}
 					return
-				} else {
-					log.Printf("Token Bearer invalide: %v", err)
 				}
+				log.Printf("Token Bearer refusé: seuls les jetons d'accès personnels sont acceptés")
 			} else {
 				log.Printf("Aucun cookie ou token Bearer trouvé")
 			}
@@ -62,13 +167,20 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
+		// Les requêtes modifiant l'état authentifiées par cookie doivent porter le jeton anti-CSRF
+		if isUnsafeMethod(r.Method) && !validCSRFToken(r, session) {
+			log.Printf("Jeton CSRF invalide pour l'utilisateur ID=%d", session.UserID)
+			http.Error(w, "Jeton CSRF invalide", http.StatusForbidden)
+			return
+		}
+
 		log.Printf("Authentification via cookie réussie pour l'utilisateur ID=%d", session.UserID)
 
-		// Ajouter l'ID utilisateur au contexte de la requête
-		ctx := context.WithValue(r.Context(), UserIDKey, session.UserID)
+		// Prolonger la session et rafraîchir le cookie
+		renewSession(w, session)
 
 		// Appeler le gestionnaire suivant avec le contexte mis à jour
-		next.ServeHTTP(w, r.WithContext(ctx))
+		next.ServeHTTP(w, withSession(r, session))
 	})
 }
 
@@ -78,32 +190,87 @@ func GetUserID(r *http.Request) (int, bool) {
 	return userID, ok
 }
 
-// OptionalAuthMiddleware permet l'accès que l'utilisateur soit authentifié ou non
-func OptionalAuthMiddleware(next http.Handler) http.Handler {
+// GetSession récupère la session authentifiée à partir du contexte
+func GetSession(r *http.Request) (*database.Session, bool) {
+	session, ok := r.Context().Value(SessionKey).(*database.Session)
+	return session, ok
+}
+
+// GetAPIToken récupère le jeton d'accès personnel ayant authentifié la requête, le cas échéant
+func GetAPIToken(r *http.Request) (*database.APIToken, bool) {
+	token, ok := r.Context().Value(APITokenKey).(*database.APIToken)
+	return token, ok
+}
+
+// RequireRole restreint l'accès aux utilisateurs ayant l'un des rôles donnés.
+// Doit être placé après AuthMiddleware, qui fournit l'ID utilisateur.
+func RequireRole(next http.Handler, roles ...string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Activer CORS pour les requêtes préliminaires OPTIONS
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-
-		// Répondre immédiatement aux requêtes OPTIONS
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
+		userID, ok := GetUserID(r)
+		if !ok {
+			http.Error(w, "Non authentifié", http.StatusUnauthorized)
 			return
 		}
 
+		role, err := database.GetUserRole(userID)
+		if err != nil {
+			log.Printf("Erreur lors de la récupération du rôle: %v", err)
+			http.Error(w, "Accès refusé", http.StatusForbidden)
+			return
+		}
+
+		for _, allowed := range roles {
+			if role == allowed {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		log.Printf("Accès refusé pour l'utilisateur ID=%d (rôle %s)", userID, role)
+		http.Error(w, "Accès refusé", http.StatusForbidden)
+	})
+}
+
+// ClientIP retourne l'adresse IP du client. L'en-tête X-Forwarded-For n'est pris
+// en compte que si l'application est configurée derrière un proxy de confiance.
+func ClientIP(r *http.Request) string {
+	if config.App.TrustProxyHeaders {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// OptionalAuthMiddleware permet l'accès que l'utilisateur soit authentifié ou non
+func OptionalAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Récupérer le cookie de session
 		cookie, err := r.Cookie("session_id")
 		if err == nil && cookie != nil {
 			// Vérifier la session
 			session, err := database.GetSessionByID(cookie.Value)
-			if err == nil && session != nil {
+			// Sans jeton anti-CSRF valide, une requête modifiant l'état est traitée comme anonyme
+			if err == nil && session != nil && (!isUnsafeMethod(r.Method) || validCSRFToken(r, session)) {
+				renewSession(w, session)
+
 				// Ajouter l'ID utilisateur au contexte de la requête
-				ctx := context.WithValue(r.Context(), UserIDKey, session.UserID)
-				r = r.WithContext(ctx)
+				r = withSession(r, session)
 				log.Printf("Utilisateur authentifié (optionnel) ID=%d", session.UserID)
 			}
+		} else if authHeader := r.Header.Get("Authorization"); strings.HasPrefix(authHeader, "Bearer ") {
+			// Un jeton d'accès sans la portée requise, ou tout autre jeton, est traité comme anonyme
+			token := strings.TrimPrefix(authHeader, "Bearer ")
+			if database.IsAPIToken(token) {
+				if authed, status := authenticateAPIToken(r, token); status == 0 {
+					r = authed
+				}
+			}
 		}
 
 		// Appeler le gestionnaire suivant avec le contexte éventuellement mis à jour
@@ -111,35 +278,51 @@ func OptionalAuthMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// WSIdentity décrit l'identité authentifiée d'une connexion WebSocket : une session
+// pour les utilisateurs, un jeton d'accès pour les bots
+type WSIdentity struct {
+	UserID  int
+	Session *database.Session
+	Token   *database.APIToken
+}
+
 // WSAuthMiddleware vérifie l'authentification pour les connexions WebSocket
-func WSAuthMiddleware(w http.ResponseWriter, r *http.Request) (int, bool) {
-	// Activer CORS pour WebSocket
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	w.Header().Set("Access-Control-Allow-Credentials", "true")
-
-	// Vérifier si le token est passé dans l'URL
-	token := r.URL.Query().Get("token")
-	if token != "" {
-		session, err := database.GetSessionByID(token)
-		if err == nil {
-			log.Printf("Authentification WebSocket réussie via token URL pour l'utilisateur ID=%d", session.UserID)
-			return session.UserID, true
-		} else {
-			log.Printf("Token URL invalide: %v", err)
-		}
+// et retourne la session ou le jeton utilisé, afin que la connexion puisse être fermée à son expiration.
+// Les utilisateurs suspendus ou bannis sont refusés. En cas d'échec, la réponse d'erreur est déjà envoyée.
+// L'origine de la requête doit avoir été vérifiée au préalable (voir IsOriginAllowed).
+func WSAuthMiddleware(w http.ResponseWriter, r *http.Request) (*WSIdentity, bool) {
+	identity := authenticateWS(r)
+	if identity == nil {
+		http.Error(w, "Non authentifié", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	_, message, err := CheckAccess(r, identity.UserID)
+	if err != nil {
+		log.Printf("Erreur lors de la vérification des sanctions: %v", err)
+		http.Error(w, "Erreur lors de la vérification des sanctions", http.StatusInternalServerError)
+		return nil, false
 	}
+	if message != "" {
+		http.Error(w, message, http.StatusForbidden)
+		return nil, false
+	}
+
+	return identity, true
+}
 
-	// Vérifier si un token est stocké dans localStorage et passé via la requête
-	localStorageToken := r.URL.Query().Get("ls_token")
-	if localStorageToken != "" {
-		session, err := database.GetSessionByID(localStorageToken)
+// authenticateWS identifie l'auteur d'une demande de connexion WebSocket, ou retourne nil
+func authenticateWS(r *http.Request) *WSIdentity {
+	// Vérifier le ticket à usage unique obtenu via POST /api/ws/ticket.
+	// Les IDs de session ne sont jamais acceptés dans l'URL, où ils finiraient dans les journaux.
+	ticket := r.URL.Query().Get("ticket")
+	if ticket != "" {
+		session, err := database.ConsumeWSTicket(ticket)
 		if err == nil {
-			log.Printf("Authentification WebSocket réussie via localStorage token pour l'utilisateur ID=%d", session.UserID)
-			return session.UserID, true
+			log.Printf("Authentification WebSocket réussie via ticket pour l'utilisateur ID=%d", session.UserID)
+			return &WSIdentity{UserID: session.UserID, Session: session}
 		} else {
-			log.Printf("localStorage token invalide: %v", err)
+			log.Printf("Ticket WebSocket invalide: %v", err)
 		}
 	}
 
@@ -149,7 +332,7 @@ func WSAuthMiddleware(w http.ResponseWriter, r *http.Request) (int, bool) {
 		session, err := database.GetSessionByID(cookie.Value)
 		if err == nil {
 			log.Printf("Authentification WebSocket réussie via cookie pour l'utilisateur ID=%d", session.UserID)
-			return session.UserID, true
+			return &WSIdentity{UserID: session.UserID, Session: session}
 		} else {
 			log.Printf("Cookie de session invalide: %v", err)
 		}
@@ -159,15 +342,20 @@ func WSAuthMiddleware(w http.ResponseWriter, r *http.Request) (int, bool) {
 	authHeader := r.Header.Get("Authorization")
 	if strings.HasPrefix(authHeader, "Bearer ") {
 		token := strings.TrimPrefix(authHeader, "Bearer ")
-		session, err := database.GetSessionByID(token)
-		if err == nil {
-			log.Printf("Authentification WebSocket réussie via Authorization pour l'utilisateur ID=%d", session.UserID)
-			return session.UserID, true
-		} else {
-			log.Printf("Token Bearer invalide: %v", err)
+
+		// Jeton de bot : seule la portée "bot" autorise la connexion WebSocket
+		if database.IsAPIToken(token) {
+			apiToken, err := database.GetAPITokenByValue(token)
+			if err == nil && apiToken.HasScope(database.ScopeBot) {
+				log.Printf("Authentification WebSocket réussie via jeton de bot pour l'utilisateur ID=%d", apiToken.UserID)
+				return &WSIdentity{UserID: apiToken.UserID, Token: apiToken}
+			}
+			log.Printf("Jeton de bot invalide pour la connexion WebSocket")
+			return nil
 		}
+		log.Printf("Token Bearer refusé: seuls les jetons de bot sont acceptés")
 	}
 
 	log.Printf("Authentification WebSocket échouée: aucune méthode d'authentification valide")
-	return 0, false
+	return nil
 }
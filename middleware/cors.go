@@ -0,0 +1,60 @@
+// fichier: middleware/cors.go
+package middleware
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"realtimeforum/config"
+	"strings"
+)
+
+// IsOriginAllowed indique si une origine peut accéder à l'API : la même origine que le serveur
+// est toujours autorisée, les autres doivent figurer dans la liste configurée
+func IsOriginAllowed(r *http.Request, origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	for _, allowed := range config.App.AllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// CORSMiddleware applique la politique cross-origin de l'API.
+// Les en-têtes CORS ne sont renvoyés qu'aux origines autorisées, et les requêtes
+// modifiant l'état provenant d'une autre origine sont rejetées.
+func CORSMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin != "" {
+			if IsOriginAllowed(r, origin) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Accept, Content-Type, Authorization, "+CSRFHeader)
+				w.Header().Set("Access-Control-Expose-Headers", "Retry-After")
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+				w.Header().Add("Vary", "Origin")
+			} else if isUnsafeMethod(r.Method) {
+				log.Printf("Requête %s refusée depuis l'origine non autorisée %s", r.Method, origin)
+				http.Error(w, "Origine non autorisée", http.StatusForbidden)
+				return
+			}
+		}
+
+		// Répondre immédiatement aux requêtes préliminaires
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
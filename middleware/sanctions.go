@@ -0,0 +1,93 @@
+// fichier: middleware/sanctions.go
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"realtimeforum/database"
+)
+
+// Clé de contexte de la mise en lecture seule de l'utilisateur authentifié
+const muteKey contextKey = "mute"
+
+// SanctionMessage décrit une suspension ou un bannissement pour l'utilisateur concerné
+func SanctionMessage(sanction *database.Sanction) string {
+	if sanction.ExpiresAt == nil {
+		return "Compte banni"
+	}
+	return "Compte suspendu jusqu'au " + sanction.ExpiresAt.Local().Format("02/01/2006 15:04")
+}
+
+// MuteMessage décrit une mise en lecture seule pour l'utilisateur concerné
+func MuteMessage(sanction *database.Sanction) string {
+	if sanction.ExpiresAt == nil {
+		return "Compte en lecture seule"
+	}
+	return "Compte en lecture seule jusqu'au " + sanction.ExpiresAt.Local().Format("02/01/2006 15:04")
+}
+
+// CheckAccess vérifie qu'un utilisateur n'est ni suspendu ni banni et que l'adresse IP de la requête
+// n'est pas bannie. Retourne les sanctions en cours et, si l'accès est refusé, le message à opposer.
+func CheckAccess(r *http.Request, userID int) (*database.SanctionStatus, string, error) {
+	ipBan, err := database.GetIPBan(ClientIP(r))
+	if err != nil {
+		return nil, "", err
+	}
+	if ipBan != nil {
+		log.Printf("Accès refusé à l'utilisateur ID=%d depuis une adresse IP bannie", userID)
+		return nil, "Adresse IP bannie", nil
+	}
+
+	status, err := database.GetActiveSanctions(userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if status.Suspension != nil {
+		log.Printf("Accès refusé à l'utilisateur sanctionné ID=%d (%s)", userID, status.Suspension.Kind)
+		return status, SanctionMessage(status.Suspension), nil
+	}
+
+	return status, "", nil
+}
+
+// rejectSanctioned refuse l'accès aux utilisateurs suspendus ou bannis et mémorise
+// une éventuelle mise en lecture seule pour RejectMuted.
+// Doit être placé après l'authentification, qui fournit l'ID utilisateur.
+func rejectSanctioned(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := GetUserID(r)
+		if !ok {
+			http.Error(w, "Non authentifié", http.StatusUnauthorized)
+			return
+		}
+
+		status, message, err := CheckAccess(r, userID)
+		if err != nil {
+			log.Printf("Erreur lors de la vérification des sanctions: %v", err)
+			http.Error(w, "Erreur lors de la vérification des sanctions", http.StatusInternalServerError)
+			return
+		}
+		if message != "" {
+			http.Error(w, message, http.StatusForbidden)
+			return
+		}
+
+		if status.Mute != nil {
+			r = r.WithContext(context.WithValue(r.Context(), muteKey, status.Mute))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RejectMuted refuse la requête si l'utilisateur est en lecture seule.
+// À placer autour des routes qui publient du contenu, à l'intérieur de AuthMiddleware.
+func RejectMuted(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if mute, ok := r.Context().Value(muteKey).(*database.Sanction); ok {
+			http.Error(w, MuteMessage(mute), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"net/http"
+	"realtimeforum/database"
 	"realtimeforum/handlers"
 	"realtimeforum/middleware"
 	"strings"
@@ -11,17 +12,6 @@ import (
 type apiHandler struct{}
 
 func (h apiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Activer CORS
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-
-	// Traiter les requêtes OPTIONS
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
 	// Router les requêtes en fonction du chemin
 	switch {
 	// Routes d'authentification
@@ -29,109 +19,302 @@ func (h apiHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		handlers.RegisterHandler(w, r)
 	case r.URL.Path == "/api/login":
 		handlers.LoginHandler(w, r)
+	case r.URL.Path == "/api/login/2fa":
+		handlers.LoginTwoFactorHandler(w, r)
 	case r.URL.Path == "/api/logout":
 		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.LogoutHandler))
 		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/csrf-token" && r.Method == http.MethodGet:
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.GetCSRFTokenHandler))
+		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/ws/ticket" && r.Method == http.MethodPost:
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.CreateWSTicketHandler))
+		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/me" && (r.Method == http.MethodPut || r.Method == http.MethodPatch):
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.UpdateProfileHandler))
+		authHandler.ServeHTTP(w, r)
 	case r.URL.Path == "/api/me":
 		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.GetCurrentUserHandler))
 		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/me/privacy" && r.Method == http.MethodGet:
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.GetPrivacySettingsHandler))
+		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/me/privacy" && r.Method == http.MethodPut:
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.UpdatePrivacySettingsHandler))
+		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/me/password":
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.ChangePasswordHandler))
+		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/me/avatar" && r.Method == http.MethodPost:
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.UploadAvatarHandler))
+		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/me/avatar" && r.Method == http.MethodDelete:
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.DeleteAvatarHandler))
+		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/me/blocks":
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.GetBlocksHandler))
+		authHandler.ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/me/blocks/") && r.Method == http.MethodPut:
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.BlockUserHandler))
+		authHandler.ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/me/blocks/"):
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.UnblockUserHandler))
+		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/me/mutes":
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.GetMutesHandler))
+		authHandler.ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/me/mutes/") && r.Method == http.MethodPut:
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.MuteUserHandler))
+		authHandler.ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/me/mutes/"):
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.UnmuteUserHandler))
+		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/me/bookmarks" && r.Method == http.MethodGet:
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.ListBookmarksHandler))
+		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/me/bookmarks":
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.CreateBookmarkHandler))
+		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/me/bookmarks/folders":
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.BookmarkFoldersHandler))
+		authHandler.ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/me/bookmarks/"):
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.BookmarkHandler))
+		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/me/email":
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.RequestEmailChangeHandler))
+		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/email/verify":
+		handlers.VerifyEmailChangeHandler(w, r)
 	case r.URL.Path == "/api/users/online":
 		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.GetOnlineUsersHandler))
 		authHandler.ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/users/") && r.Method == http.MethodGet:
+		handlers.GetPublicProfileHandler(w, r)
+
+	// Routes de l'authentification à deux facteurs
+	case r.URL.Path == "/api/2fa/status":
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.TwoFactorStatusHandler))
+		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/2fa/setup":
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.TwoFactorSetupHandler))
+		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/2fa/confirm":
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.TwoFactorConfirmHandler))
+		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/2fa/disable":
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.TwoFactorDisableHandler))
+		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/2fa/recovery-codes":
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.RegenerateRecoveryCodesHandler))
+		authHandler.ServeHTTP(w, r)
+
+	// Routes des pièces jointes
+	case r.URL.Path == "/api/attachments" && r.Method == http.MethodPost:
+		authHandler := middleware.AuthMiddleware(middleware.RejectMuted(http.HandlerFunc(handlers.UploadAttachmentHandler)))
+		middleware.RequireScope(database.ScopePostsWrite, authHandler).ServeHTTP(w, r)
+	case r.URL.Path == "/api/attachments/usage":
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.GetAttachmentUsageHandler))
+		authHandler.ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/attachments/") && r.Method == http.MethodDelete:
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.DeleteAttachmentHandler))
+		authHandler.ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/attachments/"):
+		optionalAuthHandler := middleware.OptionalAuthMiddleware(http.HandlerFunc(handlers.DownloadAttachmentHandler))
+		middleware.RequireScope(database.ScopePostsRead, optionalAuthHandler).ServeHTTP(w, r)
+
+	// Routes des jetons d'accès personnels (réservées aux sessions)
+	case r.URL.Path == "/api/tokens" && r.Method == http.MethodGet:
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.GetAPITokensHandler))
+		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/tokens" && r.Method == http.MethodPost:
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.CreateAPITokenHandler))
+		authHandler.ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/tokens/") && r.Method == http.MethodDelete:
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.RevokeAPITokenHandler))
+		authHandler.ServeHTTP(w, r)
 
 	// Routes des publications et commentaires
 	case r.URL.Path == "/api/posts" && r.Method == http.MethodGet:
 		optionalAuthHandler := middleware.OptionalAuthMiddleware(http.HandlerFunc(handlers.GetPostsHandler))
-		optionalAuthHandler.ServeHTTP(w, r)
+		middleware.RequireScope(database.ScopePostsRead, optionalAuthHandler).ServeHTTP(w, r)
 	case r.URL.Path == "/api/posts" && r.Method == http.MethodPost:
-		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.CreatePostHandler))
-		authHandler.ServeHTTP(w, r)
+		authHandler := middleware.AuthMiddleware(middleware.RejectMuted(http.HandlerFunc(handlers.CreatePostHandler)))
+		middleware.RequireScope(database.ScopePostsWrite, authHandler).ServeHTTP(w, r)
 	case r.URL.Path == "/api/categories":
 		handlers.GetCategoriesHandler(w, r)
-	case len(r.URL.Path) > 10 && r.URL.Path[:10] == "/api/posts/" && r.Method == http.MethodGet:
-		if len(r.URL.Path) > 19 && r.URL.Path[len(r.URL.Path)-9:] == "/comments" {
-			// Route pour les commentaires d'une publication
-			handlers.GetCommentsHandler(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/categories/") && strings.HasSuffix(r.URL.Path, "/read"):
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.MarkCategoryReadHandler))
+		middleware.RequireScope(database.ScopePostsRead, authHandler).ServeHTTP(w, r)
+	case r.URL.Path == "/api/tags":
+		handlers.GetTagsHandler(w, r)
+	case r.URL.Path == "/api/tags/suggest":
+		handlers.SuggestTagsHandler(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/posts/") && strings.HasSuffix(r.URL.Path, "/poll") && r.Method == http.MethodGet:
+		// Résultats du sondage d'une publication (authentification facultative pour les choix de l'utilisateur)
+		optionalAuthHandler := middleware.OptionalAuthMiddleware(http.HandlerFunc(handlers.GetPollHandler))
+		middleware.RequireScope(database.ScopePostsRead, optionalAuthHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/posts/") && strings.HasSuffix(r.URL.Path, "/poll/votes"):
+		authHandler := middleware.AuthMiddleware(middleware.RejectMuted(http.HandlerFunc(handlers.VotePollHandler)))
+		middleware.RequireScope(database.ScopePostsWrite, authHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/posts/") && strings.HasSuffix(r.URL.Path, "/vote"):
+		authHandler := middleware.AuthMiddleware(middleware.RejectMuted(http.HandlerFunc(handlers.VotePostHandler)))
+		middleware.RequireScope(database.ScopePostsWrite, authHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/posts/") && strings.HasSuffix(r.URL.Path, "/read"):
+		// Suivi de lecture de l'utilisateur
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.MarkPostReadHandler))
+		middleware.RequireScope(database.ScopePostsRead, authHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/posts/") && r.Method == http.MethodGet:
+		if strings.HasSuffix(r.URL.Path, "/comments") {
+			// Route pour les commentaires d'une publication (authentification facultative pour la sourdine)
+			optionalAuthHandler := middleware.OptionalAuthMiddleware(http.HandlerFunc(handlers.GetCommentsHandler))
+			middleware.RequireScope(database.ScopePostsRead, optionalAuthHandler).ServeHTTP(w, r)
 		} else {
-			// Route pour une publication spécifique
-			handlers.GetPostHandler(w, r)
+			// Route pour une publication spécifique (authentification facultative pour les favoris,
+			// le suivi de lecture et la déduplication des vues)
+			optionalAuthHandler := middleware.OptionalAuthMiddleware(http.HandlerFunc(handlers.GetPostHandler))
+			middleware.RequireScope(database.ScopePostsRead, optionalAuthHandler).ServeHTTP(w, r)
 		}
-	case len(r.URL.Path) > 19 && r.URL.Path[:19] == "/api/posts/" && r.URL.Path[len(r.URL.Path)-9:] == "/comments" && r.Method == http.MethodPost:
+	case strings.HasPrefix(r.URL.Path, "/api/posts/") && strings.HasSuffix(r.URL.Path, "/comments") && r.Method == http.MethodPost:
 		// Route pour créer un commentaire
-		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.CreateCommentHandler))
-		authHandler.ServeHTTP(w, r)
+		authHandler := middleware.AuthMiddleware(middleware.RejectMuted(http.HandlerFunc(handlers.CreateCommentHandler)))
+		middleware.RequireScope(database.ScopePostsWrite, authHandler).ServeHTTP(w, r)
+
+	// Routes des brouillons et publications programmées
+	case r.URL.Path == "/api/drafts" && r.Method == http.MethodGet:
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.ListDraftsHandler))
+		middleware.RequireScope(database.ScopePostsWrite, authHandler).ServeHTTP(w, r)
+	case r.URL.Path == "/api/drafts":
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.CreateDraftHandler))
+		middleware.RequireScope(database.ScopePostsWrite, authHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/drafts/") && strings.HasSuffix(r.URL.Path, "/publish"):
+		authHandler := middleware.AuthMiddleware(middleware.RejectMuted(http.HandlerFunc(handlers.PublishDraftHandler)))
+		middleware.RequireScope(database.ScopePostsWrite, authHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/drafts/"):
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.DraftHandler))
+		middleware.RequireScope(database.ScopePostsWrite, authHandler).ServeHTTP(w, r)
 
 	// Routes des messages privés
 	case r.URL.Path == "/api/messages" && r.Method == http.MethodPost:
-		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.SendPrivateMessageHandler))
-		authHandler.ServeHTTP(w, r)
+		authHandler := middleware.AuthMiddleware(middleware.RejectMuted(http.HandlerFunc(handlers.SendPrivateMessageHandler)))
+		middleware.RequireScope(database.ScopeMessagesSend, authHandler).ServeHTTP(w, r)
 	case len(r.URL.Path) > 14 && r.URL.Path[:14] == "/api/messages/" && r.Method == http.MethodGet:
 		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.GetPrivateMessagesHandler))
 		authHandler.ServeHTTP(w, r)
 
+	// Commandes slash enregistrées par les bots
+	case r.URL.Path == "/api/commands" && r.Method == http.MethodGet:
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.GetCommandsHandler))
+		authHandler.ServeHTTP(w, r)
+
 	// Routes pour l'indicateur de frappe
 	case r.URL.Path == "/api/typing" && r.Method == http.MethodPost:
-		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.UpdateTypingStatusHandler))
+		authHandler := middleware.AuthMiddleware(middleware.RejectMuted(http.HandlerFunc(handlers.UpdateTypingStatusHandler)))
 		authHandler.ServeHTTP(w, r)
 	case len(r.URL.Path) > 12 && r.URL.Path[:12] == "/api/typing/" && r.Method == http.MethodGet:
 		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.GetTypingStatusHandler))
 		authHandler.ServeHTTP(w, r)
 
+	// Routes de signalement et de modération
+	case r.URL.Path == "/api/reports" && r.Method == http.MethodPost:
+		authHandler := middleware.AuthMiddleware(http.HandlerFunc(handlers.CreateReportHandler))
+		authHandler.ServeHTTP(w, r)
+	case r.URL.Path == "/api/moderation/reports" && r.Method == http.MethodGet:
+		moderatorHandler(handlers.GetReportsHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/moderation/reports/") && strings.HasSuffix(r.URL.Path, "/claim"):
+		moderatorHandler(handlers.ClaimReportHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/moderation/reports/") && strings.HasSuffix(r.URL.Path, "/resolve"):
+		moderatorHandler(handlers.ResolveReportHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/moderation/reports/") && strings.HasSuffix(r.URL.Path, "/dismiss"):
+		moderatorHandler(handlers.DismissReportHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/moderation/users/") && strings.HasSuffix(r.URL.Path, "/sanctions") && r.Method == http.MethodGet:
+		moderatorHandler(handlers.GetUserSanctionsHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/moderation/users/") && strings.HasSuffix(r.URL.Path, "/sanctions"):
+		moderatorHandler(handlers.CreateSanctionHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/moderation/posts/") && r.Method == http.MethodPut:
+		moderatorHandler(handlers.UpdatePostStateHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/moderation/sanctions/"):
+		moderatorHandler(handlers.RevokeSanctionHandler).ServeHTTP(w, r)
+
+	// Routes d'administration
+	case r.URL.Path == "/api/admin/lockouts" && r.Method == http.MethodGet:
+		adminHandler(handlers.GetLockoutsHandler).ServeHTTP(w, r)
+	case r.URL.Path == "/api/admin/login-failures" && r.Method == http.MethodGet:
+		adminHandler(handlers.GetLoginFailuresHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/admin/users/") && strings.HasSuffix(r.URL.Path, "/unlock") && r.Method == http.MethodPost:
+		adminHandler(handlers.UnlockUserHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/admin/users/") && strings.HasSuffix(r.URL.Path, "/role"):
+		adminHandler(handlers.SetUserRoleHandler).ServeHTTP(w, r)
+	case r.URL.Path == "/api/admin/audit" && r.Method == http.MethodGet:
+		adminHandler(handlers.GetAuditLogHandler).ServeHTTP(w, r)
+	case r.URL.Path == "/api/admin/audit/export" && r.Method == http.MethodGet:
+		adminHandler(handlers.ExportAuditLogHandler).ServeHTTP(w, r)
+	case r.URL.Path == "/api/admin/categories" && r.Method == http.MethodPost:
+		adminHandler(handlers.CreateCategoryHandler).ServeHTTP(w, r)
+	case r.URL.Path == "/api/admin/categories/order" && r.Method == http.MethodPut:
+		adminHandler(handlers.ReorderCategoriesHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/admin/categories/") && r.Method == http.MethodPut:
+		adminHandler(handlers.UpdateCategoryHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/admin/categories/") && r.Method == http.MethodDelete:
+		adminHandler(handlers.DeleteCategoryHandler).ServeHTTP(w, r)
+	case r.URL.Path == "/api/admin/tags" && r.Method == http.MethodPost:
+		adminHandler(handlers.CreateTagHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/admin/tags/") && r.Method == http.MethodPut:
+		adminHandler(handlers.UpdateTagHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/admin/tags/") && r.Method == http.MethodDelete:
+		adminHandler(handlers.DeleteTagHandler).ServeHTTP(w, r)
+	case r.URL.Path == "/api/admin/webhooks" && r.Method == http.MethodGet:
+		adminHandler(handlers.GetWebhooksHandler).ServeHTTP(w, r)
+	case r.URL.Path == "/api/admin/webhooks" && r.Method == http.MethodPost:
+		adminHandler(handlers.CreateWebhookHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/admin/webhooks/") && strings.HasSuffix(r.URL.Path, "/deliveries") && r.Method == http.MethodGet:
+		adminHandler(handlers.GetWebhookDeliveriesHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/admin/webhooks/") && r.Method == http.MethodPatch:
+		adminHandler(handlers.UpdateWebhookHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/admin/webhooks/") && r.Method == http.MethodDelete:
+		adminHandler(handlers.DeleteWebhookHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/admin/webhook-deliveries/") && strings.HasSuffix(r.URL.Path, "/redeliver") && r.Method == http.MethodPost:
+		adminHandler(handlers.RedeliverWebhookHandler).ServeHTTP(w, r)
+	case r.URL.Path == "/api/admin/bots" && r.Method == http.MethodGet:
+		adminHandler(handlers.GetBotsHandler).ServeHTTP(w, r)
+	case r.URL.Path == "/api/admin/bots" && r.Method == http.MethodPost:
+		adminHandler(handlers.CreateBotHandler).ServeHTTP(w, r)
+	case strings.HasPrefix(r.URL.Path, "/api/admin/bots/") && strings.HasSuffix(r.URL.Path, "/token") && r.Method == http.MethodPost:
+		adminHandler(handlers.IssueBotTokenHandler).ServeHTTP(w, r)
+
 	// Route par défaut
 	default:
 		http.NotFound(w, r)
 	}
 }
 
+// adminHandler protège un gestionnaire par authentification et rôle administrateur
+func adminHandler(h http.HandlerFunc) http.Handler {
+	return middleware.AuthMiddleware(middleware.RequireRole(h, database.RoleAdmin))
+}
+
+// moderatorHandler protège un gestionnaire par authentification et rôle modérateur ou administrateur
+func moderatorHandler(h http.HandlerFunc) http.Handler {
+	return middleware.AuthMiddleware(middleware.RequireRole(h, database.RoleModerator, database.RoleAdmin))
+}
+
 // SetupRoutes configure toutes les routes de l'application
 func SetupRoutes() http.Handler {
 	// Créer un nouveau multiplexeur
 	mux := http.NewServeMux()
 
-	// Ajouter un gestionnaire CORS global
-	corsHandler := func(h http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Configurer les en-têtes CORS
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-			w.Header().Set("Access-Control-Allow-Credentials", "true")
-
-			// Répondre immédiatement aux requêtes OPTIONS
-			if r.Method == http.MethodOptions {
-				w.WriteHeader(http.StatusOK)
-				return
-			}
-
-			// Appeler le gestionnaire suivant
-			h.ServeHTTP(w, r)
-		})
-	}
-
-	// Appliquer le middleware CORS à toutes les routes
-	handler := corsHandler(apiHandler{})
-	mux.Handle("/api/", handler)
+	// Appliquer la politique CORS (liste d'origines autorisées) à toutes les routes de l'API
+	mux.Handle("/api/", middleware.CORSMiddleware(apiHandler{}))
 
-	// Ajouter le gestionnaire WebSocket avec CORS
-	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		// Appliquer CORS
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	// Ajouter le gestionnaire WebSocket
+	mux.HandleFunc("/ws", handlers.WebSocketHandler)
 
-		// Traiter la requête WebSocket
-		handlers.WebSocketHandler(w, r)
-	})
+	// Servir les fichiers envoyés par les utilisateurs (avatars)
+	mux.HandleFunc(database.MediaURLPrefix, handlers.ServeMediaHandler)
 
 	// Servir les fichiers statiques avec gestion du SPA
 	fileServer := http.FileServer(http.Dir("static"))
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		// Appliquer CORS pour les fichiers statiques aussi
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
 		path := r.URL.Path
 
 		// Si c'est un fichier JavaScript ou CSS, le servir directement
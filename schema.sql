@@ -12,24 +12,164 @@ CREATE TABLE IF NOT EXISTS users (
     password TEXT NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     last_login TIMESTAMP,
-    online BOOLEAN DEFAULT FALSE
+    online BOOLEAN DEFAULT FALSE,
+    totp_secret TEXT,
+    totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    totp_last_step INTEGER NOT NULL DEFAULT 0,
+    role TEXT NOT NULL DEFAULT 'user',
+    user_type TEXT NOT NULL DEFAULT 'human',
+    bio TEXT NOT NULL DEFAULT '',
+    -- Paramètres de confidentialité : champs visibles par les autres utilisateurs
+    privacy_real_name BOOLEAN NOT NULL DEFAULT FALSE,
+    privacy_age BOOLEAN NOT NULL DEFAULT FALSE,
+    privacy_gender BOOLEAN NOT NULL DEFAULT FALSE,
+    privacy_email BOOLEAN NOT NULL DEFAULT FALSE,
+    privacy_last_seen BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Version de l'avatar courant (vide sans avatar), incluse dans les clés de stockage
+    avatar_version TEXT NOT NULL DEFAULT '',
+    -- Adresse IP de la dernière connexion (bannissement par IP)
+    last_ip TEXT NOT NULL DEFAULT ''
 );
 
+-- Table des codes de récupération 2FA (stockés hachés)
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    code_hash TEXT NOT NULL,
+    used_at TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes(user_id);
+
 -- Table des sessions
 CREATE TABLE IF NOT EXISTS sessions (
     id TEXT PRIMARY KEY,
     user_id INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    last_seen_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    csrf_token TEXT NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at);
+
+-- Table des jetons d'accès personnels (API)
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    prefix TEXT NOT NULL,
+    scopes TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_tokens_user_id ON api_tokens(user_id);
+
+-- Table des webhooks sortants configurés par les administrateurs
+CREATE TABLE IF NOT EXISTS webhooks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    events TEXT NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_by INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- File et journal des livraisons de webhooks
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_id INTEGER NOT NULL,
+    event TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_attempt_at TIMESTAMP,
+    response_status INTEGER,
+    last_error TEXT,
+    redelivery_of INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_pending ON webhook_deliveries(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id);
+
+-- Table des changements d'adresse email en attente de vérification
+CREATE TABLE IF NOT EXISTS email_changes (
+    token_hash TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    new_email TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Table des tickets de connexion WebSocket (usage unique, courte durée)
+CREATE TABLE IF NOT EXISTS ws_tickets (
+    id TEXT PRIMARY KEY,
+    session_id TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+
+-- Table des sessions partielles (mot de passe vérifié, second facteur en attente)
+CREATE TABLE IF NOT EXISTS partial_sessions (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
     expires_at TIMESTAMP NOT NULL,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 );
 
+-- Table des compteurs d'échecs de connexion (par utilisateur/identifiant et par IP)
+CREATE TABLE IF NOT EXISTS login_throttles (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP
+);
+
+-- Journal des tentatives de connexion échouées
+CREATE TABLE IF NOT EXISTS login_failures (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    identifier TEXT NOT NULL,
+    user_id INTEGER,
+    ip TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_failures_created_at ON login_failures(created_at);
+
 -- Table des catégories
 CREATE TABLE IF NOT EXISTS categories (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
-    name TEXT NOT NULL UNIQUE,
-    description TEXT NOT NULL
+    name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    description TEXT NOT NULL,
+    -- Emoji ou nom d'icône affiché à côté du nom
+    icon TEXT NOT NULL DEFAULT '',
+    -- Catégorie principale d'une sous-catégorie (un seul niveau d'imbrication)
+    parent_id INTEGER,
+    -- Ordre d'affichage parmi les catégories de même niveau
+    position INTEGER NOT NULL DEFAULT 0,
+    -- Une catégorie archivée reste consultable mais n'accepte plus de publications
+    archived BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (parent_id) REFERENCES categories(id) ON DELETE SET NULL
 );
 
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories(parent_id);
+
 -- Table des publications
 CREATE TABLE IF NOT EXISTS posts (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -39,10 +179,141 @@ CREATE TABLE IF NOT EXISTS posts (
     category_id INTEGER NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    -- Masquée par la modération
+    hidden BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Épinglée en tête de sa catégorie ('category') ou de toutes les listes ('global')
+    pinned TEXT NOT NULL DEFAULT '',
+    pinned_at TIMESTAMP,
+    -- Verrouillée : plus de nouveaux commentaires
+    locked BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Annonce de l'équipe, mise en évidence
+    announcement BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Nombre de vues uniques (voir post_views)
+    view_count INTEGER NOT NULL DEFAULT 0,
+    -- Compteurs matérialisés pour les tris : somme des votes (voir post_votes), rang « hot »
+    -- (votes et ancienneté) et date du dernier commentaire (ou de la publication)
+    score INTEGER NOT NULL DEFAULT 0,
+    hot_rank REAL NOT NULL DEFAULT 0,
+    last_activity_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
-    FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+    FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS idx_posts_category_id ON posts(category_id);
+CREATE INDEX IF NOT EXISTS idx_posts_created_at ON posts(created_at);
+CREATE INDEX IF NOT EXISTS idx_posts_last_activity_at ON posts(last_activity_at);
+CREATE INDEX IF NOT EXISTS idx_posts_hot_rank ON posts(hot_rank);
+CREATE INDEX IF NOT EXISTS idx_posts_score ON posts(score DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_posts_pinned ON posts(pinned, pinned_at);
+
+-- Votes des utilisateurs sur les publications (+1 ou -1)
+CREATE TABLE IF NOT EXISTS post_votes (
+    post_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    value INTEGER NOT NULL CHECK (value IN (-1, 1)),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, user_id),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Table des étiquettes (noms normalisés) ; les étiquettes officielles sont proposées en priorité
+CREATE TABLE IF NOT EXISTS tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    curated BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Étiquettes des publications
+CREATE TABLE IF NOT EXISTS post_tags (
+    post_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (post_id, tag_id),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_tags_tag_id ON post_tags(tag_id);
+
+-- Sondages (au plus un par publication)
+CREATE TABLE IF NOT EXISTS polls (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER NOT NULL UNIQUE,
+    question TEXT NOT NULL,
+    -- Plusieurs choix possibles par votant
+    multiple BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Votes anonymes : seuls les totaux sont publiés
+    anonymous BOOLEAN NOT NULL DEFAULT FALSE,
+    closes_at TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+-- Choix proposés par un sondage
+CREATE TABLE IF NOT EXISTS poll_options (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    poll_id INTEGER NOT NULL,
+    label TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    FOREIGN KEY (poll_id) REFERENCES polls(id) ON DELETE CASCADE
 );
 
+CREATE INDEX IF NOT EXISTS idx_poll_options_poll_id ON poll_options(poll_id);
+
+-- Bulletins : la clé primaire garantit un seul vote par utilisateur et par sondage
+CREATE TABLE IF NOT EXISTS poll_ballots (
+    poll_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (poll_id, user_id),
+    FOREIGN KEY (poll_id) REFERENCES polls(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Choix retenus par chaque bulletin
+CREATE TABLE IF NOT EXISTS poll_votes (
+    poll_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    option_id INTEGER NOT NULL,
+    PRIMARY KEY (poll_id, user_id, option_id),
+    FOREIGN KEY (poll_id, user_id) REFERENCES poll_ballots(poll_id, user_id) ON DELETE CASCADE,
+    FOREIGN KEY (option_id) REFERENCES poll_options(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_poll_votes_option_id ON poll_votes(option_id);
+
+-- Un sondage à choix unique n'accepte qu'un choix par bulletin
+CREATE TRIGGER IF NOT EXISTS poll_votes_single_choice
+BEFORE INSERT ON poll_votes
+WHEN (SELECT multiple FROM polls WHERE id = NEW.poll_id) = FALSE
+    AND EXISTS (SELECT 1 FROM poll_votes WHERE poll_id = NEW.poll_id AND user_id = NEW.user_id)
+BEGIN
+    SELECT RAISE(ABORT, 'un seul choix autorisé pour ce sondage');
+END;
+
+-- Brouillons de publications, enregistrés automatiquement pendant la rédaction.
+-- Un brouillon avec publish_at est programmé : il devient une publication à cette date.
+CREATE TABLE IF NOT EXISTS post_drafts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    content TEXT NOT NULL DEFAULT '',
+    category_id INTEGER NOT NULL DEFAULT 0,
+    -- Étiquettes et sondage au format JSON, validés à la publication
+    tags TEXT NOT NULL DEFAULT '[]',
+    poll TEXT,
+    publish_at TIMESTAMP,
+    -- Motif du dernier échec de publication programmée
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_drafts_user_id ON post_drafts(user_id);
+CREATE INDEX IF NOT EXISTS idx_post_drafts_publish_at ON post_drafts(publish_at);
+
 -- Table des commentaires
 CREATE TABLE IF NOT EXISTS comments (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -50,10 +321,25 @@ CREATE TABLE IF NOT EXISTS comments (
     user_id INTEGER NOT NULL,
     content TEXT NOT NULL,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    hidden BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Citation d'un autre commentaire : commentaire source et extrait cité
+    quote_comment_id INTEGER,
+    quote_excerpt TEXT NOT NULL DEFAULT '',
     FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 );
 
+-- Liens internes (#post-123) d'une publication ou d'un commentaire vers une autre publication
+CREATE TABLE IF NOT EXISTS content_links (
+    source_type TEXT NOT NULL CHECK (source_type IN ('post', 'comment')),
+    source_id INTEGER NOT NULL,
+    target_post_id INTEGER NOT NULL,
+    PRIMARY KEY (source_type, source_id, target_post_id),
+    FOREIGN KEY (target_post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_links_target ON content_links(target_post_id);
+
 -- Table des messages privés
 CREATE TABLE IF NOT EXISTS private_messages (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -62,10 +348,166 @@ CREATE TABLE IF NOT EXISTS private_messages (
     content TEXT NOT NULL,
     read BOOLEAN DEFAULT FALSE,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    hidden BOOLEAN NOT NULL DEFAULT FALSE,
     FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE,
     FOREIGN KEY (receiver_id) REFERENCES users(id) ON DELETE CASCADE
 );
 
+-- Vues uniques des publications : un lecteur ('user:{id}' ou 'visitor:{jeton}') n'est compté qu'une fois
+CREATE TABLE IF NOT EXISTS post_views (
+    post_id INTEGER NOT NULL,
+    viewer TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (post_id, viewer),
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+-- Suivi de lecture : dernier commentaire lu par un utilisateur sur une publication
+CREATE TABLE IF NOT EXISTS post_reads (
+    user_id INTEGER NOT NULL,
+    post_id INTEGER NOT NULL,
+    last_comment_id INTEGER NOT NULL DEFAULT 0,
+    read_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, post_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+-- Favoris : publications, commentaires ou messages privés enregistrés par un utilisateur,
+-- avec une note et un dossier facultatifs
+CREATE TABLE IF NOT EXISTS bookmarks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    target_type TEXT NOT NULL CHECK (target_type IN ('post', 'comment', 'message')),
+    target_id INTEGER NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    folder TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, target_type, target_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_bookmarks_user_folder ON bookmarks(user_id, folder);
+
+-- Table des pièces jointes (le contenu est dans le stockage de fichiers)
+-- Une pièce jointe est d'abord envoyée seule, puis rattachée à une publication ou à un message privé
+CREATE TABLE IF NOT EXISTS attachments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    storage_key TEXT NOT NULL UNIQUE,
+    thumbnail_key TEXT,
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    width INTEGER NOT NULL DEFAULT 0,
+    height INTEGER NOT NULL DEFAULT 0,
+    post_id INTEGER,
+    message_id INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE SET NULL,
+    FOREIGN KEY (message_id) REFERENCES private_messages(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_attachments_user ON attachments(user_id);
+CREATE INDEX IF NOT EXISTS idx_attachments_post ON attachments(post_id);
+CREATE INDEX IF NOT EXISTS idx_attachments_message ON attachments(message_id);
+
+-- Table des blocages et mises en sourdine entre utilisateurs
+CREATE TABLE IF NOT EXISTS user_relations (
+    user_id INTEGER NOT NULL,
+    target_id INTEGER NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('block', 'mute')),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, target_id, kind),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (target_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_relations_target ON user_relations(target_id, kind);
+
+-- Table des signalements de contenu (publications, commentaires, messages privés)
+CREATE TABLE IF NOT EXISTS reports (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    reporter_id INTEGER NOT NULL,
+    target_type TEXT NOT NULL CHECK (target_type IN ('post', 'comment', 'message')),
+    target_id INTEGER NOT NULL,
+    -- Auteur du contenu signalé et copie du contenu au moment du signalement
+    target_user_id INTEGER NOT NULL,
+    content_snapshot TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    details TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open',
+    moderator_id INTEGER,
+    actions TEXT NOT NULL DEFAULT '',
+    resolution_note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP,
+    FOREIGN KEY (reporter_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (target_user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (moderator_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_reports_status ON reports(status, created_at);
+-- Un utilisateur ne peut avoir qu'un signalement en cours par contenu
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_pending ON reports(reporter_id, target_type, target_id)
+    WHERE status IN ('open', 'claimed');
+
+-- Table des sanctions prononcées contre des utilisateurs
+CREATE TABLE IF NOT EXISTS sanctions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    moderator_id INTEGER,
+    report_id INTEGER,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    -- Fin de la sanction (NULL pour un avertissement ou un bannissement définitif)
+    expires_at TIMESTAMP,
+    -- Adresse IP bannie avec le compte (bannissement uniquement, NULL sinon)
+    ip TEXT,
+    -- Levée anticipée de la sanction par un modérateur
+    revoked_at TIMESTAMP,
+    revoked_by INTEGER,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (moderator_id) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (report_id) REFERENCES reports(id) ON DELETE SET NULL,
+    FOREIGN KEY (revoked_by) REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sanctions_user ON sanctions(user_id, kind);
+CREATE INDEX IF NOT EXISTS idx_sanctions_ip ON sanctions(ip) WHERE ip IS NOT NULL;
+
+-- Journal d'audit des actions sensibles (connexions, sessions, rôles, modération, suppressions).
+-- Les entrées ne sont jamais modifiées ni supprimées : actor_id et target_id ne sont pas des clés étrangères
+-- pour que l'historique survive aux objets concernés.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    action TEXT NOT NULL,
+    -- Auteur de l'action (NULL pour une tentative de connexion anonyme ou une action du système)
+    actor_id INTEGER,
+    target_type TEXT NOT NULL DEFAULT '',
+    target_id INTEGER,
+    ip TEXT NOT NULL DEFAULT '',
+    -- Informations complémentaires propres à l'action (JSON)
+    details TEXT NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log(target_type, target_id, created_at);
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'le journal d''audit est en ajout seul');
+END;
+
+CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+BEGIN
+    SELECT RAISE(ABORT, 'le journal d''audit est en ajout seul');
+END;
+
 -- Table des indicateurs de frappe
 CREATE TABLE IF NOT EXISTS typing_indicators (
     user_id INTEGER NOT NULL,
@@ -77,10 +519,15 @@ CREATE TABLE IF NOT EXISTS typing_indicators (
     FOREIGN KEY (target_user_id) REFERENCES users(id) ON DELETE CASCADE
 );
 
--- Insertion de catégories de base
-INSERT INTO categories (name, description) VALUES 
-('Général', 'Discussions générales'),
-('Technologie', 'Discussions sur la technologie'),
-('Sports', 'Discussions sur les sports'),
-('Jeux vidéo', 'Discussions sur les jeux vidéo'),
-('Musique', 'Discussions sur la musique');
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_typing_indicators_updated_at ON typing_indicators(updated_at);
+
+-- Insertion de catégories de base (uniquement dans une base sans catégorie)
+INSERT INTO categories (name, description, icon, position)
+SELECT column1, column2, column3, column4 FROM (VALUES
+    ('Général', 'Discussions générales', '💬', 0),
+    ('Technologie', 'Discussions sur la technologie', '💻', 1),
+    ('Sports', 'Discussions sur les sports', '⚽', 2),
+    ('Jeux vidéo', 'Discussions sur les jeux vidéo', '🎮', 3),
+    ('Musique', 'Discussions sur la musique', '🎵', 4)
+)
+WHERE NOT EXISTS (SELECT 1 FROM categories);
@@ -1,9 +1,13 @@
-import { initAuth, isAuthenticated, getCurrentUser } from './auth.js';
-import { initPosts } from './posts.js';
+import { initAuth, getCurrentUser, csrfHeaders } from './auth.js';
+import { initPosts, renderPoll } from './posts.js';
 import { initMessages } from './messages.js';
+import { initBookmarks, fetchBookmarks, createBookmarkButton } from './bookmarks.js';
 import { initWebSocket } from './websocket.js';
 import { initUI, showPage, updateUI } from './ui.js';
 
+// Nombre de publications non épinglées chargées à la fois
+const POSTS_PAGE_SIZE = 20;
+
 // État global de l'application
 const state = {
     currentUser: null,
@@ -12,6 +16,10 @@ const state = {
     onlineUsers: [],
     categories: [],
     posts: [],
+    // Filtre courant de la liste des publications (catégorie ou étiquettes), le tri étant lu dans la page
+    postsFilter: '',
+    // Nombre de publications non épinglées déjà chargées (pagination de la liste)
+    postsOffset: 0,
     currentPost: null,
     currentChatUser: null,
     socket: null
@@ -38,14 +46,21 @@ async function initApp() {
         console.log("Initialisation des messages...");
         initMessages(state);
 
-        // Charger les publications et les catégories
+        // Initialiser les favoris
+        initBookmarks();
+
+        // Configurer le tri des publications
+        setupPostsSort();
+
+        // Charger les publications, les catégories et les étiquettes populaires
         await fetchPosts();
         await fetchCategories();
+        await fetchPopularTags();
 
         // Initialiser les WebSockets si l'utilisateur est authentifié
         if (state.isAuthenticated) {
             console.log("Initialisation des WebSockets...");
-            const socket = initWebSocket(state, handleWebSocketMessage);
+            const socket = await initWebSocket(state, handleWebSocketMessage);
             state.socket = socket;
 
             // Charger les utilisateurs en ligne
@@ -82,8 +97,9 @@ function handleAuthenticationChange() {
     if (state.isAuthenticated) {
         // L'utilisateur vient de se connecter
         if (!state.socket) {
-            const socket = initWebSocket(state, handleWebSocketMessage);
-            state.socket = socket;
+            initWebSocket(state, handleWebSocketMessage).then(socket => {
+                state.socket = socket;
+            });
         }
 
         // Charger les utilisateurs en ligne
@@ -146,6 +162,50 @@ function handleWebSocketMessage(event) {
                 }
                 break;
 
+            case 'post_state_changed':
+                handlePostStateChanged(message.payload);
+                break;
+
+            case 'poll_updated':
+                handlePollUpdated(message.payload);
+                break;
+
+            case 'post_score_updated':
+                handlePostScoreUpdated(message.payload);
+                break;
+
+            case 'draft_published':
+                notifyUser('Publication programmée publiée', message.payload.post.title);
+                break;
+
+            case 'draft_failed':
+                notifyUser('Publication programmée annulée', `${message.payload.title || '(sans titre)'}: ${message.payload.error}`);
+                break;
+
+            case 'message_error':
+                notifyUser('Message non envoyé', message.payload.error);
+                break;
+
+            case 'report_created':
+                notifyUser('Nouveau signalement', `${message.payload.reporter} a signalé un contenu de ${message.payload.targetUsername} (${message.payload.reason})`);
+                break;
+
+            case 'report_updated':
+                console.log('Signalement mis à jour:', message.payload.id, message.payload.status);
+                break;
+
+            case 'moderation_warning':
+                notifyUser('Avertissement de la modération', message.payload.message || `Motif: ${message.payload.reason}`);
+                break;
+
+            case 'moderation_mute':
+                notifyUser('Compte en lecture seule', message.payload.message || `Motif: ${message.payload.reason}`);
+                break;
+
+            case 'command_response':
+                console.log('Réponse de la commande /' + message.payload.command + ' par', message.payload.botUsername);
+                handleCommandResponse(message.payload);
+                break;
 
             default:
                 console.log('Type de message non géré:', message.type);
@@ -155,6 +215,31 @@ function handleWebSocketMessage(event) {
     }
 }
 
+// Afficher la réponse d'un bot à une commande slash (non enregistrée dans l'historique)
+function handleCommandResponse(response) {
+    const messagesList = document.getElementById('messages-list');
+    if (state.currentPage !== 'messages' || !messagesList) {
+        notifyUser(`/${response.command}`, `${response.botUsername}: ${response.content}`);
+        return;
+    }
+
+    const messageDiv = document.createElement('div');
+    messageDiv.className = 'message received command-response';
+
+    const content = document.createElement('div');
+    content.className = 'message-content';
+    content.textContent = `${response.botUsername} (/${response.command}) : ${response.content}`;
+
+    const time = document.createElement('div');
+    time.className = 'message-time';
+    time.textContent = new Date(response.createdAt).toLocaleTimeString();
+
+    messageDiv.appendChild(content);
+    messageDiv.appendChild(time);
+    messagesList.appendChild(messageDiv);
+    messagesList.scrollTop = messagesList.scrollHeight;
+}
+
 // Gestion des messages privés reçus
 function handlePrivateMessage(message) {
     console.log('Traitement du message privé:', message);
@@ -188,6 +273,9 @@ function handlePrivateMessage(message) {
         time.textContent = new Date(message.createdAt).toLocaleTimeString();
 
         messageDiv.appendChild(content);
+        if (message.attachments) {
+            messageDiv.appendChild(createAttachmentList(message.attachments));
+        }
         messageDiv.appendChild(time);
 
         // Supprimer le message "Aucun message" s'il existe
@@ -275,8 +363,10 @@ function handleTypingIndicator(typingData) {
 
 // Gestion des nouvelles publications
 function handleNewPost(post) {
-    // Ajouter la nouvelle publication à la liste
-    state.posts = [post, ...state.posts];
+    // Ajouter la nouvelle publication à la liste, après les publications épinglées
+    const firstUnpinned = state.posts.findIndex(existing => !existing.pinned);
+    const index = firstUnpinned === -1 ? state.posts.length : firstUnpinned;
+    state.posts = [...state.posts.slice(0, index), post, ...state.posts.slice(index)];
 
     // Mettre à jour l'affichage si nécessaire
     if (state.currentPage === 'home') {
@@ -284,6 +374,287 @@ function handleNewPost(post) {
     }
 }
 
+// Gestion des changements d'état d'une publication (épinglage, verrouillage, annonce)
+function handlePostStateChanged(change) {
+    const apply = post => {
+        const becameAnnouncement = change.announcement && !post.announcement;
+        post.pinned = change.pinned || undefined;
+        post.locked = change.locked;
+        post.announcement = change.announcement;
+        if (becameAnnouncement) {
+            notifyUser('Annonce', post.title);
+        }
+    };
+
+    const post = state.posts.find(existing => existing.id === change.postId);
+    if (post) {
+        apply(post);
+        if (state.currentPage === 'home') {
+            updatePostsList();
+        }
+    }
+
+    if (state.currentPost && state.currentPost.id === change.postId) {
+        if (state.currentPost !== post) {
+            apply(state.currentPost);
+        }
+        updateCommentsLock(state.currentPost);
+    }
+}
+
+// Gestion des nouveaux résultats d'un sondage
+function handlePollUpdated(poll) {
+    // Les résultats diffusés ne portent pas les choix de l'utilisateur : conserver ceux déjà connus
+    const current = state.currentPost && state.currentPost.id === poll.postId ? state.currentPost : null;
+    const myVotes = current && current.poll ? current.poll.myVotes : undefined;
+
+    const post = state.posts.find(existing => existing.id === poll.postId);
+    if (post) {
+        post.poll = poll;
+    }
+
+    if (current) {
+        current.poll = { ...poll, myVotes };
+        if (state.currentPage === 'post-detail') {
+            renderPoll(current.poll);
+        }
+    }
+}
+
+// Gestion des changements de score d'une publication (le vote de l'utilisateur n'est pas diffusé)
+function handlePostScoreUpdated({ postId, score }) {
+    const post = state.posts.find(existing => existing.id === postId);
+    if (post) {
+        post.score = score;
+    }
+    if (state.currentPost && state.currentPost.id === postId) {
+        state.currentPost.score = score;
+    }
+    document.querySelectorAll(`.vote-control[data-post-id="${postId}"] .vote-score`).forEach(element => {
+        element.textContent = score;
+    });
+}
+
+// Créer les boutons de vote d'une publication avec son score ; un vote identique au vote actuel le retire
+function createVoteControl(post) {
+    const control = document.createElement('span');
+    control.className = 'vote-control';
+    control.dataset.postId = post.id;
+
+    const canVote = state.isAuthenticated && state.currentUser && state.currentUser.id !== post.userId;
+
+    const render = () => {
+        control.innerHTML = '';
+        [[1, '▲', 'Voter pour'], [0, null, null], [-1, '▼', 'Voter contre']].forEach(([value, label, title]) => {
+            if (value === 0) {
+                const score = document.createElement('span');
+                score.className = 'vote-score';
+                score.textContent = post.score;
+                control.appendChild(score);
+                return;
+            }
+
+            const button = document.createElement('button');
+            button.type = 'button';
+            button.className = 'vote-button';
+            button.textContent = label;
+            button.title = title;
+            button.disabled = !canVote;
+            button.classList.toggle('active', post.myVote === value);
+            button.onclick = async (e) => {
+                e.stopPropagation();
+                try {
+                    const response = await fetch(`/api/posts/${post.id}/vote`, {
+                        method: 'PUT',
+                        headers: {
+                            'Content-Type': 'application/json',
+                            ...csrfHeaders()
+                        },
+                        body: JSON.stringify({ value: post.myVote === value ? 0 : value })
+                    });
+                    if (!response.ok) {
+                        throw new Error(await response.text());
+                    }
+
+                    const result = await response.json();
+                    post.score = result.score;
+                    post.myVote = result.myVote;
+                    render();
+                } catch (error) {
+                    console.error('Erreur lors du vote:', error);
+                    alert('Erreur lors du vote: ' + error.message);
+                }
+            };
+            control.appendChild(button);
+        });
+    };
+    render();
+
+    return control;
+}
+
+// Ouvrir une publication à partir de son ID
+async function openPost(postId) {
+    const post = await fetchPostById(postId);
+    if (post) {
+        navigateTo('post-detail', post);
+    }
+}
+
+// Afficher un contenu en remplaçant les liens internes résolus (#post-123) par le titre de la publication
+function renderLinkedContent(element, text, links) {
+    element.textContent = '';
+    const available = new Map((links || []).filter(link => link.available).map(link => [link.postId, link]));
+
+    let last = 0;
+    for (const match of text.matchAll(/#post-(\d+)\b/g)) {
+        const link = available.get(Number(match[1]));
+        if (!link) continue;
+
+        element.appendChild(document.createTextNode(text.slice(last, match.index)));
+        const anchor = document.createElement('a');
+        anchor.href = `/posts/${link.postId}`;
+        anchor.className = 'post-link';
+        anchor.textContent = link.title;
+        anchor.onclick = (e) => {
+            e.preventDefault();
+            e.stopPropagation();
+            openPost(link.postId);
+        };
+        element.appendChild(anchor);
+        last = match.index + match[0].length;
+    }
+    element.appendChild(document.createTextNode(text.slice(last)));
+}
+
+// Créer le bloc d'une citation ; un commentaire cité masqué depuis n'est plus affiché
+function createQuoteBlock(quote) {
+    const block = document.createElement('blockquote');
+    block.className = 'comment-quote';
+
+    if (!quote.available) {
+        block.classList.add('unavailable');
+        block.textContent = 'Commentaire cité indisponible';
+        return block;
+    }
+
+    const source = document.createElement('div');
+    source.className = 'comment-quote-source';
+    source.textContent = `${quote.author} a écrit :`;
+    source.onclick = () => {
+        // Dans la même discussion, aller au commentaire cité ; sinon ouvrir sa publication
+        const target = document.getElementById(`comment-${quote.commentId}`);
+        if (target) {
+            target.scrollIntoView({ behavior: 'smooth', block: 'center' });
+        } else {
+            openPost(quote.postId);
+        }
+    };
+
+    const excerpt = document.createElement('div');
+    excerpt.className = 'comment-quote-excerpt';
+    excerpt.textContent = quote.excerpt;
+
+    block.appendChild(source);
+    block.appendChild(excerpt);
+    return block;
+}
+
+// Compléter l'affichage d'un commentaire : ancre, citation, liens internes et bouton « Citer »
+// (le texte sélectionné dans le commentaire devient l'extrait cité)
+function decorateComment(commentDiv, content, comment) {
+    commentDiv.id = `comment-${comment.id}`;
+    renderLinkedContent(content, comment.content, comment.links);
+    if (comment.quote) {
+        commentDiv.insertBefore(createQuoteBlock(comment.quote), content);
+    }
+
+    let selection = '';
+    const quoteButton = document.createElement('button');
+    quoteButton.type = 'button';
+    quoteButton.className = 'comment-quote-button auth-required';
+    quoteButton.classList.toggle('hidden', !state.isAuthenticated);
+    quoteButton.textContent = 'Citer';
+    // La sélection est lue avant que le clic ne la fasse disparaître
+    quoteButton.onmousedown = () => {
+        selection = window.getSelection().toString().trim();
+    };
+    quoteButton.onclick = () => {
+        const excerpt = selection && comment.content.includes(selection) ? selection : '';
+        document.dispatchEvent(new CustomEvent('quote-comment', { detail: { comment, excerpt } }));
+    };
+    commentDiv.querySelector('.comment-header').appendChild(quoteButton);
+}
+
+// Créer la liste des discussions qui référencent une publication
+function createBacklinkList(backlinks) {
+    const list = document.createElement('div');
+    list.className = 'post-backlinks';
+    list.textContent = 'Référencée par : ';
+
+    backlinks.forEach((backlink, index) => {
+        if (index > 0) list.appendChild(document.createTextNode(', '));
+        const anchor = document.createElement('a');
+        anchor.href = `/posts/${backlink.postId}`;
+        anchor.textContent = backlink.title;
+        anchor.onclick = (e) => {
+            e.preventDefault();
+            openPost(backlink.postId);
+        };
+        list.appendChild(anchor);
+    });
+    return list;
+}
+
+// Masquer le formulaire de commentaire d'une publication verrouillée
+function updateCommentsLock(post) {
+    const commentsContainer = document.getElementById('comments-container');
+    if (commentsContainer) {
+        commentsContainer.classList.toggle('locked', Boolean(post && post.locked));
+    }
+}
+
+// Créer les badges d'état d'une publication (épinglée, verrouillée, annonce)
+function createPostBadges(post) {
+    const badges = document.createElement('span');
+    badges.className = 'post-badges';
+    if (post.announcement) badges.appendChild(document.createTextNode('📢 Annonce'));
+    if (post.pinned) badges.appendChild(document.createTextNode('📌 Épinglée'));
+    if (post.locked) badges.appendChild(document.createTextNode('🔒 Verrouillée'));
+    if (post.poll) badges.appendChild(document.createTextNode('📊 Sondage'));
+    return badges;
+}
+
+// Créer l'indicateur de lecture d'une publication (nouvelle, commentaires non lus)
+function createReadStatus(post) {
+    const status = document.createElement('span');
+    status.className = 'post-read-status';
+    if (post.new) {
+        status.textContent = 'Nouveau';
+    } else if (post.unreadComments > 0) {
+        status.textContent = `${post.unreadComments} nouveau${post.unreadComments > 1 ? 'x' : ''} commentaire${post.unreadComments > 1 ? 's' : ''}`;
+    } else {
+        status.classList.add('hidden');
+    }
+    return status;
+}
+
+// Marquer la publication courante comme lue jusqu'au commentaire donné
+async function markCommentRead(postId, commentId) {
+    try {
+        await fetch(`/api/posts/${postId}/read`, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+                ...csrfHeaders()
+            },
+            body: JSON.stringify({ lastCommentId: commentId })
+        });
+    } catch (error) {
+        console.error('Erreur lors du marquage comme lu:', error);
+    }
+}
+
 // Gestion des nouveaux commentaires
 function handleNewComment(comment) {
     // Vérifier si c'est pour la publication courante
@@ -312,10 +683,17 @@ function handleNewComment(comment) {
 
         header.appendChild(author);
         header.appendChild(date);
+        header.appendChild(createBookmarkButton('comment', comment));
         commentDiv.appendChild(header);
         commentDiv.appendChild(content);
+        decorateComment(commentDiv, content, comment);
 
         commentsList.appendChild(commentDiv);
+
+        // Le commentaire est affiché : il est lu
+        if (state.isAuthenticated) {
+            markCommentRead(comment.postId, comment.id);
+        }
     }
 }
 
@@ -356,6 +734,9 @@ function updateUrl(page, data) {
         case 'categories':
             url = '/categories';
             break;
+        case 'bookmarks':
+            url = '/bookmarks';
+            break;
         case 'messages':
             if (data && data.user) {
                 url = `/messages/${data.user.id}`;
@@ -382,6 +763,9 @@ function loadPageData(page, data) {
         case 'categories':
             fetchCategories();
             break;
+        case 'bookmarks':
+            fetchBookmarks();
+            break;
         case 'messages':
             if (data && data.user) {
                 fetchMessages(data.user.id);
@@ -390,6 +774,7 @@ function loadPageData(page, data) {
             break;
         case 'post-detail':
             if (data) {
+                refreshPostViews(data.id);
                 fetchComments(data.id);
             }
             break;
@@ -398,6 +783,15 @@ function loadPageData(page, data) {
 
 // Gestion de la navigation initiale
 function handleInitialNavigation() {
+    // Résultat de la vérification d'une nouvelle adresse email (lien reçu par email)
+    const params = new URLSearchParams(window.location.search);
+    if (params.has('emailVerified')) {
+        alert(params.get('emailVerified') === '1'
+            ? 'Votre nouvelle adresse email a été confirmée.'
+            : 'Lien de vérification invalide ou expiré.');
+        window.history.replaceState({}, '', window.location.pathname);
+    }
+
     // Récupérer la page à partir de l'URL
     const path = window.location.pathname;
     let page = 'home';
@@ -405,6 +799,8 @@ function handleInitialNavigation() {
 
     if (path === '/categories') {
         page = 'categories';
+    } else if (path === '/bookmarks') {
+        page = 'bookmarks';
     } else if (path.startsWith('/messages')) {
         page = 'messages';
         const userId = path.split('/')[2];
@@ -434,31 +830,8 @@ function handleInitialNavigation() {
 }
 
 // Récupération des publications
-async function fetchPosts() {
-    try {
-        const postsContainer = document.getElementById('posts-container');
-        if (postsContainer) {
-            postsContainer.innerHTML = '<div class="loading">Chargement des publications...</div>';
-        }
-
-        const response = await fetch('/api/posts');
-        if (!response.ok) {
-            throw new Error(`Erreur HTTP: ${response.status}`);
-        }
-
-        const posts = await response.json();
-
-        updateAppState({ posts });
-        updatePostsList();
-    } catch (error) {
-        console.error('Erreur lors de la récupération des publications:', error);
-
-        // Afficher un message d'erreur à l'utilisateur
-        const postsContainer = document.getElementById('posts-container');
-        if (postsContainer) {
-            postsContainer.innerHTML = '<div class="error">Erreur lors du chargement des publications. Veuillez réessayer.</div>';
-        }
-    }
+function fetchPosts() {
+    return fetchFilteredPosts('');
 }
 
 // Récupération des catégories
@@ -589,6 +962,18 @@ async function fetchPostById(postId) {
     }
 }
 
+// Ouvrir une publication compte une vue : relire son nombre de vues
+async function refreshPostViews(postId) {
+    const post = await fetchPostById(postId);
+    if (!post || !state.currentPost || state.currentPost.id !== post.id) return;
+
+    state.currentPost.viewCount = post.viewCount;
+    const views = document.querySelector('#post-detail .post-views');
+    if (views) {
+        views.textContent = `👁 ${post.viewCount} vue${post.viewCount > 1 ? 's' : ''}`;
+    }
+}
+
 // Récupération d'un utilisateur par ID
 async function fetchUserById(userId) {
     try {
@@ -612,28 +997,93 @@ async function fetchUserById(userId) {
 }
 
 // Fonction pour récupérer les publications par catégorie
-async function fetchPostsByCategory(categoryId) {
+function fetchPostsByCategory(categoryId) {
+    return fetchFilteredPosts(`category=${categoryId}`);
+}
+
+// Paramètres de tri choisis dans la page d'accueil
+function postsSortQuery() {
+    const sort = document.getElementById('posts-sort');
+    const period = document.getElementById('posts-period');
+    if (!sort) return '';
+
+    const params = new URLSearchParams({ sort: sort.value });
+    if (sort.value === 'top' && period) {
+        params.set('period', period.value);
+    }
+    return params.toString();
+}
+
+// Recharger la liste des publications, avec son filtre courant, lorsque le tri change,
+// et charger la page suivante à la demande
+function setupPostsSort() {
+    const sort = document.getElementById('posts-sort');
+    const period = document.getElementById('posts-period');
+    const moreButton = document.getElementById('posts-more');
+    if (!sort || !period) return;
+
+    const reload = () => {
+        period.classList.toggle('hidden', sort.value !== 'top');
+        fetchFilteredPosts(state.postsFilter);
+    };
+    sort.addEventListener('change', reload);
+    period.addEventListener('change', reload);
+
+    if (moreButton) {
+        moreButton.addEventListener('click', () => fetchFilteredPosts(state.postsFilter, true));
+    }
+}
+
+// Fonction pour récupérer les publications portant une étiquette
+function fetchPostsByTag(tag) {
+    return fetchFilteredPosts(`tags=${encodeURIComponent(tag)}`);
+}
+
+// Récupérer les publications correspondant aux paramètres de filtre donnés ;
+// append ajoute la page suivante à la liste au lieu de la remplacer
+async function fetchFilteredPosts(query, append = false) {
+    const postsContainer = document.getElementById('posts-container');
+    const moreButton = document.getElementById('posts-more');
+
     try {
-        const postsContainer = document.getElementById('posts-container');
-        if (postsContainer) {
+        if (postsContainer && !append) {
             postsContainer.innerHTML = '<div class="loading">Chargement des publications...</div>';
         }
 
-        const response = await fetch(`/api/posts?category=${categoryId}`);
+        const offset = append ? state.postsOffset : 0;
+        const params = new URLSearchParams(query);
+        new URLSearchParams(postsSortQuery()).forEach((value, key) => params.set(key, value));
+        params.set('limit', POSTS_PAGE_SIZE);
+        params.set('offset', offset);
+
+        const response = await fetch(`/api/posts?${params}`);
         if (!response.ok) {
             throw new Error(`Erreur HTTP: ${response.status}`);
         }
 
-        const posts = await response.json();
+        const page = await response.json();
+
+        // Les publications épinglées en tête de la première page ne comptent pas dans la pagination
+        const headScopes = params.has('category') ? ['global', 'category'] : ['global'];
+        const pinnedCount = offset === 0 ? page.filter(post => headScopes.includes(post.pinned)).length : 0;
+        const loaded = page.length - pinnedCount;
+
+        // Une publication reçue en temps réel entre deux pages peut apparaître deux fois
+        const posts = append
+            ? [...state.posts, ...page.filter(post => !state.posts.some(existing => existing.id === post.id))]
+            : page;
 
-        updateAppState({ posts });
+        updateAppState({ posts, postsFilter: query, postsOffset: offset + loaded });
         updatePostsList();
+
+        if (moreButton) {
+            moreButton.style.display = loaded === POSTS_PAGE_SIZE ? 'block' : 'none';
+        }
     } catch (error) {
-        console.error('Erreur lors de la récupération des publications par catégorie:', error);
+        console.error('Erreur lors de la récupération des publications:', error);
 
         // Afficher un message d'erreur à l'utilisateur
-        const postsContainer = document.getElementById('posts-container');
-        if (postsContainer) {
+        if (postsContainer && !append) {
             postsContainer.innerHTML = '<div class="error">Erreur lors du chargement des publications. Veuillez réessayer.</div>';
         }
     }
@@ -654,6 +1104,7 @@ function updatePostsList() {
     state.posts.forEach(post => {
         const postCard = document.createElement('div');
         postCard.className = 'post-card';
+        if (post.announcement) postCard.classList.add('announcement');
         postCard.onclick = () => navigateTo('post-detail', post);
 
         const header = document.createElement('div');
@@ -664,6 +1115,9 @@ function updatePostsList() {
         const title = document.createElement('div');
         title.className = 'post-title';
         title.textContent = post.title;
+        title.appendChild(createPostBadges(post));
+        title.appendChild(createReadStatus(post));
+        title.prepend(createVoteControl(post));
 
         const category = document.createElement('div');
         category.className = 'post-category';
@@ -688,8 +1142,16 @@ function updatePostsList() {
 
         titleDiv.appendChild(title);
         titleDiv.appendChild(category);
+        if (post.tags) {
+            titleDiv.appendChild(createTagList(post.tags));
+        }
+        const views = document.createElement('div');
+        views.className = 'post-views';
+        views.textContent = `👁 ${post.viewCount} vue${post.viewCount > 1 ? 's' : ''}`;
+
         authorDiv.appendChild(author);
         authorDiv.appendChild(date);
+        authorDiv.appendChild(views);
         header.appendChild(titleDiv);
         header.appendChild(authorDiv);
         postCard.appendChild(header);
@@ -711,9 +1173,11 @@ function updateCategoriesList() {
 
     categoriesContainer.innerHTML = '';
 
-    state.categories.forEach(category => {
+    sortCategoryTree(state.categories).forEach(category => {
         const categoryCard = document.createElement('div');
         categoryCard.className = 'category-card';
+        if (category.parentId) categoryCard.classList.add('subcategory');
+        if (category.archived) categoryCard.classList.add('archived');
 
         // Corriger le comportement de clic sur une catégorie
         categoryCard.onclick = (e) => {
@@ -735,14 +1199,46 @@ function updateCategoriesList() {
 
         const name = document.createElement('div');
         name.className = 'category-name';
-        name.textContent = category.name;
+        name.textContent = category.icon ? `${category.icon} ${category.name}` : category.name;
+        if (category.archived) {
+            const badge = document.createElement('span');
+            badge.className = 'category-archived';
+            badge.textContent = 'Archivée';
+            name.appendChild(badge);
+        }
 
         const description = document.createElement('div');
         description.className = 'category-description';
         description.textContent = category.description;
 
+        const stats = document.createElement('div');
+        stats.className = 'category-stats';
+        stats.textContent = `${category.postCount} publication${category.postCount > 1 ? 's' : ''}`;
+        if (category.lastActivityAt) {
+            stats.textContent += ` · dernière activité ${new Date(category.lastActivityAt).toLocaleString()}`;
+        }
+
+        // Marquer toutes les publications de la catégorie comme lues
+        const markRead = document.createElement('button');
+        markRead.className = 'category-mark-read auth-required';
+        markRead.classList.toggle('hidden', !state.isAuthenticated);
+        markRead.textContent = 'Tout marquer comme lu';
+        markRead.onclick = async (e) => {
+            e.stopPropagation();
+            const response = await fetch(`/api/categories/${category.id}/read`, {
+                method: 'POST',
+                headers: csrfHeaders()
+            });
+            if (response.ok) {
+                markRead.textContent = 'Marquée comme lue';
+                markRead.disabled = true;
+            }
+        };
+
         categoryCard.appendChild(name);
         categoryCard.appendChild(description);
+        categoryCard.appendChild(stats);
+        categoryCard.appendChild(markRead);
 
         categoriesContainer.appendChild(categoryCard);
     });
@@ -751,16 +1247,30 @@ function updateCategoriesList() {
     const postCategorySelect = document.getElementById('post-category');
     if (postCategorySelect) {
         postCategorySelect.innerHTML = '';
-
-        state.categories.forEach(category => {
-            const option = document.createElement('option');
-            option.value = category.id;
-            option.textContent = category.name;
-            postCategorySelect.appendChild(option);
-        });
+        fillCategorySelect(postCategorySelect, state.categories);
     }
 }
 
+// Ordonner les catégories en plaçant chaque sous-catégorie sous sa catégorie principale
+function sortCategoryTree(categories) {
+    const sorted = [];
+    categories.filter(category => !category.parentId).forEach(parent => {
+        sorted.push(parent);
+        categories.filter(category => category.parentId === parent.id).forEach(child => sorted.push(child));
+    });
+    return sorted;
+}
+
+// Remplir un select avec les catégories qui acceptent encore des publications
+function fillCategorySelect(select, categories) {
+    sortCategoryTree(categories).filter(category => !category.archived).forEach(category => {
+        const option = document.createElement('option');
+        option.value = category.id;
+        option.textContent = category.parentId ? `— ${category.name}` : category.name;
+        select.appendChild(option);
+    });
+}
+
 // Mise à jour de la liste des commentaires
 function updateCommentsList(comments) {
     const commentsList = document.getElementById('comments-list');
@@ -794,8 +1304,10 @@ function updateCommentsList(comments) {
 
         header.appendChild(author);
         header.appendChild(date);
+        header.appendChild(createBookmarkButton('comment', comment));
         commentDiv.appendChild(header);
         commentDiv.appendChild(content);
+        decorateComment(commentDiv, content, comment);
 
         commentsList.appendChild(commentDiv);
     });
@@ -828,6 +1340,9 @@ function updateMessagesList(messages) {
         time.textContent = new Date(message.createdAt).toLocaleTimeString();
 
         messageDiv.appendChild(content);
+        if (message.attachments) {
+            messageDiv.appendChild(createAttachmentList(message.attachments));
+        }
         messageDiv.appendChild(time);
 
         messagesList.appendChild(messageDiv);
@@ -837,6 +1352,88 @@ function updateMessagesList(messages) {
     messagesList.scrollTop = messagesList.scrollHeight;
 }
 
+// Récupérer les étiquettes les plus utilisées et les afficher sur la page d'accueil
+async function fetchPopularTags() {
+    const container = document.getElementById('popular-tags');
+    if (!container) return;
+
+    try {
+        const response = await fetch('/api/tags?limit=15');
+        if (!response.ok) {
+            throw new Error(`Erreur HTTP: ${response.status}`);
+        }
+
+        const tags = await response.json();
+        container.innerHTML = '';
+        if (tags.length > 0) {
+            container.appendChild(createTagList(tags.map(tag => tag.name), tags.map(tag => tag.postCount)));
+        }
+    } catch (error) {
+        console.error('Erreur lors de la récupération des étiquettes:', error);
+    }
+}
+
+// Créer la liste des étiquettes ; un clic filtre les publications sur l'étiquette
+function createTagList(tags, counts) {
+    const list = document.createElement('div');
+    list.className = 'tag-list';
+
+    tags.forEach((tag, index) => {
+        const chip = document.createElement('a');
+        chip.href = '#';
+        chip.className = 'tag';
+        chip.textContent = counts ? `#${tag} (${counts[index]})` : `#${tag}`;
+        chip.onclick = (e) => {
+            e.preventDefault();
+            e.stopPropagation();
+            navigateTo('home');
+            fetchPostsByTag(tag);
+        };
+        list.appendChild(chip);
+    });
+
+    return list;
+}
+
+// Créer la liste des pièces jointes d'une publication ou d'un message
+// (miniature cliquable pour les images, lien de téléchargement sinon)
+function createAttachmentList(attachments) {
+    const list = document.createElement('div');
+    list.className = 'attachments';
+
+    attachments.forEach(attachment => {
+        const link = document.createElement('a');
+        link.className = 'attachment';
+        link.href = attachment.url;
+        link.target = '_blank';
+        link.rel = 'noopener';
+
+        if (attachment.thumbnailUrl) {
+            const img = document.createElement('img');
+            img.src = attachment.thumbnailUrl;
+            img.alt = attachment.filename;
+            link.appendChild(img);
+        } else {
+            link.textContent = `${attachment.filename} (${Math.ceil(attachment.size / 1024)} Ko)`;
+        }
+
+        list.appendChild(link);
+    });
+
+    return list;
+}
+
+// Créer la miniature de l'avatar d'un utilisateur
+function createAvatarImage(user) {
+    const img = document.createElement('img');
+    img.className = 'avatar';
+    img.src = user.avatar.thumbnailUrl;
+    img.alt = '';
+    img.width = 24;
+    img.height = 24;
+    return img;
+}
+
 // Mise à jour de la liste des utilisateurs en ligne
 function updateOnlineUsersList() {
     const onlineUsers = document.getElementById('online-users');
@@ -878,6 +1475,9 @@ function updateOnlineUsersList() {
             username.textContent = user.username;
 
             li.appendChild(status);
+            if (user.avatar) {
+                li.appendChild(createAvatarImage(user));
+            }
             li.appendChild(username);
 
             onlineUsers.appendChild(li);
@@ -909,6 +1509,9 @@ function updateOnlineUsersList() {
             username.textContent = user.username;
 
             li.appendChild(status);
+            if (user.avatar) {
+                li.appendChild(createAvatarImage(user));
+            }
             li.appendChild(username);
 
             usersList.appendChild(li);
@@ -932,4 +1535,4 @@ window.addEventListener('popstate', (event) => {
 });
 
 // Exporter les fonctions et l'état pour les autres modules
-export { state, updateAppState, navigateTo, fetchCategories, fetchPosts, updatePostsList, updateOnlineUsersList };
\ No newline at end of file
+export { state, updateAppState, navigateTo, fetchCategories, fillCategorySelect, fetchPosts, fetchPopularTags, updatePostsList, updateOnlineUsersList, createAttachmentList, createTagList, createPostBadges, createVoteControl, renderLinkedContent, decorateComment, createBacklinkList, updateCommentsLock };
\ No newline at end of file
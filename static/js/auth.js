@@ -3,23 +3,19 @@ export async function initAuth(state, updateAppState) {
     try {
         console.log("Vérification de l'authentification...");
 
-        // Vérifier si l'utilisateur est déjà connecté
-        if (isAuthenticated()) {
-            console.log("Token de session trouvé dans localStorage, tentative de récupération de l'utilisateur");
-            const user = await getCurrentUser();
-            if (user) {
-                console.log("Utilisateur authentifié:", user.username);
-                updateAppState({
-                    currentUser: user,
-                    isAuthenticated: true
-                });
-            } else {
-                console.log("Token de session présent mais utilisateur non trouvé");
-                // Supprimer le token obsolète
-                localStorage.removeItem('session_id');
-            }
+        // Vérifier si l'utilisateur est déjà connecté (cookie de session HttpOnly)
+        const user = await getCurrentUser();
+        if (user) {
+            console.log("Utilisateur authentifié:", user.username);
+            await refreshCsrfToken();
+            updateAppState({
+                currentUser: user,
+                isAuthenticated: true
+            });
         } else {
-            console.log("Aucun token de session trouvé dans localStorage");
+            console.log("Aucune session active");
+            // Supprimer le jeton anti-CSRF obsolète
+            localStorage.removeItem('csrf_token');
         }
 
         // Configurer les événements pour les formulaires d'authentification
@@ -29,33 +25,36 @@ export async function initAuth(state, updateAppState) {
     }
 }
 
-// Vérifier si l'utilisateur est authentifié
-export function isAuthenticated() {
-    return localStorage.getItem('session_id') !== null;
+// En-têtes anti-CSRF à joindre à toute requête modifiant l'état
+export function csrfHeaders() {
+    const token = localStorage.getItem('csrf_token');
+    return token ? { 'X-CSRF-Token': token } : {};
+}
+
+// Récupérer le jeton anti-CSRF de la session courante (après un rechargement de page)
+async function refreshCsrfToken() {
+    try {
+        const response = await fetch('/api/csrf-token');
+        if (response.ok) {
+            const data = await response.json();
+            localStorage.setItem('csrf_token', data.csrfToken);
+        }
+    } catch (error) {
+        console.error('Erreur lors de la récupération du jeton CSRF:', error);
+    }
 }
 
 // Récupérer l'utilisateur actuel
 export async function getCurrentUser() {
     try {
         console.log("Tentative de récupération de l'utilisateur actuel...");
-        const sessionId = localStorage.getItem('session_id');
-
-        if (!sessionId) {
-            console.log("Aucun token de session trouvé");
-            return null;
-        }
 
-        const response = await fetch('/api/me', {
-            headers: {
-                'Authorization': `Bearer ${sessionId}`
-            }
-        });
+        // Le cookie de session est envoyé automatiquement par le navigateur
+        const response = await fetch('/api/me');
 
         if (!response.ok) {
             if (response.status === 401) {
                 console.log("L'utilisateur n'est pas authentifié");
-                // Nettoyer le localStorage si le token n'est plus valide
-                localStorage.removeItem('session_id');
             } else {
                 console.error("Erreur lors de la récupération de l'utilisateur:", response.status);
             }
@@ -90,6 +89,35 @@ async function parseResponseError(response) {
     }
 }
 
+// Finaliser une connexion nécessitant un second facteur
+async function completeTwoFactorLogin(challengeToken) {
+    const input = prompt("Code de l'application d'authentification (ou code de récupération) :");
+    if (!input) {
+        throw new Error("Connexion annulée");
+    }
+
+    // Un code de récupération contient un tiret, un code TOTP uniquement des chiffres
+    const value = input.trim();
+    const body = value.includes('-')
+        ? { challengeToken, recoveryCode: value }
+        : { challengeToken, code: value };
+
+    const response = await fetch('/api/login/2fa', {
+        method: 'POST',
+        headers: {
+            'Content-Type': 'application/json'
+        },
+        body: JSON.stringify(body)
+    });
+
+    if (!response.ok) {
+        const errorMsg = await parseResponseError(response);
+        throw new Error(errorMsg);
+    }
+
+    return await response.json();
+}
+
 // Configurer les formulaires d'authentification
 function setupAuthForms(updateAppState) {
     // Formulaire de connexion
@@ -120,11 +148,16 @@ function setupAuthForms(updateAppState) {
                     throw new Error(errorMsg);
                 }
 
-                const data = await response.json();
+                let data = await response.json();
+
+                // Second facteur requis : demander le code TOTP (ou un code de récupération)
+                if (data.twoFactorRequired) {
+                    data = await completeTwoFactorLogin(data.challengeToken);
+                }
                 console.log("Connexion réussie pour:", data.user.username);
 
-                // Stocker le token dans localStorage
-                localStorage.setItem('session_id', data.sessionId);
+                // Stocker le jeton anti-CSRF (la session reste dans le cookie HttpOnly)
+                localStorage.setItem('csrf_token', data.csrfToken);
 
                 // Mettre à jour l'état
                 updateAppState({
@@ -192,13 +225,8 @@ function setupAuthForms(updateAppState) {
                 const data = await response.json();
                 console.log("Inscription réussie pour:", data.username);
 
-                // Récupérer l'ID de session de la réponse ou des en-têtes
-                const sessionId = response.headers.get('X-Session-ID') || data.sessionId;
-                if (sessionId) {
-                    localStorage.setItem('session_id', sessionId);
-                } else {
-                    console.warn("Pas d'ID de session trouvé dans la réponse");
-                }
+                // Stocker le jeton anti-CSRF (la session reste dans le cookie HttpOnly)
+                localStorage.setItem('csrf_token', data.csrfToken);
 
                 // Mettre à jour l'état
                 updateAppState({
@@ -234,13 +262,9 @@ function setupAuthForms(updateAppState) {
         logoutButton.addEventListener('click', async () => {
             try {
                 console.log("Tentative de déconnexion");
-                const sessionId = localStorage.getItem('session_id');
-
                 const response = await fetch('/api/logout', {
                     method: 'POST',
-                    headers: {
-                        'Authorization': `Bearer ${sessionId}`
-                    }
+                    headers: csrfHeaders()
                 });
 
                 if (!response.ok) {
@@ -250,8 +274,8 @@ function setupAuthForms(updateAppState) {
 
                 console.log("Déconnexion réussie");
 
-                // Supprimer le token du localStorage
-                localStorage.removeItem('session_id');
+                // Supprimer le jeton anti-CSRF du localStorage
+                localStorage.removeItem('csrf_token');
 
                 // Mettre à jour l'état
                 updateAppState({
@@ -0,0 +1,216 @@
+import { state, navigateTo } from './app.js';
+import { csrfHeaders } from './auth.js';
+
+// Nombre de favoris chargés à la fois
+const PAGE_SIZE = 20;
+
+// Pagination de la liste des favoris
+let offset = 0;
+
+// Initialiser la page des favoris
+export function initBookmarks() {
+    const typeSelect = document.getElementById('bookmarks-type');
+    const folderSelect = document.getElementById('bookmarks-folder');
+    const moreButton = document.getElementById('bookmarks-more');
+
+    if (typeSelect) typeSelect.addEventListener('change', () => fetchBookmarks());
+    if (folderSelect) folderSelect.addEventListener('change', () => fetchBookmarks());
+    if (moreButton) moreButton.addEventListener('click', () => fetchBookmarks(true));
+}
+
+// Créer le bouton d'ajout ou de retrait des favoris d'une publication ou d'un commentaire ;
+// le favori est lu et mis à jour dans item.bookmark
+export function createBookmarkButton(targetType, item) {
+    const button = document.createElement('button');
+    button.type = 'button';
+    button.className = 'bookmark-toggle auth-required';
+    button.classList.toggle('hidden', !state.isAuthenticated);
+
+    const render = () => {
+        button.textContent = item.bookmark ? '★' : '☆';
+        button.title = item.bookmark ? 'Retirer des favoris' : 'Ajouter aux favoris';
+        button.classList.toggle('active', Boolean(item.bookmark));
+    };
+    render();
+
+    button.onclick = async (e) => {
+        e.stopPropagation();
+        if (!state.isAuthenticated) return;
+
+        try {
+            const response = item.bookmark
+                ? await fetch(`/api/me/bookmarks/${item.bookmark.id}`, {
+                    method: 'DELETE',
+                    headers: csrfHeaders()
+                })
+                : await fetch('/api/me/bookmarks', {
+                    method: 'POST',
+                    headers: {
+                        'Content-Type': 'application/json',
+                        ...csrfHeaders()
+                    },
+                    body: JSON.stringify({ targetType, targetId: item.id })
+                });
+
+            if (!response.ok) {
+                throw new Error(await response.text());
+            }
+
+            item.bookmark = response.status === 204 ? undefined : await response.json();
+            render();
+        } catch (error) {
+            console.error('Erreur lors de la mise à jour des favoris:', error);
+            alert('Erreur lors de la mise à jour des favoris: ' + error.message);
+        }
+    };
+
+    return button;
+}
+
+// Charger les favoris selon les filtres ; append ajoute la page suivante à la liste
+export async function fetchBookmarks(append = false) {
+    const list = document.getElementById('bookmarks-list');
+    const moreButton = document.getElementById('bookmarks-more');
+    if (!list) return;
+
+    if (!append) {
+        offset = 0;
+        list.innerHTML = '<div class="loading">Chargement des favoris...</div>';
+        fetchFolders();
+    }
+
+    const params = new URLSearchParams({ limit: PAGE_SIZE, offset });
+    const type = document.getElementById('bookmarks-type').value;
+    const folder = document.getElementById('bookmarks-folder').value;
+    if (type) params.set('type', type);
+    if (folder !== '*') params.set('folder', folder);
+
+    try {
+        const response = await fetch(`/api/me/bookmarks?${params}`);
+        if (!response.ok) {
+            throw new Error(`Erreur HTTP: ${response.status}`);
+        }
+
+        const bookmarks = await response.json();
+        if (!append) {
+            list.innerHTML = bookmarks.length === 0 ? '<div class="empty">Aucun favori.</div>' : '';
+        }
+        bookmarks.forEach(bookmark => list.appendChild(createBookmarkItem(bookmark)));
+
+        offset += bookmarks.length;
+        if (moreButton) {
+            moreButton.style.display = bookmarks.length === PAGE_SIZE ? 'block' : 'none';
+        }
+    } catch (error) {
+        console.error('Erreur lors de la récupération des favoris:', error);
+        list.innerHTML = '<div class="error">Erreur lors du chargement des favoris.</div>';
+    }
+}
+
+// Remplir la liste des dossiers en conservant le dossier sélectionné
+async function fetchFolders() {
+    const folderSelect = document.getElementById('bookmarks-folder');
+    if (!folderSelect) return;
+
+    try {
+        const response = await fetch('/api/me/bookmarks/folders');
+        if (!response.ok) return;
+
+        const folders = await response.json();
+        const selected = folderSelect.value;
+        folderSelect.innerHTML = '<option value="*">Tous les dossiers</option>';
+        folders.forEach(folder => {
+            const option = document.createElement('option');
+            option.value = folder.name;
+            option.textContent = `${folder.name || 'Sans dossier'} (${folder.count})`;
+            folderSelect.appendChild(option);
+        });
+        folderSelect.value = [...folderSelect.options].some(option => option.value === selected) ? selected : '*';
+    } catch (error) {
+        console.error('Erreur lors de la récupération des dossiers de favoris:', error);
+    }
+}
+
+// Créer l'élément d'un favori : aperçu du contenu, note et dossier modifiables, retrait
+function createBookmarkItem(bookmark) {
+    const item = document.createElement('div');
+    item.className = 'bookmark-item';
+
+    const labels = { post: 'Publication', comment: 'Commentaire', message: 'Message privé' };
+    const preview = bookmark.preview;
+
+    const header = document.createElement('div');
+    header.className = 'bookmark-header';
+    header.textContent = preview.available
+        ? `${labels[bookmark.targetType]} de ${preview.author}${preview.title ? ` · ${preview.title}` : ''}`
+        : `${labels[bookmark.targetType]} indisponible`;
+    if (preview.postId) {
+        header.classList.add('clickable');
+        header.onclick = async () => {
+            const response = await fetch(`/api/posts/${preview.postId}`);
+            if (response.ok) {
+                navigateTo('post-detail', await response.json());
+            }
+        };
+    }
+    item.appendChild(header);
+
+    if (preview.excerpt) {
+        const excerpt = document.createElement('div');
+        excerpt.className = 'bookmark-excerpt';
+        excerpt.textContent = preview.excerpt;
+        item.appendChild(excerpt);
+    }
+
+    const note = document.createElement('input');
+    note.type = 'text';
+    note.placeholder = 'Note';
+    note.value = bookmark.note;
+
+    const folder = document.createElement('input');
+    folder.type = 'text';
+    folder.placeholder = 'Dossier';
+    folder.value = bookmark.folder;
+
+    const save = document.createElement('button');
+    save.textContent = 'Enregistrer';
+    save.onclick = async () => {
+        const response = await fetch(`/api/me/bookmarks/${bookmark.id}`, {
+            method: 'PUT',
+            headers: {
+                'Content-Type': 'application/json',
+                ...csrfHeaders()
+            },
+            body: JSON.stringify({ note: note.value, folder: folder.value })
+        });
+        if (!response.ok) {
+            alert('Erreur lors de la modification du favori: ' + await response.text());
+            return;
+        }
+        fetchFolders();
+    };
+
+    const remove = document.createElement('button');
+    remove.textContent = 'Retirer';
+    remove.onclick = async () => {
+        const response = await fetch(`/api/me/bookmarks/${bookmark.id}`, {
+            method: 'DELETE',
+            headers: csrfHeaders()
+        });
+        if (response.ok) {
+            item.remove();
+            offset--;
+            fetchFolders();
+        }
+    };
+
+    const actions = document.createElement('div');
+    actions.className = 'bookmark-actions';
+    actions.appendChild(note);
+    actions.appendChild(folder);
+    actions.appendChild(save);
+    actions.appendChild(remove);
+    item.appendChild(actions);
+
+    return item;
+}
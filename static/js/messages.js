@@ -1,3 +1,6 @@
+import { csrfHeaders } from './auth.js';
+import { createAttachmentList } from './app.js';
+
 // Variables pour l'indicateur de frappe
 let typingTimer;
 let isTyping = false;
@@ -95,7 +98,8 @@ async function sendMessage(state) {
             const response = await fetch('/api/messages', {
                 method: 'POST',
                 headers: {
-                    'Content-Type': 'application/json'
+                    'Content-Type': 'application/json',
+                    ...csrfHeaders()
                 },
                 body: JSON.stringify(messageData)
             });
@@ -123,6 +127,9 @@ async function sendMessage(state) {
             time.textContent = new Date(message.createdAt).toLocaleTimeString();
 
             messageDiv.appendChild(content);
+            if (message.attachments) {
+                messageDiv.appendChild(createAttachmentList(message.attachments));
+            }
             messageDiv.appendChild(time);
 
             // Supprimer le message "Aucun message" s'il existe
@@ -207,7 +214,8 @@ function sendTypingIndicator(state, isTyping) {
         fetch('/api/typing', {
             method: 'POST',
             headers: {
-                'Content-Type': 'application/json'
+                'Content-Type': 'application/json',
+                ...csrfHeaders()
             },
             body: JSON.stringify(typingData)
         }).catch(error => {
@@ -1,4 +1,6 @@
-import { fetchCategories, updatePostsList } from './app.js';
+import { state as appState, fetchCategories, fillCategorySelect, fetchPopularTags, updatePostsList, createAttachmentList, createTagList, createPostBadges, createVoteControl, renderLinkedContent, decorateComment, createBacklinkList, updateCommentsLock } from './app.js';
+import { csrfHeaders } from './auth.js';
+import { createBookmarkButton } from './bookmarks.js';
 
 // Initialiser le module des publications
 export function initPosts(state, updateAppState) {
@@ -24,17 +26,18 @@ function setupNewPostForm(state, updateAppState) {
     // Charger les catégories dans le formulaire si elles ne sont pas déjà chargées
     const postCategorySelect = document.getElementById('post-category');
     if (postCategorySelect && postCategorySelect.options.length === 0 && state.categories.length > 0) {
-        state.categories.forEach(category => {
-            const option = document.createElement('option');
-            option.value = category.id;
-            option.textContent = category.name;
-            postCategorySelect.appendChild(option);
-        });
+        fillCategorySelect(postCategorySelect, state.categories);
     } else if (postCategorySelect && postCategorySelect.options.length === 0) {
         // Si les catégories ne sont pas encore chargées, les charger
         fetchCategories();
     }
 
+    // Proposer des étiquettes existantes pendant la saisie
+    setupTagAutocomplete();
+
+    // Enregistrer automatiquement la saisie comme brouillon et lister les brouillons existants
+    const drafts = setupDrafts(state, newPostForm);
+
     // Soumettre le formulaire
     newPostForm.addEventListener('submit', async (e) => {
         e.preventDefault();
@@ -45,19 +48,35 @@ function setupNewPostForm(state, updateAppState) {
             return;
         }
 
-        const postData = {
-            title: document.getElementById('post-title').value,
-            content: document.getElementById('post-content').value,
-            categoryId: parseInt(document.getElementById('post-category').value)
-        };
+        // Une date de publication programme le brouillon au lieu de publier
+        const publishAt = document.getElementById('post-publish-at').value;
+        if (publishAt) {
+            try {
+                const draft = await drafts.save(new Date(publishAt).toISOString());
+                alert(`Publication programmée pour le ${new Date(draft.publishAt).toLocaleString()}`);
+                drafts.reset();
+                document.getElementById('new-post-modal').style.display = 'none';
+            } catch (error) {
+                console.error('Erreur lors de la programmation de la publication:', error);
+                alert('Erreur lors de la programmation de la publication: ' + error.message);
+            }
+            return;
+        }
 
         try {
-            const response = await fetch('/api/posts', {
+            // Publier le brouillon en cours s'il existe (après y avoir enregistré la saisie),
+            // pour qu'il disparaisse de la liste
+            const draftId = await drafts.settle();
+            if (draftId) {
+                await drafts.save();
+            }
+            const response = await fetch(draftId ? `/api/drafts/${draftId}/publish` : '/api/posts', {
                 method: 'POST',
                 headers: {
-                    'Content-Type': 'application/json'
+                    'Content-Type': 'application/json',
+                    ...csrfHeaders()
                 },
-                body: JSON.stringify(postData)
+                body: JSON.stringify(readPostForm())
             });
 
             if (!response.ok) {
@@ -77,13 +96,16 @@ function setupNewPostForm(state, updateAppState) {
                 newPostModal.style.display = 'none';
             }
 
-            // Réinitialiser le formulaire
-            newPostForm.reset();
+            // Réinitialiser le formulaire et oublier le brouillon publié
+            drafts.reset();
 
             // Si l'utilisateur est sur la page d'accueil, mettre à jour la liste des publications
             if (state.currentPage === 'home') {
                 updatePostsList();
             }
+            if (post.tags) {
+                fetchPopularTags();
+            }
 
             // Envoyer un message WebSocket pour informer les autres utilisateurs
             if (state.socket && state.socket.readyState === WebSocket.OPEN) {
@@ -100,7 +122,290 @@ function setupNewPostForm(state, updateAppState) {
     });
 }
 
+// Lire le formulaire de création de publication
+function readPostForm() {
+    const postData = {
+        title: document.getElementById('post-title').value,
+        content: document.getElementById('post-content').value,
+        categoryId: parseInt(document.getElementById('post-category').value) || 0,
+        tags: document.getElementById('post-tags').value
+            .split(',')
+            .map(tag => tag.trim())
+            .filter(tag => tag !== '')
+    };
+
+    // Joindre un sondage si une question est saisie (un choix par ligne)
+    const pollQuestion = document.getElementById('poll-question').value.trim();
+    if (pollQuestion) {
+        const closesAt = document.getElementById('poll-closes-at').value;
+        postData.newPoll = {
+            question: pollQuestion,
+            options: document.getElementById('poll-options').value
+                .split('\n')
+                .map(option => option.trim())
+                .filter(option => option !== ''),
+            multiple: document.getElementById('poll-multiple').checked,
+            anonymous: document.getElementById('poll-anonymous').checked,
+            closesAt: closesAt ? new Date(closesAt).toISOString() : null
+        };
+    }
+
+    return postData;
+}
+
+// Formater une date pour un champ datetime-local (heure locale)
+function toLocalInputValue(date) {
+    const local = new Date(date);
+    local.setMinutes(local.getMinutes() - local.getTimezoneOffset());
+    return local.toISOString().slice(0, 16);
+}
+
+// Remplir le formulaire de création de publication avec un brouillon
+function fillPostForm(draft) {
+    document.getElementById('post-title').value = draft.title;
+    document.getElementById('post-content').value = draft.content;
+    if (draft.categoryId) {
+        document.getElementById('post-category').value = draft.categoryId;
+    }
+    document.getElementById('post-tags').value = draft.tags.join(', ');
+    document.getElementById('post-publish-at').value = draft.publishAt ? toLocalInputValue(draft.publishAt) : '';
+
+    const poll = draft.poll || { question: '', options: [], multiple: false, anonymous: false };
+    document.getElementById('poll-question').value = poll.question;
+    document.getElementById('poll-options').value = poll.options.join('\n');
+    document.getElementById('poll-multiple').checked = poll.multiple;
+    document.getElementById('poll-anonymous').checked = poll.anonymous;
+    document.getElementById('poll-closes-at').value = poll.closesAt ? toLocalInputValue(poll.closesAt) : '';
+}
+
+// Configurer les brouillons du formulaire de création : sauvegarde automatique de la saisie,
+// liste des brouillons (reprendre, abandonner) et programmation
+function setupDrafts(state, newPostForm) {
+    let draftId = null;
+    let pending = null;
+    let saving = Promise.resolve();
+
+    // Enregistrer le formulaire dans le brouillon courant (créé au premier enregistrement)
+    const save = (publishAt) => {
+        saving = saving.catch(() => {}).then(async () => {
+            const postData = readPostForm();
+            const draft = {
+                title: postData.title,
+                content: postData.content,
+                categoryId: postData.categoryId,
+                tags: postData.tags,
+                poll: postData.newPoll,
+                publishAt: publishAt || null
+            };
+
+            const response = await fetch(draftId ? `/api/drafts/${draftId}` : '/api/drafts', {
+                method: draftId ? 'PUT' : 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                    ...csrfHeaders()
+                },
+                body: JSON.stringify(draft)
+            });
+
+            if (!response.ok) {
+                const error = await response.text();
+                throw new Error(error);
+            }
+
+            const saved = await response.json();
+            draftId = saved.id;
+            return saved;
+        });
+        return saving;
+    };
+
+    // Sauvegarde automatique après une pause dans la saisie
+    newPostForm.addEventListener('input', (e) => {
+        if (!state.isAuthenticated || e.target.id === 'post-publish-at') return;
+        clearTimeout(pending);
+        pending = setTimeout(() => {
+            pending = null;
+            if (!document.getElementById('post-title').value && !document.getElementById('post-content').value) return;
+            save().catch(error => console.error('Erreur lors de la sauvegarde du brouillon:', error));
+        }, 2000);
+    });
+
+    // Recharger la liste des brouillons à chaque ouverture du formulaire
+    const newPostButton = document.getElementById('new-post-button');
+    if (newPostButton) {
+        newPostButton.addEventListener('click', () => {
+            if (state.isAuthenticated) {
+                fetchDrafts();
+            }
+        });
+    }
+
+    // Afficher la liste des brouillons et publications programmées
+    async function fetchDrafts() {
+        const list = document.getElementById('drafts-list');
+        if (!list) return;
+
+        try {
+            const response = await fetch('/api/drafts');
+            if (!response.ok) {
+                throw new Error(await response.text());
+            }
+
+            const drafts = await response.json();
+            list.innerHTML = '';
+            drafts.forEach(draft => {
+                const item = document.createElement('div');
+                item.className = 'draft-item';
+                if (draft.id === draftId) {
+                    item.classList.add('current');
+                }
+
+                const title = document.createElement('span');
+                title.className = 'draft-title';
+                title.textContent = draft.title || '(sans titre)';
+
+                const meta = document.createElement('span');
+                meta.className = 'draft-meta';
+                meta.textContent = draft.publishAt
+                    ? `Programmée le ${new Date(draft.publishAt).toLocaleString()}`
+                    : `Modifié le ${new Date(draft.updatedAt).toLocaleString()}`;
+
+                const resume = document.createElement('button');
+                resume.type = 'button';
+                resume.textContent = 'Reprendre';
+                resume.onclick = () => {
+                    clearTimeout(pending);
+                    draftId = draft.id;
+                    fillPostForm(draft);
+                    fetchDrafts();
+                };
+
+                const discard = document.createElement('button');
+                discard.type = 'button';
+                discard.textContent = 'Abandonner';
+                discard.onclick = async () => {
+                    if (!confirm('Abandonner ce brouillon ?')) return;
+                    const response = await fetch(`/api/drafts/${draft.id}`, {
+                        method: 'DELETE',
+                        headers: csrfHeaders()
+                    });
+                    if (response.ok && draft.id === draftId) {
+                        reset();
+                    }
+                    fetchDrafts();
+                };
+
+                item.appendChild(title);
+                item.appendChild(meta);
+                item.appendChild(resume);
+                item.appendChild(discard);
+
+                if (draft.lastError) {
+                    const error = document.createElement('div');
+                    error.className = 'draft-error';
+                    error.textContent = `Publication programmée annulée : ${draft.lastError}`;
+                    item.appendChild(error);
+                }
+
+                list.appendChild(item);
+            });
+        } catch (error) {
+            console.error('Erreur lors de la récupération des brouillons:', error);
+        }
+    }
+
+    // Vider le formulaire et commencer un nouveau brouillon
+    function reset() {
+        clearTimeout(pending);
+        draftId = null;
+        newPostForm.reset();
+    }
+
+    return {
+        save,
+        reset,
+        // Attendre la fin des sauvegardes en cours et retourner l'ID du brouillon courant
+        settle: async () => {
+            clearTimeout(pending);
+            pending = null;
+            await saving.catch(() => {});
+            return draftId;
+        }
+    };
+}
+
+// Compléter la dernière étiquette saisie avec les étiquettes existantes
+function setupTagAutocomplete() {
+    const tagsInput = document.getElementById('post-tags');
+    const suggestions = document.getElementById('tag-suggestions');
+    if (!tagsInput || !suggestions) return;
+
+    let pending = null;
+    tagsInput.addEventListener('input', () => {
+        clearTimeout(pending);
+        pending = setTimeout(async () => {
+            const parts = tagsInput.value.split(',');
+            const current = parts.pop().trim();
+            suggestions.innerHTML = '';
+            if (current === '') return;
+
+            try {
+                const response = await fetch(`/api/tags/suggest?q=${encodeURIComponent(current)}`);
+                if (!response.ok) return;
+
+                const tags = await response.json();
+                const prefix = parts.map(part => part.trim()).filter(part => part !== '');
+                tags.forEach(tag => {
+                    const option = document.createElement('option');
+                    option.value = [...prefix, tag.name].join(', ');
+                    option.label = tag.curated ? `#${tag.name} (officielle)` : `#${tag.name} (${tag.postCount})`;
+                    suggestions.appendChild(option);
+                });
+            } catch (error) {
+                console.error('Erreur lors de la recherche d\'étiquettes:', error);
+            }
+        }, 200);
+    });
+}
+
 // Configurer le formulaire de commentaire
+// Citation en attente dans le formulaire de commentaire, choisie via le bouton « Citer » d'un commentaire.
+// Retourne { current, clear } : la citation à envoyer (ou undefined) et sa réinitialisation.
+function setupCommentQuote(commentForm, commentInput) {
+    let pending = null;
+
+    const preview = document.createElement('div');
+    preview.className = 'comment-quote-pending hidden';
+    commentForm.insertBefore(preview, commentInput);
+
+    const clear = () => {
+        pending = null;
+        preview.classList.add('hidden');
+        preview.innerHTML = '';
+    };
+
+    document.addEventListener('quote-comment', ({ detail: { comment, excerpt } }) => {
+        pending = { commentId: comment.id, excerpt };
+
+        preview.innerHTML = '';
+        const text = document.createElement('span');
+        const quoted = excerpt || comment.content;
+        text.textContent = `Citation de ${comment.username} : « ${quoted.length > 100 ? quoted.substring(0, 100) + '…' : quoted} »`;
+
+        const cancel = document.createElement('button');
+        cancel.type = 'button';
+        cancel.textContent = 'Annuler';
+        cancel.onclick = clear;
+
+        preview.appendChild(text);
+        preview.appendChild(cancel);
+        preview.classList.remove('hidden');
+        commentInput.focus();
+    });
+
+    return { current: () => pending || undefined, clear };
+}
+
 function setupCommentForm(state) {
     const commentForm = document.getElementById('comment-form');
     if (!commentForm) return;
@@ -110,6 +415,8 @@ function setupCommentForm(state) {
 
     if (!commentInput || !postCommentButton) return;
 
+    const quote = setupCommentQuote(commentForm, commentInput);
+
     postCommentButton.addEventListener('click', async () => {
         // Vérifier que l'utilisateur est authentifié
         if (!state.isAuthenticated) {
@@ -134,9 +441,10 @@ function setupCommentForm(state) {
             const response = await fetch(`/api/posts/${state.currentPost.id}/comments`, {
                 method: 'POST',
                 headers: {
-                    'Content-Type': 'application/json'
+                    'Content-Type': 'application/json',
+                    ...csrfHeaders()
                 },
-                body: JSON.stringify({ content })
+                body: JSON.stringify({ content, quote: quote.current() })
             });
 
             if (!response.ok) {
@@ -145,6 +453,7 @@ function setupCommentForm(state) {
             }
 
             const comment = await response.json();
+            quote.clear();
 
             // Ajouter le commentaire à la liste
             const commentsList = document.getElementById('comments-list');
@@ -170,8 +479,10 @@ function setupCommentForm(state) {
 
             header.appendChild(author);
             header.appendChild(date);
+            header.appendChild(createBookmarkButton('comment', comment));
             commentDiv.appendChild(header);
             commentDiv.appendChild(commentContent);
+            decorateComment(commentDiv, commentContent, comment);
 
             // Supprimer le message "Aucun commentaire" s'il existe
             const emptyMessage = commentsList.querySelector('.empty');
@@ -224,13 +535,158 @@ function setupPostDetail(state) {
                             <div class="post-category">${state.currentPost.category}</div>
                             <div class="post-author">Par ${state.currentPost.username}</div>
                             <div class="post-date">${new Date(state.currentPost.createdAt).toLocaleString()}</div>
+                            <div class="post-views">👁 ${state.currentPost.viewCount} vue${state.currentPost.viewCount > 1 ? 's' : ''}</div>
                         </div>
-                        <div class="post-content">${state.currentPost.content}</div>
+                        <div class="post-content"></div>
                     `;
+                    postDetail.querySelector('h1').appendChild(createPostBadges(state.currentPost));
+                    postDetail.querySelector('h1').appendChild(createBookmarkButton('post', state.currentPost));
+                    postDetail.querySelector('h1').prepend(createVoteControl(state.currentPost));
+                    renderLinkedContent(postDetail.querySelector('.post-content'), state.currentPost.content, state.currentPost.links);
+                    if (state.currentPost.backlinks) {
+                        postDetail.appendChild(createBacklinkList(state.currentPost.backlinks));
+                    }
+                    updateCommentsLock(state.currentPost);
+                    if (state.currentPost.tags) {
+                        postDetail.querySelector('.post-meta').appendChild(createTagList(state.currentPost.tags));
+                    }
+                    if (state.currentPost.attachments) {
+                        postDetail.appendChild(createAttachmentList(state.currentPost.attachments));
+                    }
+                    if (state.currentPost.poll) {
+                        const pollContainer = document.createElement('div');
+                        pollContainer.id = 'post-poll';
+                        pollContainer.className = 'poll';
+                        postDetail.appendChild(pollContainer);
+                        fetchPoll(state.currentPost);
+                    }
                 }
             }
         });
     });
 
     observer.observe(postDetailPage, { attributes: true });
-}
\ No newline at end of file
+}
+// Récupérer les résultats détaillés du sondage d'une publication (votants et choix de l'utilisateur)
+async function fetchPoll(post) {
+    try {
+        const response = await fetch(`/api/posts/${post.id}/poll`);
+        if (!response.ok) {
+            throw new Error(await response.text());
+        }
+        post.poll = await response.json();
+        renderPoll(post.poll);
+    } catch (error) {
+        console.error('Erreur lors de la récupération du sondage:', error);
+    }
+}
+
+// Envoyer le vote de l'utilisateur au sondage d'une publication
+async function votePoll(poll, optionIds) {
+    try {
+        const response = await fetch(`/api/posts/${poll.postId}/poll/votes`, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+                ...csrfHeaders()
+            },
+            body: JSON.stringify({ options: optionIds })
+        });
+
+        if (!response.ok) {
+            const error = await response.text();
+            throw new Error(error);
+        }
+
+        const results = await response.json();
+        if (appState.currentPost && appState.currentPost.id === results.postId) {
+            appState.currentPost.poll = results;
+        }
+        renderPoll(results);
+    } catch (error) {
+        console.error('Erreur lors du vote:', error);
+        alert('Erreur lors du vote: ' + error.message);
+    }
+}
+
+// Afficher un sondage et ses résultats ; tant que l'utilisateur connecté n'a pas voté
+// et que le sondage est ouvert, les choix peuvent être cochés
+export function renderPoll(poll) {
+    const container = document.getElementById('post-poll');
+    if (!container) return;
+    container.innerHTML = '';
+
+    const question = document.createElement('h3');
+    question.textContent = `📊 ${poll.question}`;
+    container.appendChild(question);
+
+    const details = [
+        poll.multiple ? 'Choix multiple' : 'Choix unique',
+        poll.anonymous ? 'Vote anonyme' : 'Vote public',
+        `${poll.totalVoters} votant${poll.totalVoters > 1 ? 's' : ''}`
+    ];
+    if (poll.closed) {
+        details.push('Sondage clos');
+    } else if (poll.closesAt) {
+        details.push(`Clôture le ${new Date(poll.closesAt).toLocaleString()}`);
+    }
+    const meta = document.createElement('div');
+    meta.className = 'poll-meta';
+    meta.textContent = details.join(' · ');
+    container.appendChild(meta);
+
+    const myVotes = poll.myVotes || [];
+    const canVote = appState.isAuthenticated && !poll.closed && myVotes.length === 0;
+
+    poll.options.forEach(option => {
+        const row = document.createElement('label');
+        row.className = 'poll-option';
+        if (myVotes.includes(option.id)) {
+            row.classList.add('chosen');
+        }
+        if (option.voters) {
+            row.title = option.voters.join(', ');
+        }
+
+        if (canVote) {
+            const input = document.createElement('input');
+            input.type = poll.multiple ? 'checkbox' : 'radio';
+            input.name = 'poll-choice';
+            input.value = option.id;
+            row.appendChild(input);
+        }
+
+        const label = document.createElement('span');
+        label.className = 'poll-label';
+        label.textContent = option.label;
+
+        const percent = poll.totalVoters ? Math.round(option.votes * 100 / poll.totalVoters) : 0;
+        const bar = document.createElement('span');
+        bar.className = 'poll-bar';
+        bar.style.width = `${percent}%`;
+
+        const count = document.createElement('span');
+        count.className = 'poll-count';
+        count.textContent = `${option.votes} (${percent} %)`;
+
+        row.appendChild(label);
+        row.appendChild(count);
+        row.appendChild(bar);
+        container.appendChild(row);
+    });
+
+    if (canVote) {
+        const button = document.createElement('button');
+        button.textContent = 'Voter';
+        button.onclick = () => {
+            const chosen = [...container.querySelectorAll('input[name="poll-choice"]:checked')]
+                .map(input => parseInt(input.value));
+            if (chosen.length === 0) {
+                alert('Sélectionnez au moins un choix.');
+                return;
+            }
+            votePoll(poll, chosen);
+        };
+        container.appendChild(button);
+    }
+}
@@ -1,5 +1,23 @@
+import { csrfHeaders } from './auth.js';
+
+// Obtenir un ticket de connexion WebSocket à usage unique
+async function fetchWebSocketTicket() {
+    const response = await fetch('/api/ws/ticket', {
+        method: 'POST',
+        headers: {
+            ...csrfHeaders()
+        }
+    });
+
+    if (!response.ok) {
+        throw new Error(`Erreur HTTP: ${response.status}`);
+    }
+
+    return await response.json();
+}
+
 // Initialiser la connexion WebSocket
-export function initWebSocket(state, messageHandler) {
+export async function initWebSocket(state, messageHandler) {
     try {
         // Ne pas initialiser le WebSocket si l'utilisateur n'est pas authentifié
         if (!state.isAuthenticated || !state.currentUser) {
@@ -7,22 +25,17 @@ export function initWebSocket(state, messageHandler) {
             return null;
         }
 
-        // Récupérer le token de session depuis localStorage
-        const sessionId = localStorage.getItem('session_id');
+        // Obtenir un ticket de courte durée (l'ID de session ne transite jamais dans l'URL)
+        const { ticket, subprotocol } = await fetchWebSocketTicket();
 
-        if (!sessionId) {
-            console.error('Aucun token de session trouvé dans localStorage pour WebSocket');
-            return null;
-        }
-
-        // Créer l'URL WebSocket avec le token
+        // Créer l'URL WebSocket avec le ticket
         const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-        const wsUrl = `${protocol}//${window.location.host}/ws?ls_token=${sessionId}`;
+        const wsUrl = `${protocol}//${window.location.host}/ws?ticket=${encodeURIComponent(ticket)}`;
 
-        console.log('Tentative de connexion WebSocket à', wsUrl);
+        console.log('Tentative de connexion WebSocket');
 
         // Créer la connexion WebSocket
-        const socket = new WebSocket(wsUrl);
+        const socket = new WebSocket(wsUrl, [subprotocol]);
 
         // Configurer les événements
         socket.onopen = () => {
@@ -52,12 +65,20 @@ export function initWebSocket(state, messageHandler) {
         socket.onclose = (event) => {
             console.log(`Connexion WebSocket fermée: ${event.code} ${event.reason}`);
 
+            // Session expirée ou compte suspendu : ne pas tenter de reconnexion, l'utilisateur doit se reconnecter
+            if (event.code === 4001 || event.code === 4003) {
+                localStorage.removeItem('csrf_token');
+                return;
+            }
+
             // Essayer de se reconnecter après un délai si la connexion n'est pas fermée volontairement
             if (event.code !== 1000 && event.code !== 1001) {
                 setTimeout(() => {
                     if (state.isAuthenticated) {
                         console.log('Tentative de reconnexion WebSocket...');
-                        state.socket = initWebSocket(state, messageHandler);
+                        initWebSocket(state, messageHandler).then(socket => {
+                            state.socket = socket;
+                        });
                     }
                 }, 5000); // Reconnecter après 5 secondes
             }
@@ -73,15 +94,3 @@ export function initWebSocket(state, messageHandler) {
         return null;
     }
 }
-
-// Récupérer l'ID de session à partir du cookie
-function getSessionIdFromCookie() {
-    const cookies = document.cookie.split(';');
-    for (let cookie of cookies) {
-        cookie = cookie.trim();
-        if (cookie.startsWith('session_id=')) {
-            return cookie.substring('session_id='.length);
-        }
-    }
-    return '';
-}
\ No newline at end of file
@@ -0,0 +1,117 @@
+// fichier: storage/storage.go
+// Package storage abstrait le stockage des fichiers envoyés par les utilisateurs (avatars, pièces jointes).
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// ErrNotFound est retournée lorsqu'aucun objet n'existe pour la clé demandée
+var ErrNotFound = errors.New("objet non trouvé")
+
+// ErrInvalidKey est retournée pour une clé contenant des caractères non autorisés
+var ErrInvalidKey = errors.New("clé de stockage invalide")
+
+// Format des clés : segments alphanumériques séparés par des "/", sans ".." possible
+var keyPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+(/[a-zA-Z0-9_-]+)*(\.[a-z0-9]+)?$`)
+
+// BlobStore stocke des objets binaires identifiés par une clé
+type BlobStore interface {
+	// Put enregistre (ou remplace) l'objet de clé donnée
+	Put(key string, r io.Reader) error
+	// Get ouvre l'objet de clé donnée ; l'appelant doit le fermer
+	Get(key string) (io.ReadSeekCloser, error)
+	// Delete supprime l'objet ; supprimer un objet absent n'est pas une erreur
+	Delete(key string) error
+}
+
+// Default est le stockage utilisé par l'application, initialisé au démarrage
+var Default BlobStore
+
+// ValidKey indique si la clé respecte le format accepté par les stockages
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// LocalStore stocke les objets dans un répertoire du système de fichiers local
+type LocalStore struct {
+	Root string
+}
+
+// NewLocalStore crée le répertoire racine si nécessaire et retourne le stockage associé
+func NewLocalStore(root string) (*LocalStore, error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{Root: root}, nil
+}
+
+// path convertit une clé en chemin de fichier sous la racine
+func (s *LocalStore) path(key string) (string, error) {
+	if !ValidKey(key) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.Root, filepath.FromSlash(key)), nil
+}
+
+// Put écrit l'objet dans un fichier temporaire puis le renomme, pour ne jamais exposer d'objet partiel
+func (s *LocalStore) Put(key string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get ouvre le fichier correspondant à la clé
+func (s *LocalStore) Get(key string) (io.ReadSeekCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// Delete supprime le fichier correspondant à la clé
+func (s *LocalStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
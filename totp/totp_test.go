@@ -0,0 +1,92 @@
+// fichier: totp/totp_test.go
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// Secret des vecteurs de test de la RFC 6238 (annexe B, HMAC-SHA1) : "12345678901234567890" en ASCII
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeRFC6238Vectors(t *testing.T) {
+	// Les codes de l'annexe B comptent 8 chiffres : les 6 derniers correspondent à Digits = 6
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tt := range tests {
+		want := tt.code[len(tt.code)-Digits:]
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("Code(T=%d): %v", tt.unix, err)
+		}
+		if got != want {
+			t.Errorf("Code(T=%d) = %s, attendu %s", tt.unix, got, want)
+		}
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := Step(now)
+
+	tests := []struct {
+		name  string
+		delta int64
+		valid bool
+	}{
+		{"période courante", 0, true},
+		{"période précédente", -1, true},
+		{"période suivante", 1, true},
+		{"deux périodes avant", -2, false},
+		{"deux périodes après", 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := Code(rfcSecret, current+tt.delta)
+			if err != nil {
+				t.Fatalf("Code: %v", err)
+			}
+
+			step, ok := Validate(rfcSecret, code, now, 1)
+			if ok != tt.valid {
+				t.Fatalf("Validate = %v, attendu %v", ok, tt.valid)
+			}
+			// La période retournée permet à l'appelant de refuser la réutilisation du code
+			if ok && step != current+tt.delta {
+				t.Errorf("période retournée %d, attendu %d", step, current+tt.delta)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsMalformedCodes(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, err := Code(rfcSecret, Step(now))
+	if err != nil {
+		t.Fatalf("Code: %v", err)
+	}
+
+	if _, ok := Validate(rfcSecret, " "+code[:3]+" "+code[3:]+" ", now, 1); !ok {
+		t.Errorf("un code entouré ou coupé d'espaces doit être accepté")
+	}
+	for _, bad := range []string{"", code[:Digits-1], code + "0", "abcdef"} {
+		if _, ok := Validate(rfcSecret, bad, now, 1); ok {
+			t.Errorf("le code %q ne doit pas être accepté", bad)
+		}
+	}
+	if _, ok := Validate("secret invalide!", code, now, 1); ok {
+		t.Errorf("un secret invalide ne doit valider aucun code")
+	}
+}
@@ -0,0 +1,234 @@
+// fichier: webhooks/webhooks.go
+// Package webhooks publie les événements du forum vers des URL externes.
+// Les livraisons sont persistées dans SQLite et retentées avec un délai exponentiel.
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"realtimeforum/config"
+	"realtimeforum/database"
+	"strconv"
+	"time"
+)
+
+// En-têtes ajoutés à chaque livraison
+const (
+	EventHeader     = "X-Forum-Event"
+	DeliveryHeader  = "X-Forum-Delivery"
+	TimestampHeader = "X-Forum-Timestamp"
+	SignatureHeader = "X-Forum-Signature"
+)
+
+// Nombre maximal de livraisons traitées par passe
+const batchSize = 50
+
+// Longueur maximale du message d'erreur conservé dans le journal
+const maxErrorLength = 500
+
+// wake réveille le répartiteur dès qu'un événement est mis en file
+var wake = make(chan struct{}, 1)
+
+// Envelope est le corps JSON envoyé aux webhooks
+type Envelope struct {
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// Emit met en file un événement pour tous les webhooks abonnés.
+// Les erreurs sont journalisées sans interrompre l'action qui a déclenché l'événement.
+func Emit(event string, data interface{}) {
+	body, err := json.Marshal(Envelope{Event: event, CreatedAt: time.Now(), Data: data})
+	if err != nil {
+		log.Printf("Erreur lors de la sérialisation de l'événement %s: %v", event, err)
+		return
+	}
+
+	count, err := database.EnqueueWebhookEvent(event, string(body))
+	if err != nil {
+		log.Printf("Erreur lors de la mise en file de l'événement %s: %v", event, err)
+		return
+	}
+
+	if count > 0 {
+		Notify()
+	}
+}
+
+// Notify réveille le répartiteur sans attendre la prochaine consultation de la file
+func Notify() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// Sign calcule la signature HMAC-SHA256 d'une livraison : sha256=hex(HMAC(secret, timestamp + "." + body))
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// StartDispatcher lance en arrière-plan la livraison des webhooks en attente
+func StartDispatcher(interval time.Duration) {
+	client := &http.Client{
+		Timeout: config.App.WebhookTimeout,
+		// Ne pas suivre les redirections : la réponse du point de terminaison configuré fait foi
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			dispatchDue(client)
+
+			select {
+			case <-ticker.C:
+			case <-wake:
+			}
+		}
+	}()
+}
+
+// dispatchDue livre toutes les livraisons arrivées à échéance. Chaque livraison traitée quitte la file
+// (livrée, abandonnée ou replanifiée) ; la passe s'interrompt si un résultat ne peut être enregistré.
+func dispatchDue(client *http.Client) {
+	for {
+		deliveries, err := database.GetDueWebhookDeliveries(batchSize)
+		if err != nil {
+			log.Printf("Erreur lors de la récupération des livraisons de webhooks: %v", err)
+			return
+		}
+
+		for _, delivery := range deliveries {
+			if err := dispatch(client, delivery); err != nil {
+				log.Printf("Erreur lors de l'enregistrement de la livraison ID=%d: %v", delivery.ID, err)
+				return
+			}
+		}
+
+		if len(deliveries) < batchSize {
+			return
+		}
+	}
+}
+
+// dispatch relit le webhook d'une livraison juste avant la tentative : une livraison dont le webhook
+// a été supprimé ou désactivé depuis sa mise en file est abandonnée sans être envoyée
+func dispatch(client *http.Client, delivery *database.WebhookDelivery) error {
+	webhook, err := database.GetWebhookByID(delivery.WebhookID)
+	switch {
+	case errors.Is(err, database.ErrWebhookNotFound):
+		return drop(delivery, "webhook supprimé")
+	case err != nil:
+		// Erreur passagère : la tentative compte comme un échec et la livraison est replanifiée
+		log.Printf("Erreur lors de la récupération du webhook ID=%d pour la livraison ID=%d: %v", delivery.WebhookID, delivery.ID, err)
+		return recordAttempt(delivery, 0, fmt.Errorf("webhook indisponible: %w", err))
+	case !webhook.Active:
+		return drop(delivery, "webhook désactivé")
+	}
+
+	status, err := send(client, webhook, delivery)
+	return recordAttempt(delivery, status, err)
+}
+
+// drop abandonne une livraison sans tentative
+func drop(delivery *database.WebhookDelivery, reason string) error {
+	log.Printf("Livraison ID=%d abandonnée: %s", delivery.ID, reason)
+	delivery.Status = database.DeliveryFailed
+	delivery.LastError = reason
+	return database.RecordWebhookAttempt(delivery)
+}
+
+// recordAttempt enregistre le résultat d'une tentative de livraison et planifie la suivante en cas d'échec
+func recordAttempt(delivery *database.WebhookDelivery, status int, err error) error {
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = nil
+	delivery.LastError = ""
+	if status != 0 {
+		delivery.ResponseStatus = &status
+	}
+
+	switch {
+	case err == nil:
+		delivery.Status = database.DeliveryDelivered
+	case delivery.Attempts >= config.App.WebhookMaxAttempts:
+		delivery.Status = database.DeliveryFailed
+		delivery.LastError = truncate(err.Error())
+		log.Printf("Livraison ID=%d abandonnée après %d tentative(s): %v", delivery.ID, delivery.Attempts, err)
+	default:
+		delivery.LastError = truncate(err.Error())
+		delivery.NextAttemptAt = now.Add(retryDelay(delivery.Attempts))
+		log.Printf("Échec de la livraison ID=%d (tentative %d), nouvel essai à %s: %v",
+			delivery.ID, delivery.Attempts, delivery.NextAttemptAt.Format(time.RFC3339), err)
+	}
+
+	return database.RecordWebhookAttempt(delivery)
+}
+
+// send envoie la livraison signée ; tout statut hors 2xx est un échec
+func send(client *http.Client, webhook *database.Webhook, delivery *database.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "RealTimeForum-Webhooks/1.0")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, strconv.Itoa(delivery.ID))
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("réponse HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryDelay calcule le délai avant la tentative suivante : base × 2^(tentatives−1), plafonné
+func retryDelay(attempts int) time.Duration {
+	delay := config.App.WebhookRetryBase
+	for i := 1; i < attempts && delay < config.App.WebhookRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > config.App.WebhookRetryMax {
+		delay = config.App.WebhookRetryMax
+	}
+	return delay
+}
+
+// truncate limite la longueur d'un message d'erreur
+func truncate(message string) string {
+	if len(message) > maxErrorLength {
+		return message[:maxErrorLength]
+	}
+	return message
+}